@@ -7,3 +7,6 @@ pub use file::*;
 pub use nodes::*;
 pub use print::Printer;
 pub use token::*;
+
+#[cfg(test)]
+mod token_test;
@@ -1,3 +1,5 @@
+use std::collections::HashMap;
+
 use crate::{
     ast::Token,
     common::{Pos, Span},
@@ -7,6 +9,8 @@ pub type NodeId = usize;
 
 #[derive(Debug)]
 pub struct Ast {
+    /// Optional package declaration at the top of the file.
+    pub package: Option<PackageNode>,
     pub imports: Vec<ImportNode>,
     // Declarations are the only top level statements in koi. They contain
     // all other statements and expressions. Eg. a function has a block
@@ -21,6 +25,21 @@ impl Ast {
             node.accept(visitor);
         }
     }
+
+    /// Doc comments of all documented functions, structs, and constants,
+    /// keyed by declaration name.
+    pub fn docs(&self) -> HashMap<String, String> {
+        self.decls
+            .iter()
+            .filter_map(|decl| match decl {
+                Decl::Func(node) => Some((&node.name, &node.doc)),
+                Decl::Struct(node) => Some((&node.name, &node.doc)),
+                Decl::Const(node) => Some((&node.name, &node.doc)),
+                _ => None,
+            })
+            .filter_map(|(name, doc)| Some((name.to_string(), doc.clone()?)))
+            .collect()
+    }
 }
 
 /// A node is any part of the AST, including statements, expressions, and
@@ -67,10 +86,13 @@ pub trait Visitor<R> {
     fn visit_func(&mut self, node: &FuncNode) -> R;
     fn visit_extern(&mut self, node: &FuncDeclNode) -> R;
     fn visit_type_decl(&mut self, node: &TypeDeclNode) -> R;
+    fn visit_const_decl(&mut self, node: &ConstDeclNode) -> R;
+    fn visit_struct_decl(&mut self, node: &StructDeclNode) -> R;
     fn visit_block(&mut self, node: &BlockNode) -> R;
     fn visit_return(&mut self, node: &ReturnNode) -> R;
     fn visit_type(&mut self, node: &TypeNode) -> R;
     fn visit_var_decl(&mut self, node: &VarDeclNode) -> R;
+    fn visit_var(&mut self, node: &VarNode) -> R;
     fn visit_var_assign(&mut self, node: &VarAssignNode) -> R;
     fn visit_import(&mut self, node: &ImportNode) -> R;
     fn visit_if(&mut self, node: &IfNode) -> R;
@@ -81,6 +103,10 @@ pub trait Visitor<R> {
     fn visit_op_assign(&mut self, node: &OpAssignNode) -> R;
 
     fn visit_member(&mut self, node: &MemberNode) -> R;
+    fn visit_index(&mut self, node: &IndexExpr) -> R;
+    fn visit_struct_lit(&mut self, node: &StructLitExpr) -> R;
+    fn visit_tuple(&mut self, node: &TupleExpr) -> R;
+    fn visit_ternary(&mut self, node: &TernaryExpr) -> R;
     fn visit_literal(&mut self, node: &Token) -> R;
     fn visit_call(&mut self, node: &CallExpr) -> R;
     fn visit_group(&mut self, node: &GroupExpr) -> R;
@@ -98,6 +124,8 @@ pub enum Decl {
     Func(Box<FuncNode>),
     Extern(Box<FuncDeclNode>),
     Type(Box<TypeDeclNode>),
+    Const(Box<ConstDeclNode>),
+    Struct(Box<StructDeclNode>),
 }
 
 /// Statements are found inside blocks. They have side effects and do
@@ -108,6 +136,7 @@ pub enum Stmt {
     Return(ReturnNode),
     Block(BlockNode),
     VarDecl(VarDeclNode),
+    Var(VarNode),
     VarAssign(VarAssignNode),
     If(IfNode),
     While(WhileNode),
@@ -125,6 +154,13 @@ pub enum Expr {
     Group(GroupExpr),
     Call(CallExpr),
     Member(MemberNode),
+    Index(IndexExpr),
+    StructLit(StructLitExpr),
+    /// Comma separated values, only used for multiple return values
+    Tuple(TupleExpr),
+    Ternary(TernaryExpr),
+    /// Block evaluating to its last expression, eg. { a := 1; a + 1 }
+    Block(BlockNode),
     Binary(BinaryExpr),
     Unary(UnaryExpr),
     Cast(CastExpr),
@@ -134,7 +170,29 @@ pub enum Expr {
 #[derive(Debug, Clone)]
 pub enum TypeNode {
     Ident(Token),
-    Imported { namespace: Token, ty: Token },
+    Imported {
+        namespace: Token,
+        ty: Token,
+    },
+    /// Fixed size array, eg. [4]int
+    Array {
+        lbrack: Token,
+        size: Box<Expr>,
+        rbrack: Token,
+        elem: Box<TypeNode>,
+    },
+    /// Array of unknown size, eg. []int
+    Slice {
+        lbrack: Token,
+        rbrack: Token,
+        elem: Box<TypeNode>,
+    },
+    /// List of types for multiple return values, eg. (int, string)
+    Tuple {
+        lparen: Token,
+        types: Vec<TypeNode>,
+        rparen: Token,
+    },
 }
 
 #[derive(Debug, Clone)]
@@ -172,14 +230,76 @@ pub struct MemberNode {
     pub field: Token,
 }
 
+/// Array index, eg. arr[i]
+#[derive(Debug, Clone)]
+pub struct IndexExpr {
+    pub expr: Box<Expr>,
+    pub lbrack: Token,
+    pub index: Box<Expr>,
+    pub rbrack: Token,
+}
+
+/// Struct literal with named fields, eg. Point{x: 1, y: 2}
+#[derive(Debug, Clone)]
+pub struct StructLitExpr {
+    pub name: Token,
+    pub lbrace: Token,
+    pub fields: Vec<FieldValue>,
+    pub rbrace: Token,
+}
+
+/// Named field value in a struct literal, eg. x: 1
+#[derive(Debug, Clone)]
+pub struct FieldValue {
+    pub name: Token,
+    pub value: Expr,
+}
+
+/// Multiple values, eg. return a, b
+#[derive(Debug, Clone)]
+pub struct TupleExpr {
+    pub exprs: Vec<Expr>,
+    /// First comma, gives the node its id
+    pub comma: Token,
+}
+
+/// Conditional expression, eg. cond ? a : b
+#[derive(Debug, Clone)]
+pub struct TernaryExpr {
+    pub cond: Box<Expr>,
+    pub question: Token,
+    pub then: Box<Expr>,
+    pub colon: Token,
+    pub otherwise: Box<Expr>,
+}
+
 #[derive(Debug, Clone)]
 pub struct VarDeclNode {
     pub constant: bool,
     pub name: Token,
+    /// Explicit type, eg. x int = 0
+    pub ty: Option<TypeNode>,
     pub symbol: Token,
     pub expr: Expr,
 }
 
+/// Variable declared with the var keyword, eg. var x int. Either the type or
+/// the value may be left out, but not both. Without a value the variable is
+/// zero initialized.
+#[derive(Debug, Clone)]
+pub struct VarNode {
+    pub kw: Token,
+    pub name: Token,
+    pub ty: Option<TypeNode>,
+    pub expr: Option<Expr>,
+}
+
+#[derive(Debug, Clone)]
+pub struct PackageNode {
+    pub kw: Token,
+    pub name: Token,
+}
+
 #[derive(Debug, Clone)]
 pub struct ImportNode {
     pub kw: Token,
@@ -231,6 +351,8 @@ pub enum ElseBlock {
 #[derive(Debug, Clone)]
 pub struct IfNode {
     pub kw: Token,
+    /// Optional statement run before the condition, eg. if x := f(); x > 0
+    pub init: Option<Box<Stmt>>,
     pub expr: Expr,
     pub block: BlockNode,
     pub elseif: Box<ElseBlock>,
@@ -238,6 +360,8 @@ pub struct IfNode {
 
 #[derive(Debug, Clone)]
 pub struct WhileNode {
+    /// The for keyword when written as for { } or for x < 10 { }. The
+    /// condition of for { } is a synthetic true literal.
     pub kw: Token,
     pub expr: Expr,
     pub block: BlockNode,
@@ -289,7 +413,10 @@ pub struct FuncNode {
     pub params: Vec<Field>,
     pub rparen: Token,
     pub ret_type: Option<TypeNode>,
+    /// A body written as => expr has the arrow as its opening brace.
     pub body: BlockNode,
+    /// Comment lines directly above the declaration.
+    pub doc: Option<String>,
 }
 
 #[derive(Debug, Clone)]
@@ -298,9 +425,37 @@ pub struct TypeDeclNode {
     pub unique: bool,
     pub kw: Token,
     pub name: Token,
+    /// Equal sign of an alias written as type X = int.
+    pub eq: Option<Token>,
     pub ty: TypeNode,
 }
 
+/// Top-level constant declaration, eg. max :: 10
+#[derive(Debug, Clone)]
+pub struct ConstDeclNode {
+    pub public: bool,
+    pub name: Token,
+    /// Declared type, inferred from the value if not given.
+    pub ty: Option<TypeNode>,
+    pub symbol: Token,
+    pub expr: Expr,
+    /// Comment lines directly above the declaration.
+    pub doc: Option<String>,
+}
+
+/// Struct type declaration, eg. struct Point { x int, y int }
+#[derive(Debug, Clone)]
+pub struct StructDeclNode {
+    pub public: bool,
+    pub kw: Token,
+    pub name: Token,
+    pub lbrace: Token,
+    pub fields: Vec<Field>,
+    pub rbrace: Token,
+    /// Comment lines directly above the declaration.
+    pub doc: Option<String>,
+}
+
 impl From<FuncNode> for FuncDeclNode {
     fn from(f: FuncNode) -> Self {
         FuncDeclNode {
@@ -334,6 +489,8 @@ impl Visitable for Decl {
             Decl::Func(node) => visitor.visit_func(node),
             Decl::Extern(node) => visitor.visit_extern(node),
             Decl::Type(node) => visitor.visit_type_decl(node),
+            Decl::Const(node) => visitor.visit_const_decl(node),
+            Decl::Struct(node) => visitor.visit_struct_decl(node),
         }
     }
 }
@@ -345,6 +502,7 @@ impl Visitable for Stmt {
             Stmt::Return(node) => visitor.visit_return(node),
             Stmt::Block(node) => visitor.visit_block(node),
             Stmt::VarDecl(node) => visitor.visit_var_decl(node),
+            Stmt::Var(node) => visitor.visit_var(node),
             Stmt::VarAssign(node) => visitor.visit_var_assign(node),
             Stmt::If(node) => visitor.visit_if(node),
             Stmt::While(node) => visitor.visit_while(node),
@@ -363,6 +521,11 @@ impl Visitable for Expr {
             Expr::Call(call) => visitor.visit_call(call),
             Expr::Group(grp) => visitor.visit_group(grp),
             Expr::Member(node) => visitor.visit_member(node),
+            Expr::Index(node) => visitor.visit_index(node),
+            Expr::StructLit(node) => visitor.visit_struct_lit(node),
+            Expr::Tuple(node) => visitor.visit_tuple(node),
+            Expr::Ternary(node) => visitor.visit_ternary(node),
+            Expr::Block(node) => visitor.visit_block(node),
             Expr::Binary(node) => visitor.visit_binary(node),
             Expr::Unary(node) => visitor.visit_unary(node),
             Expr::Cast(node) => visitor.visit_cast(node),
@@ -381,6 +544,8 @@ impl Node for TypeNode {
         match self {
             TypeNode::Ident(token) => &token.pos,
             TypeNode::Imported { namespace, .. } => &namespace.pos,
+            TypeNode::Array { lbrack, .. } | TypeNode::Slice { lbrack, .. } => &lbrack.pos,
+            TypeNode::Tuple { lparen, .. } => &lparen.pos,
         }
     }
 
@@ -388,6 +553,8 @@ impl Node for TypeNode {
         match self {
             TypeNode::Ident(token) => &token.end_pos,
             TypeNode::Imported { ty, .. } => &ty.pos,
+            TypeNode::Array { elem, .. } | TypeNode::Slice { elem, .. } => Node::end(elem.as_ref()),
+            TypeNode::Tuple { rparen, .. } => &rparen.end_pos,
         }
     }
 
@@ -395,6 +562,8 @@ impl Node for TypeNode {
         match self {
             TypeNode::Ident(token) => token.id,
             TypeNode::Imported { ty, .. } => ty.id,
+            TypeNode::Array { lbrack, .. } | TypeNode::Slice { lbrack, .. } => lbrack.id,
+            TypeNode::Tuple { lparen, .. } => lparen.id,
         }
     }
 }
@@ -429,13 +598,20 @@ macro_rules! impl_node_enum {
     };
 }
 
-impl_node_enum!(Decl { Func, Extern, Type });
+impl_node_enum!(Decl {
+    Func,
+    Extern,
+    Type,
+    Const,
+    Struct
+});
 
 impl_node_enum!(Stmt {
     ExprStmt,
     Return,
     Block,
     VarDecl,
+    Var,
     VarAssign,
     If,
     While,
@@ -452,6 +628,11 @@ impl Node for Expr {
             Expr::Call(node) => Node::pos(node),
             Expr::Group(grp) => &grp.lparen.pos,
             Expr::Member(node) => Node::pos(&*node.expr),
+            Expr::Index(node) => Node::pos(node),
+            Expr::StructLit(node) => Node::pos(node),
+            Expr::Tuple(node) => Node::pos(node),
+            Expr::Ternary(node) => Node::pos(node),
+            Expr::Block(node) => Node::pos(node),
             Expr::Binary(node) => Node::pos(node),
             Expr::Unary(node) => Node::pos(node),
             Expr::Cast(node) => Node::pos(node),
@@ -463,6 +644,11 @@ impl Node for Expr {
             Expr::Literal(token) => &token.end_pos,
             Expr::Call(call) => Node::end(call),
             Expr::Member(node) => &node.field.end_pos,
+            Expr::Index(node) => Node::end(node),
+            Expr::StructLit(node) => Node::end(node),
+            Expr::Tuple(node) => Node::end(node),
+            Expr::Ternary(node) => Node::end(node),
+            Expr::Block(node) => Node::end(node),
             Expr::Group(grp) => &grp.rparen.end_pos,
             Expr::Binary(node) => Node::end(node),
             Expr::Unary(node) => Node::end(node),
@@ -476,6 +662,11 @@ impl Node for Expr {
             Expr::Call(call) => call.id(),
             Expr::Group(grp) => grp.rparen.id,
             Expr::Member(node) => node.dot.id,
+            Expr::Index(node) => node.id(),
+            Expr::StructLit(node) => node.id(),
+            Expr::Tuple(node) => node.id(),
+            Expr::Ternary(node) => node.id(),
+            Expr::Block(node) => node.id(),
             Expr::Binary(node) => node.id(),
             Expr::Unary(node) => node.id(),
             Expr::Cast(node) => node.id(),
@@ -539,6 +730,24 @@ impl Node for VarDeclNode {
     }
 }
 
+impl Node for VarNode {
+    fn pos(&self) -> &Pos {
+        &self.kw.pos
+    }
+
+    fn end(&self) -> &Pos {
+        match (&self.expr, &self.ty) {
+            (Some(expr), _) => Node::end(expr),
+            (None, Some(ty)) => Node::end(ty),
+            (None, None) => &self.name.end_pos,
+        }
+    }
+
+    fn id(&self) -> NodeId {
+        self.kw.id
+    }
+}
+
 impl Node for VarAssignNode {
     fn pos(&self) -> &Pos {
         Node::pos(&self.lval)
@@ -637,6 +846,62 @@ impl Node for MemberNode {
     }
 }
 
+impl Node for IndexExpr {
+    fn pos(&self) -> &Pos {
+        Node::pos(&*self.expr)
+    }
+
+    fn end(&self) -> &Pos {
+        &self.rbrack.end_pos
+    }
+
+    fn id(&self) -> NodeId {
+        self.lbrack.id
+    }
+}
+
+impl Node for StructLitExpr {
+    fn pos(&self) -> &Pos {
+        &self.name.pos
+    }
+
+    fn end(&self) -> &Pos {
+        &self.rbrace.end_pos
+    }
+
+    fn id(&self) -> NodeId {
+        self.lbrace.id
+    }
+}
+
+impl Node for TupleExpr {
+    fn pos(&self) -> &Pos {
+        Node::pos(&self.exprs[0])
+    }
+
+    fn end(&self) -> &Pos {
+        Node::end(self.exprs.last().unwrap())
+    }
+
+    fn id(&self) -> NodeId {
+        self.comma.id
+    }
+}
+
+impl Node for TernaryExpr {
+    fn pos(&self) -> &Pos {
+        Node::pos(&*self.cond)
+    }
+
+    fn end(&self) -> &Pos {
+        Node::end(&*self.otherwise)
+    }
+
+    fn id(&self) -> NodeId {
+        self.question.id
+    }
+}
+
 impl Node for UnaryExpr {
     fn pos(&self) -> &Pos {
         &self.op.pos
@@ -735,6 +1000,34 @@ impl Node for TypeDeclNode {
     }
 }
 
+impl Node for ConstDeclNode {
+    fn pos(&self) -> &Pos {
+        &self.name.pos
+    }
+
+    fn end(&self) -> &Pos {
+        Node::end(&self.expr)
+    }
+
+    fn id(&self) -> NodeId {
+        self.name.id
+    }
+}
+
+impl Node for StructDeclNode {
+    fn pos(&self) -> &Pos {
+        &self.name.pos
+    }
+
+    fn end(&self) -> &Pos {
+        &self.name.end_pos
+    }
+
+    fn id(&self) -> NodeId {
+        self.name.id
+    }
+}
+
 impl Node for CastExpr {
     fn pos(&self) -> &Pos {
         Node::pos(&*self.expr)
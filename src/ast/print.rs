@@ -1,13 +1,23 @@
-use crate::ast::{
-    Ast, BlockNode, ElseBlock, FuncNode, ReturnNode, Stmt, Token, TypeNode, Visitable, Visitor,
+use std::{collections::HashMap, ops::Range};
+
+use crate::{
+    ast::{
+        Ast, BlockNode, Decl, ElseBlock, Expr, FuncNode, Node, NodeId, ReturnNode, Stmt, Token,
+        TokenKind, TypeNode, Visitable, Visitor,
+    },
+    common::Source,
 };
 
-pub struct Printer {
+pub struct Printer<'a> {
     s: String,
     indent: usize,
+    /// Output byte range of each printed declaration and statement.
+    ranges: HashMap<NodeId, Range<usize>>,
+    /// Source the AST was parsed from. Literals are copied from it as written.
+    source: Option<&'a Source>,
 }
 
-impl Printer {
+impl<'a> Printer<'a> {
     /// Convert AST to printable format and print to stdout
     pub fn print(ast: &Ast) {
         println!("{}", Printer::to_string(ast));
@@ -15,28 +25,109 @@ impl Printer {
 
     /// Convert AST to printable format
     pub fn to_string(ast: &Ast) -> String {
-        let mut s = Self {
+        Printer::to_string_with_ranges(ast).0
+    }
+
+    /// Convert AST to printable format. Also returns the byte range in the output
+    /// of each declaration and statement, keyed by node id.
+    pub fn to_string_with_ranges(ast: &Ast) -> (String, HashMap<NodeId, Range<usize>>) {
+        Printer::new(None).print_ast(ast)
+    }
+
+    /// Convert AST to source text. Literals are written as they appear in the
+    /// given source, so eg. hex numbers and string escapes keep their form.
+    pub fn format(ast: &Ast, source: &'a Source) -> String {
+        Printer::new(Some(source)).print_ast(ast).0
+    }
+
+    fn new(source: Option<&'a Source>) -> Self {
+        Self {
             s: String::new(),
             indent: 0,
-        };
+            ranges: HashMap::new(),
+            source,
+        }
+    }
+
+    fn print_ast(mut self, ast: &Ast) -> (String, HashMap<NodeId, Range<usize>>) {
+        let s = &mut self;
+
+        if let Some(package) = &ast.package {
+            s.s.push_str(&format!("package {}\n\n", package.name));
+        }
 
         for node in &ast.imports {
             s.visit_import(node);
         }
 
+        let mut prev: Option<&Decl> = None;
         for node in &ast.decls {
-            node.accept(&mut s);
+            if let Some(prev) = prev {
+                s.separate(prev, node);
+            }
+
+            let start = s.s.len();
+            node.accept(s);
+            s.record(node.id(), start);
+            prev = Some(node);
         }
 
-        s.s.clone()
+        (self.s, self.ranges)
+    }
+
+    /// End the previous declaration with a newline. Consecutive constants or
+    /// types are kept together, other declarations get an empty line between.
+    fn separate(&mut self, prev: &Decl, next: &Decl) {
+        let grouped = matches!(
+            (prev, next),
+            (Decl::Const(_), Decl::Const(_)) | (Decl::Type(_), Decl::Type(_))
+        );
+
+        let want = if grouped { 1 } else { 2 };
+        let have = self.s.len() - self.s.trim_end_matches('\n').len();
+        for _ in have..want {
+            self.s.push('\n');
+        }
+    }
+
+    /// Record the output range of a node written from start to the current end,
+    /// ignoring trailing whitespace.
+    fn record(&mut self, id: NodeId, start: usize) {
+        let end = start + self.s[start..].trim_end().len();
+        self.ranges.insert(id, start..end);
     }
 
     fn token(&mut self, token: &Token) {
-        self.s.push_str(&format!("{}", token.kind));
+        let is_literal = matches!(
+            token.kind,
+            TokenKind::IntLit(_)
+                | TokenKind::FloatLit(_)
+                | TokenKind::StringLit(_)
+                | TokenKind::CharLit(_)
+        );
+
+        // Synthetic tokens have no length and are not in the source
+        match self.source {
+            Some(source) if is_literal && token.length > 0 && token.pos.source_id == source.id => {
+                let end = token.pos.offset + token.length;
+                self.s.push_str(source.str_range(token.pos.offset, end));
+            }
+            _ => self.s.push_str(&token.to_source()),
+        }
+    }
+
+    /// Write a comma separated list of tokens.
+    fn token_list(&mut self, tokens: &[Token], sep: &str) {
+        for (i, token) in tokens.iter().enumerate() {
+            if i > 0 {
+                self.s.push_str(sep);
+            }
+            self.token(token);
+        }
     }
 }
 
-impl Visitor<()> for Printer {
+impl Visitor<()> for Printer<'_> {
     fn visit_literal(&mut self, node: &Token) {
         self.token(node);
     }
@@ -75,7 +166,17 @@ impl Visitor<()> for Printer {
             self.s.push(' ');
         }
 
-        Stmt::Block(node.body.clone()).accept(self);
+        match node.body.stmts.as_slice() {
+            [Stmt::Return(ret)] if node.body.lbrace.kind == TokenKind::FatArrow => {
+                self.s += "=> ";
+                let start = self.s.len();
+                if let Some(expr) = &ret.expr {
+                    expr.accept(self);
+                }
+                self.record(ret.id(), start);
+            }
+            _ => Stmt::Block(node.body.clone()).accept(self),
+        }
         self.s += "\n";
         self.s += "\n";
     }
@@ -88,7 +189,9 @@ impl Visitor<()> for Printer {
             for _ in 0..self.indent {
                 self.s.push_str("    ");
             }
+            let start = self.s.len();
             stmt.accept(self);
+            self.record(stmt.id(), start);
             self.s.push('\n');
         }
         self.indent -= 1;
@@ -101,7 +204,31 @@ impl Visitor<()> for Printer {
     fn visit_type(&mut self, node: &super::TypeNode) {
         match node {
             TypeNode::Ident(tok) => self.visit_literal(tok),
-            TypeNode::Imported { namespace, ty } => self.s += &format!("{namespace}.{ty}"),
+            TypeNode::Imported { namespace, ty } => {
+                self.token(namespace);
+                self.s.push('.');
+                self.token(ty);
+            }
+            TypeNode::Array { size, elem, .. } => {
+                self.s.push('[');
+                size.accept(self);
+                self.s.push(']');
+                elem.accept(self);
+            }
+            TypeNode::Slice { elem, .. } => {
+                self.s += "[]";
+                elem.accept(self);
+            }
+            TypeNode::Tuple { types, .. } => {
+                self.s.push('(');
+                for (i, ty) in types.iter().enumerate() {
+                    if i > 0 {
+                        self.s += ", ";
+                    }
+                    ty.accept(self);
+                }
+                self.s.push(')');
+            }
         }
     }
 
@@ -154,10 +281,29 @@ impl Visitor<()> for Printer {
     }
 
     fn visit_var_decl(&mut self, node: &super::VarDeclNode) {
-        self.s.push_str(&format!("{} {} ", node.name, node.symbol));
+        self.token(&node.name);
+        self.s.push(' ');
+        if let Some(ty) = &node.ty {
+            ty.accept(self);
+            self.s.push(' ');
+        }
+        self.s.push_str(&format!("{} ", node.symbol));
         node.expr.accept(self);
     }
 
+    fn visit_var(&mut self, node: &super::VarNode) {
+        self.s.push_str("var ");
+        self.token(&node.name);
+        if let Some(ty) = &node.ty {
+            self.s.push(' ');
+            ty.accept(self);
+        }
+        if let Some(expr) = &node.expr {
+            self.s.push_str(" = ");
+            expr.accept(self);
+        }
+    }
+
     fn visit_var_assign(&mut self, node: &super::VarAssignNode) {
         node.lval.accept(self);
         self.s.push_str(" = ");
@@ -165,34 +311,62 @@ impl Visitor<()> for Printer {
     }
 
     fn visit_import(&mut self, node: &super::ImportNode) {
-        self.s.push_str(&format!(
-            "import {} {}\n\n",
-            node.names
-                .iter()
-                .map(|t| t.to_string())
-                .collect::<Vec<_>>()
-                .join("."),
-            if let Some(alias) = &node.alias {
-                format!("as {}", alias)
-            } else if !node.imports.is_empty() {
-                format!(
-                    "{{\n    {}\n}}",
-                    node.imports
-                        .iter()
-                        .map(|t| t.to_string())
-                        .collect::<Vec<_>>()
-                        .join(",\n    ")
-                )
-            } else {
-                "".to_string()
-            }
-        ));
+        self.s.push_str("import ");
+        self.token_list(&node.names, ".");
+        self.s.push(' ');
+        if let Some(alias) = &node.alias {
+            self.s.push_str("as ");
+            self.token(alias);
+        } else if !node.imports.is_empty() {
+            self.s.push_str("{\n    ");
+            self.token_list(&node.imports, ",\n    ");
+            self.s.push_str("\n}");
+        }
+        self.s.push_str("\n\n");
     }
 
     fn visit_member(&mut self, node: &super::MemberNode) {
         node.expr.accept(self);
         self.s.push('.');
-        self.s.push_str(&node.field.to_string());
+        self.token(&node.field);
+    }
+
+    fn visit_index(&mut self, node: &super::IndexExpr) {
+        node.expr.accept(self);
+        self.s.push('[');
+        node.index.accept(self);
+        self.s.push(']');
+    }
+
+    fn visit_struct_lit(&mut self, node: &super::StructLitExpr) {
+        self.token(&node.name);
+        self.s += "{";
+        for (i, field) in node.fields.iter().enumerate() {
+            if i > 0 {
+                self.s += ", ";
+            }
+            self.token(&field.name);
+            self.s += ": ";
+            field.value.accept(self);
+        }
+        self.s += "}";
+    }
+
+    fn visit_tuple(&mut self, node: &super::TupleExpr) {
+        for (i, expr) in node.exprs.iter().enumerate() {
+            if i > 0 {
+                self.s += ", ";
+            }
+            expr.accept(self);
+        }
+    }
+
+    fn visit_ternary(&mut self, node: &super::TernaryExpr) {
+        node.cond.accept(self);
+        self.s += " ? ";
+        node.then.accept(self);
+        self.s += " : ";
+        node.otherwise.accept(self);
     }
 
     fn visit_binary(&mut self, node: &super::BinaryExpr) {
@@ -210,6 +384,10 @@ impl Visitor<()> for Printer {
 
     fn visit_if(&mut self, node: &super::IfNode) {
         self.s += "if ";
+        if let Some(init) = &node.init {
+            init.accept(self);
+            self.s += "; ";
+        }
         node.expr.accept(self);
         self.s += " ";
         self.visit_block(&node.block);
@@ -227,9 +405,16 @@ impl Visitor<()> for Printer {
     }
 
     fn visit_while(&mut self, node: &super::WhileNode) {
-        self.s += "while ";
-        node.expr.accept(self);
-        self.s += " ";
+        // The condition of for { } is synthetic and not printed
+        self.s += if node.kw.kind == TokenKind::For {
+            "for "
+        } else {
+            "while "
+        };
+        if !matches!(&node.expr, Expr::Literal(cond) if cond.length == 0) {
+            node.expr.accept(self);
+            self.s += " ";
+        }
         self.visit_block(&node.block);
     }
 
@@ -260,10 +445,43 @@ impl Visitor<()> for Printer {
             self.s += "unique "
         }
         self.s += "type ";
-        self.s += &format!("{} ", node.name);
+        self.token(&node.name);
+        self.s += if node.eq.is_some() { " = " } else { " " };
         self.visit_type(&node.ty);
     }
 
+    fn visit_const_decl(&mut self, node: &super::ConstDeclNode) {
+        if node.public {
+            self.s += "pub "
+        }
+        self.token(&node.name);
+        self.s.push(' ');
+        if let Some(ty) = &node.ty {
+            self.visit_type(ty);
+            self.s.push(' ');
+        }
+        self.s += &format!("{} ", node.symbol);
+        node.expr.accept(self);
+        self.s += "\n";
+    }
+
+    fn visit_struct_decl(&mut self, node: &super::StructDeclNode) {
+        if node.public {
+            self.s += "pub "
+        }
+        self.s += "struct ";
+        self.token(&node.name);
+        self.s += " {\n";
+        for field in &node.fields {
+            self.s += "    ";
+            self.token(&field.name);
+            self.s.push(' ');
+            self.visit_type(&field.typ);
+            self.s += "\n";
+        }
+        self.s += "}\n";
+    }
+
     fn visit_cast(&mut self, node: &super::CastExpr) {
         node.expr.accept(self);
         self.s += " as ";
@@ -41,23 +41,60 @@ fn next_id() -> usize {
 impl Token {
     /// Create new Token. Sets token flags based on kind.
     pub fn new(kind: TokenKind, length: usize, pos: Pos) -> Token {
-        let end_pos = Pos {
-            source_id: pos.source_id,
-            row: pos.row,
-            col: pos.col + length,
-            offset: pos.offset + length,
-            line_begin: pos.line_begin,
-        };
-
         Token {
             id: next_id(),
             length,
             eof: kind.eq(&TokenKind::Eof),
             invalid: kind.eq(&TokenKind::Invalid),
-            pos,
-            end_pos,
+            pos: Pos::default(),
+            end_pos: Pos::default(),
             kind,
         }
+        .with_pos(pos)
+    }
+
+    /// Create a token that does not appear in the source, eg. one inserted
+    /// when desugaring. It has no length and a default position until one
+    /// is given with with_pos. Like any other token it gets a unique id.
+    pub fn synthetic(kind: TokenKind) -> Token {
+        Token::new(kind, 0, Pos::default())
+    }
+
+    /// Move the token to the given position, keeping its length.
+    pub fn with_pos(mut self, pos: Pos) -> Token {
+        self.end_pos = Pos {
+            source_id: pos.source_id,
+            row: pos.row,
+            col: pos.col + self.length,
+            offset: pos.offset + self.length,
+            line_begin: pos.line_begin,
+        };
+        self.pos = pos;
+        self
+    }
+
+    /// Reports whether both tokens have the same kind, length, and flags,
+    /// regardless of where they are in the source.
+    pub fn eq_ignore_pos(&self, other: &Token) -> bool {
+        self.kind == other.kind
+            && self.length == other.length
+            && self.eof == other.eof
+            && self.invalid == other.invalid
+    }
+
+    /// Same as eq_ignore_pos but also compares the start and end positions.
+    /// The id is never compared as every token gets a unique one.
+    pub fn eq_with_pos(&self, other: &Token) -> bool {
+        self.eq_ignore_pos(other) && self.pos == other.pos && self.end_pos == other.end_pos
+    }
+
+    /// The token as it is written in source. Unlike Display, an identifier
+    /// which is a reserved word keeps its '#' prefix, eg. '#type'.
+    pub fn to_source(&self) -> String {
+        match &self.kind {
+            TokenKind::IdentLit(name) if str_to_token(name).is_some() => format!("#{}", name),
+            kind => kind.to_string(),
+        }
     }
 }
 
@@ -78,6 +115,28 @@ pub fn display_tokens(tokens: &[Token]) -> String {
     )
 }
 
+/// Render tokens back to rough source text for debugging. Tokens on a line
+/// are separated by a single space and newline tokens start a new line, so
+/// the original formatting is not kept.
+pub fn render_tokens(tokens: &[Token]) -> String {
+    let mut out = String::new();
+
+    for token in tokens {
+        match token.kind {
+            TokenKind::Newline => out.push('\n'),
+            TokenKind::Eof => {}
+            _ => {
+                if !out.is_empty() && !out.ends_with('\n') {
+                    out.push(' ');
+                }
+                out.push_str(&token.to_source());
+            }
+        }
+    }
+
+    out
+}
+
 #[derive(Debug, Clone, PartialEq)]
 pub enum TokenKind {
     Invalid,
@@ -85,8 +144,10 @@ pub enum TokenKind {
     Newline,
     Eof,
 
-    LineComment,
-    BlockComment,
+    // Comments are only emitted when scanning with comments, and contain
+    // the full comment text including delimiters
+    LineComment(String),
+    BlockComment(String),
 
     // Literals, contain the literal value
     IdentLit(String),
@@ -114,6 +175,9 @@ pub enum TokenKind {
     Continue,
     Type,
     Unique,
+    Struct,
+    Package,
+    Var,
 
     // Math
     Plus,
@@ -131,10 +195,13 @@ pub enum TokenKind {
     MinusEq,
     StarEq,
     SlashEq,
+    PercentEq,
     Greater,
     Less,
     GreaterEq,
     LessEq,
+    Shl,
+    Shr,
     Or,
     OrOr,
     And,
@@ -157,6 +224,7 @@ pub enum TokenKind {
     ColonColon,
     Question,
     At,
+    FatArrow,
 }
 
 /// Reserved token lexemes
@@ -180,6 +248,9 @@ static RESERVED: &[(&str, TokenKind)] = &[
     ("continue", TokenKind::Continue),
     ("type", TokenKind::Type),
     ("unique", TokenKind::Unique),
+    ("struct", TokenKind::Struct),
+    ("package", TokenKind::Package),
+    ("var", TokenKind::Var),
     // Math
     ("+", TokenKind::Plus),
     ("-", TokenKind::Minus),
@@ -195,10 +266,13 @@ static RESERVED: &[(&str, TokenKind)] = &[
     ("-=", TokenKind::MinusEq),
     ("*=", TokenKind::StarEq),
     ("/=", TokenKind::SlashEq),
+    ("%=", TokenKind::PercentEq),
     (">", TokenKind::Greater),
     ("<", TokenKind::Less),
     (">=", TokenKind::GreaterEq),
     ("<=", TokenKind::LessEq),
+    ("<<", TokenKind::Shl),
+    (">>", TokenKind::Shr),
     ("|", TokenKind::Or),
     ("||", TokenKind::OrOr),
     ("&", TokenKind::And),
@@ -219,8 +293,33 @@ static RESERVED: &[(&str, TokenKind)] = &[
     ("::", TokenKind::ColonColon),
     ("?", TokenKind::Question),
     ("@", TokenKind::At),
+    ("=>", TokenKind::FatArrow),
 ];
 
+/// Escape a decoded string literal value so it can be written back as source,
+/// or as a C or assembler string literal. A null byte followed by an octal
+/// digit, and other control characters, use the full three digit octal form
+/// so the following digit is not read as part of the escape.
+pub fn escape_string(s: &str) -> String {
+    let mut escaped = String::with_capacity(s.len());
+    let mut chars = s.chars().peekable();
+    while let Some(ch) = chars.next() {
+        match ch {
+            '\\' => escaped.push_str("\\\\"),
+            '"' => escaped.push_str("\\\""),
+            '\n' => escaped.push_str("\\n"),
+            '\r' => escaped.push_str("\\r"),
+            '\t' => escaped.push_str("\\t"),
+            '\0' if !chars.peek().is_some_and(|c| ('0'..='7').contains(c)) => {
+                escaped.push_str("\\0")
+            }
+            c if c.is_ascii_control() => escaped.push_str(&format!("\\{:03o}", c as u8)),
+            c => escaped.push(c),
+        }
+    }
+    escaped
+}
+
 pub fn str_to_token(s: &str) -> Option<&TokenKind> {
     RESERVED.iter().find(|(kw, _)| *kw == s).map(|(_, t)| t)
 }
@@ -245,9 +344,11 @@ impl fmt::Display for TokenKind {
             TokenKind::IdentLit(ident) => ident,
             TokenKind::IntLit(n) => &n.to_string(),
             TokenKind::FloatLit(f) => &f.to_string(),
-            TokenKind::StringLit(s) => &format!("\"{}\"", s),
+            TokenKind::StringLit(s) => &format!("\"{}\"", escape_string(s)),
             TokenKind::CharLit(c) => &c.to_string(),
 
+            TokenKind::LineComment(text) | TokenKind::BlockComment(text) => text,
+
             k => token_to_str(k.clone()).expect("kind was not found in RESERVED map"),
         };
 
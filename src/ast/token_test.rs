@@ -0,0 +1,131 @@
+use crate::{
+    ast::{Token, TokenKind, escape_string, render_tokens},
+    common::{Pos, must, scan_string},
+};
+
+fn pos(row: usize, col: usize) -> Pos {
+    Pos {
+        row,
+        col,
+        offset: col,
+        ..Default::default()
+    }
+}
+
+fn ident(name: &str, pos: Pos) -> Token {
+    Token::new(TokenKind::IdentLit(name.to_string()), name.len(), pos)
+}
+
+#[test]
+fn test_eq_ignore_pos_matching() {
+    let a = ident("foo", pos(0, 0));
+    let b = ident("foo", pos(3, 7));
+    assert!(a.eq_ignore_pos(&b));
+    assert!(!a.eq_with_pos(&b));
+}
+
+#[test]
+fn test_eq_ignore_pos_differing_kind() {
+    let a = ident("foo", pos(0, 0));
+    let b = ident("bar", pos(0, 0));
+    assert!(!a.eq_ignore_pos(&b));
+}
+
+#[test]
+fn test_eq_ignore_pos_differing_length() {
+    let a = Token::new(TokenKind::IntLit(1), 1, pos(0, 0));
+    let b = Token::new(TokenKind::IntLit(1), 3, pos(0, 0));
+    assert!(!a.eq_ignore_pos(&b));
+}
+
+#[test]
+fn test_eq_with_pos_ignores_id() {
+    let a = ident("foo", pos(1, 2));
+    let b = ident("foo", pos(1, 2));
+    assert_ne!(a.id, b.id);
+    assert!(a.eq_with_pos(&b));
+}
+
+#[test]
+fn test_eq_with_pos_differing_end() {
+    let a = ident("foo", pos(1, 2));
+    let mut b = ident("foo", pos(1, 2));
+    b.end_pos.row = 2;
+    assert!(!a.eq_with_pos(&b));
+}
+
+#[test]
+fn test_synthetic_token() {
+    let tok = Token::synthetic(TokenKind::Return);
+    assert_eq!(tok.kind, TokenKind::Return);
+    assert_eq!(tok.length, 0);
+    assert_eq!(tok.pos, Pos::default());
+    assert_eq!(tok.end_pos, Pos::default());
+    assert!(!tok.eof);
+    assert!(!tok.invalid);
+}
+
+#[test]
+fn test_synthetic_tokens_have_unique_ids() {
+    let a = Token::synthetic(TokenKind::LBrace);
+    let b = Token::synthetic(TokenKind::LBrace);
+    assert_ne!(a.id, b.id);
+    assert!(a.eq_with_pos(&b));
+}
+
+#[test]
+fn test_synthetic_eof_flag() {
+    assert!(Token::synthetic(TokenKind::Eof).eof);
+    assert!(Token::synthetic(TokenKind::Invalid).invalid);
+}
+
+#[test]
+fn test_with_pos_keeps_length() {
+    let tok = ident("foo", pos(0, 0)).with_pos(pos(2, 5));
+    assert_eq!(tok.length, 3);
+    assert_eq!(tok.pos, pos(2, 5));
+    assert_eq!(tok.end_pos.row, 2);
+    assert_eq!(tok.end_pos.col, 8);
+    assert_eq!(tok.end_pos.offset, 8);
+}
+
+#[test]
+fn test_with_pos_keeps_id() {
+    let tok = Token::synthetic(TokenKind::Return);
+    let id = tok.id;
+    let moved = tok.with_pos(pos(4, 1));
+    assert_eq!(moved.id, id);
+    assert_eq!(moved.pos, pos(4, 1));
+    assert_eq!(moved.end_pos, pos(4, 1));
+}
+
+#[test]
+fn test_render_tokens() {
+    let src = "func  add(a int,b int) int {\n\treturn a+b\n}\n";
+    let rendered = render_tokens(&must(scan_string(src)));
+
+    assert_eq!(
+        rendered,
+        "func add ( a int , b int ) int {\nreturn a + b\n}\n"
+    );
+}
+
+#[test]
+fn test_render_tokens_escaped_identifier() {
+    let rendered = render_tokens(&must(scan_string("#type := #func")));
+    assert_eq!(rendered, "#type := #func");
+}
+
+#[test]
+fn test_render_tokens_string_literal() {
+    let rendered = render_tokens(&must(scan_string("s := \"a\\nb\"")));
+    assert_eq!(rendered, "s := \"a\\nb\"");
+}
+
+#[test]
+fn test_escape_string() {
+    assert_eq!(escape_string("a\"b\\c\n"), "a\\\"b\\\\c\\n");
+    assert_eq!(escape_string("a\0b"), "a\\0b");
+    assert_eq!(escape_string("a\x001"), "a\\0001");
+    assert_eq!(escape_string("\x07"), "\\007");
+}
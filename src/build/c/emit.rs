@@ -11,6 +11,24 @@ pub fn emit(unit: Unit, _config: &Config, pm: &PathManager) -> Ast {
 
     decls.push(Decl::Include(pm.include_path().join("koi.h").to_string()));
 
+    for (i, data) in unit.data.iter().enumerate() {
+        decls.push(match data {
+            ir::Data::String(s) => Decl::String {
+                label: to_data_label(i),
+                content: s.as_bytes().to_vec(),
+            },
+        });
+    }
+
+    for global in &unit.globals {
+        decls.push(Decl::Global {
+            public: global.public,
+            name: global.name.clone(),
+            ty: ctype(&unit.types, global.ty),
+            value: global.value.as_ref().map(const_to_expr),
+        });
+    }
+
     for decl in unit.decls {
         let decl = match decl {
             crate::ir::Decl::Extern(ext) => Decl::ExternFunc {
@@ -22,7 +40,7 @@ pub fn emit(unit: Unit, _config: &Config, pm: &PathManager) -> Ast {
                     .collect(),
                 ret: ctype(&unit.types, ext.ret),
             },
-            crate::ir::Decl::Func(func) => FuncEmitter::new(func, &unit.types, &unit.data).emit(),
+            crate::ir::Decl::Func(func) => FuncEmitter::new(func, &unit.types).emit(),
         };
 
         decls.push(decl);
@@ -31,6 +49,21 @@ pub fn emit(unit: Unit, _config: &Config, pm: &PathManager) -> Ast {
     Ast { decls }
 }
 
+/// Convert the constant value of a global to a C expression.
+fn const_to_expr(rval: &ir::RValue) -> Expr {
+    match rval {
+        ir::RValue::Int(n) => Expr::IntLit(*n),
+        ir::RValue::Float(n) => Expr::FloatLit(*n),
+        ir::RValue::Uint(n) => Expr::UintLit(*n),
+        ir::RValue::Data(idx) => Expr::DataLit(to_data_label(*idx)),
+        _ => panic!("global value must be constant"),
+    }
+}
+
+fn to_data_label(idx: usize) -> String {
+    format!("_data{}", idx)
+}
+
 fn ctype(types: &IRTypeInterner, typeid: IRTypeId) -> Type {
     match types.get(typeid) {
         ir::IRType::Primitive(primitive) => primitive.into(),
@@ -41,7 +74,6 @@ fn ctype(types: &IRTypeInterner, typeid: IRTypeId) -> Type {
 struct FuncEmitter<'a> {
     decl: ir::FuncDecl,
     types: &'a IRTypeInterner,
-    data: &'a [ir::Data],
     param_count: usize,
     stmts: Vec<Stmt>,
     /// Remaps a branch-local ConstId to the canonical ConstId from the first
@@ -59,13 +91,12 @@ struct FuncEmitter<'a> {
 }
 
 impl<'a> FuncEmitter<'a> {
-    fn new(decl: ir::FuncDecl, types: &'a IRTypeInterner, data: &'a [ir::Data]) -> Self {
+    fn new(decl: ir::FuncDecl, types: &'a IRTypeInterner) -> Self {
         Self {
             param_count: decl.params.len(),
             stmts: Vec::new(),
             decl,
             types,
-            data,
             var_remap: HashMap::new(),
             predeclared: HashMap::new(),
             continue_target: Vec::new(),
@@ -360,9 +391,8 @@ impl<'a> FuncEmitter<'a> {
             ir::RValue::Int(n) => Expr::IntLit(*n),
             ir::RValue::Float(n) => Expr::FloatLit(*n),
             ir::RValue::Uint(n) => Expr::UintLit(*n),
-            ir::RValue::Data(idx) => match &self.data[*idx] {
-                ir::Data::String(s) => Expr::StrLit(s.clone()),
-            },
+            ir::RValue::Data(idx) => Expr::DataLit(to_data_label(*idx)),
+            ir::RValue::Global(name) => Expr::GlobalLit(name.clone()),
             ir::RValue::Function(_) => todo!(),
             ir::RValue::Void => panic!("void value should always be checked"),
         }
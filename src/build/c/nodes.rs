@@ -20,6 +20,20 @@ impl Display for Ast {
 
 pub enum Decl {
     Include(String),
+    /// Constant global, extern if there is no value
+    Global {
+        public: bool,
+        name: String,
+        ty: Type,
+        value: Option<Expr>,
+    },
+    /// String data stored as bytes with its length just before them, so an
+    /// embedded null does not end the string. The bytes are still null
+    /// terminated for C functions taking a string.
+    String {
+        label: String,
+        content: Vec<u8>,
+    },
     ExternFunc {
         name: String,
         params: Vec<Type>,
@@ -75,7 +89,9 @@ pub enum Expr {
     FloatLit(f64),
     UintLit(u64),
     VarLit(usize),
-    StrLit(String),
+    /// Pointer to the bytes of a string data declaration.
+    DataLit(String),
+    GlobalLit(String),
     Not(Box<Expr>),
     Cast(Type, Box<Expr>),
 }
@@ -250,20 +266,8 @@ impl Display for Expr {
             Expr::FloatLit(i) => write!(f, "{i}"),
             Expr::UintLit(u) => write!(f, "{u}"),
             Expr::VarLit(id) => write!(f, "t{id}"),
-            Expr::StrLit(s) => {
-                write!(f, "\"")?;
-                for ch in s.chars() {
-                    match ch {
-                        '\\' => write!(f, "\\\\")?,
-                        '"' => write!(f, "\\\"")?,
-                        '\n' => write!(f, "\\n")?,
-                        '\r' => write!(f, "\\r")?,
-                        '\t' => write!(f, "\\t")?,
-                        c => write!(f, "{c}")?,
-                    }
-                }
-                write!(f, "\"")
-            }
+            Expr::DataLit(label) => write!(f, "(uint8_t*){label}.bytes"),
+            Expr::GlobalLit(name) => write!(f, "{name}"),
             Expr::Not(inner) => write!(f, "!{inner}"),
             Expr::Cast(ty, inner) => write!(f, "({ty})({inner})"),
         }
@@ -301,6 +305,28 @@ impl Display for Decl {
                     .collect::<Vec<_>>()
                     .join(", ")
             ),
+            Decl::Global {
+                public,
+                name,
+                ty,
+                value,
+            } => match value {
+                Some(value) if *public => write!(f, "{ty} const {name} = {value};"),
+                Some(value) => write!(f, "static {ty} const {name} = {value};"),
+                None => write!(f, "extern {ty} const {name};"),
+            },
+            Decl::String { label, content } => write!(
+                f,
+                "static const struct {{ uint64_t length; uint8_t bytes[{}]; }} {label} = {{ {}, {{ {} }} }};",
+                content.len() + 1,
+                content.len(),
+                content
+                    .iter()
+                    .chain([&0])
+                    .map(|b| b.to_string())
+                    .collect::<Vec<_>>()
+                    .join(", ")
+            ),
             Decl::Include(path) => write!(f, "#include \"{path}\""),
         }
     }
@@ -61,8 +61,30 @@ func f() string {
         r#"
 #include "include/koi.h"
 
+static const struct { uint64_t length; uint8_t bytes[6]; } _data0 = { 5, { 72, 101, 108, 108, 111, 0 } };
+
+uint8_t* f() {
+    return (uint8_t*)_data0.bytes;
+}
+        "#,
+    );
+}
+
+#[test]
+fn test_string_with_null_byte() {
+    compare(
+        r#"
+func f() string {
+    return "a\x00b"
+}
+        "#,
+        r#"
+#include "include/koi.h"
+
+static const struct { uint64_t length; uint8_t bytes[4]; } _data0 = { 3, { 97, 0, 98, 0 } };
+
 uint8_t* f() {
-    return "Hello";
+    return (uint8_t*)_data0.bytes;
 }
         "#,
     );
@@ -80,8 +102,10 @@ func f(a int, b string) int {
         r#"
 #include "include/koi.h"
 
+static const struct { uint64_t length; uint8_t bytes[6]; } _data0 = { 5, { 72, 101, 108, 108, 111, 0 } };
+
 int32_t f(int32_t t0, uint8_t* t1) {
-    int32_t t2 = f(1, "Hello");
+    int32_t t2 = f(1, (uint8_t*)_data0.bytes);
     int32_t t3 = t2;
     return t3;
 }
@@ -128,9 +152,13 @@ func f() {
         r#"
 #include "include/koi.h"
 
+static const struct { uint64_t length; uint8_t bytes[6]; } _data0 = { 5, { 72, 101, 108, 108, 111, 0 } };
+
+static const struct { uint64_t length; uint8_t bytes[6]; } _data1 = { 5, { 87, 111, 114, 108, 100, 0 } };
+
 void f() {
-    uint8_t* t0 = "Hello";
-    t0 = "World";
+    uint8_t* t0 = (uint8_t*)_data0.bytes;
+    t0 = (uint8_t*)_data1.bytes;
     uint8_t* t1 = t0;
     t0 = t1;
     return ;
@@ -2,15 +2,16 @@ use std::{collections::HashMap, mem::take};
 
 use crate::{
     build::x86::{
-        Asm, Condition, DataDecl, Dest, File, Immediate, Label, Reg, Size, Src, StackOffset,
-        TextDecl, UnsignedReg,
+        Asm, Condition, DataDecl, Dest, File, GlobalRef, Immediate, Label, Reg, Size, Src,
+        StackOffset, TextDecl, UnsignedReg,
     },
     config::Config,
     ir::{
         AssignIns, BinaryIns, Block, CallIns, CondIns, ConstId, Data, Decl, ExternDecl, FuncDecl,
         IRBinaryOp, IRCondOp, IRType, IRTypeId, IRUnaryOp, IfIns, Ins, LValue, Primitive, RValue,
-        StoreIns, UnaryIns, Unit, WhileIns, ins_to_string_oneline,
+        StoreIns, UnaryIns, Unit, WhileIns, align_to, ins_to_string_oneline,
     },
+    types::PTR_SIZE,
 };
 
 pub fn assemble(unit: Unit, config: &Config) -> File {
@@ -55,12 +56,44 @@ impl<'a> Assembler<'a> {
                 Data::String(s) => DataDecl::String {
                     label: to_data_label(i),
                     content: s.clone(),
+                    length: s.len(),
                 },
             };
 
             self.data.push(data_decl);
         }
 
+        // Declare globals, or reference them if declared elsewhere. Taken
+        // last as functions look up global sizes while assembling.
+        for global in take(&mut self.unit.globals) {
+            match &global.value {
+                Some(value) => {
+                    let size = type_size(&self.unit, &global.ty);
+                    let value = match value {
+                        RValue::Int(n) => n.to_string(),
+                        RValue::Uint(n) => n.to_string(),
+                        RValue::Float(n) => match size {
+                            Size::Dword => (*n as f32).to_bits().to_string(),
+                            _ => n.to_bits().to_string(),
+                        },
+                        RValue::Data(idx) => Label {
+                            name: to_data_label(*idx),
+                        }
+                        .to_string(),
+                        _ => panic!("global value must be constant"),
+                    };
+
+                    self.data.push(DataDecl::Global {
+                        global: global.public,
+                        name: global.name,
+                        size,
+                        value,
+                    });
+                }
+                None => self.text.push(TextDecl::Extern(global.name)),
+            }
+        }
+
         File {
             data_section: self.data,
             text_section: self.text,
@@ -141,11 +174,14 @@ impl<'a> FunctionAssembler<'a> {
             .map(|ty| regs.next(self.unit, ty))
             .collect::<Vec<Reg>>();
 
-        let param_stack_size = self
-            .decl
-            .params
-            .iter()
-            .fold(0_usize, |acc, ty| acc + self.sizeof(ty));
+        let param_stack_size = self.decl.params.iter().fold(0_usize, |acc, ty| {
+            align_to(acc + self.sizeof(ty), self.alignof(ty))
+        });
+
+        // Locals are laid out after the parameters with the same alignment
+        // rules as in lowering, so starting them on a word boundary keeps the
+        // precomputed stack size large enough.
+        let param_stack_size = align_to(param_stack_size, PTR_SIZE);
 
         // sub rsp, [x]
         let stacksize = round_to_16(self.decl.stacksize + param_stack_size);
@@ -296,7 +332,10 @@ impl<'a> FunctionAssembler<'a> {
         let base_offset = self.cur_stack_offset();
         self.emit_block(&ifins.block);
 
-        self.push(Asm::Jmp(end.clone()));
+        // Without other branches the end label follows directly, so no jump is needed
+        if has_branches {
+            self.push(Asm::Jmp(end.clone()));
+        }
 
         // Emit else-if branches; each one consumes next_label and allocates the
         // label for the branch that follows it
@@ -316,7 +355,9 @@ impl<'a> FunctionAssembler<'a> {
 
             self.set_stack_offset(base_offset);
             self.emit_block(&elseif.block);
-            self.push(Asm::Jmp(end.clone()));
+            if next_label.is_some() {
+                self.push(Asm::Jmp(end.clone()));
+            }
         }
 
         if let Some(elseblock) = &ifins.elseblock {
@@ -553,6 +594,13 @@ impl<'a> FunctionAssembler<'a> {
             RValue::Data(idx) => Src::Label(Label {
                 name: to_data_label(*idx),
             }),
+            RValue::Global(name) => {
+                let global = self.unit.globals.iter().find(|g| &g.name == name);
+                Src::Global(GlobalRef {
+                    name: name.clone(),
+                    size: self.type_size(&global.expect("global not declared").ty),
+                })
+            }
 
             RValue::Void => todo!(),
             RValue::Function(_) => todo!(),
@@ -571,7 +619,7 @@ impl<'a> FunctionAssembler<'a> {
     fn src_to_movable(&mut self, src: Src, ty: &IRTypeId) -> Src {
         match src {
             Src::Reg(_) | Src::Immediate(_) => src,
-            Src::StackOffset(_) | Src::Label(_) => {
+            Src::StackOffset(_) | Src::Label(_) | Src::Global(_) => {
                 let rax = self.rax(ty);
                 self.mov_or_lea(Dest::Reg(rax.clone()), src);
                 Src::Reg(rax)
@@ -581,7 +629,9 @@ impl<'a> FunctionAssembler<'a> {
 
     /// Allocate new stack slot for variable
     fn new_stack_offset(&mut self, ty: &IRTypeId) -> Dest {
-        self.acc_offset += self.sizeof(ty);
+        // Offsets are subtracted from rbp, which is always 16 byte aligned,
+        // so an aligned offset gives an aligned address.
+        self.acc_offset = align_to(self.acc_offset + self.sizeof(ty), self.alignof(ty));
 
         Dest::StackOffset(StackOffset {
             offset: self.acc_offset,
@@ -607,6 +657,10 @@ impl<'a> FunctionAssembler<'a> {
         self.unit.types.sizeof(*ty)
     }
 
+    fn alignof(&self, ty: &IRTypeId) -> usize {
+        self.unit.types.alignof(*ty)
+    }
+
     /// Shorthand for getting correctly sized RAX register
     fn rax(&self, ty: &IRTypeId) -> Reg {
         UnsignedReg::Rax.to_sized(self.type_size(ty))
@@ -715,6 +769,7 @@ fn src_size(src: &Src) -> Size {
     match src {
         Src::Reg(reg) => reg_to_size(reg),
         Src::StackOffset(off) => off.size.clone(),
+        Src::Global(global) => global.size.clone(),
         Src::Immediate(_) | Src::Label(_) => Size::Qword,
     }
 }
@@ -6,7 +6,18 @@ pub struct File {
 }
 
 pub enum DataDecl {
-    String { label: String, content: String },
+    String {
+        label: String,
+        content: String,
+        /// Length in bytes, excluding the terminating null.
+        length: usize,
+    },
+    Global {
+        global: bool,
+        name: String,
+        size: Size,
+        value: String,
+    },
 }
 
 pub enum TextDecl {
@@ -64,6 +75,7 @@ pub enum Src {
     Reg(Reg),
     StackOffset(StackOffset),
     Label(Label),
+    Global(GlobalRef),
 }
 
 impl From<&Dest> for Src {
@@ -215,6 +227,12 @@ pub struct Label {
     pub name: String,
 }
 
+/// Sized memory reference to a global symbol
+pub struct GlobalRef {
+    pub name: String,
+    pub size: Size,
+}
+
 impl Display for File {
     fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
         writeln!(f, ".intel_syntax noprefix")?;
@@ -237,8 +255,43 @@ impl Display for File {
 impl Display for DataDecl {
     fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
         match self {
-            DataDecl::String { label, content } => {
-                write!(f, ".{}: .asciz \"{}\"", label, content)
+            DataDecl::String {
+                label,
+                content,
+                length,
+            } => {
+                // The length is stored in the quad just before the label, so
+                // it can be read at [label-8] without scanning for a null.
+                writeln!(f, ".balign 8")?;
+                writeln!(f, ".quad {}", length)?;
+
+                // Written as explicit bytes so embedded null bytes are kept,
+                // followed by a terminating null for C interop.
+                let bytes = content
+                    .bytes()
+                    .chain([0])
+                    .map(|b| b.to_string())
+                    .collect::<Vec<_>>()
+                    .join(", ");
+                write!(f, ".{}: .byte {}", label, bytes)
+            }
+            DataDecl::Global {
+                global,
+                name,
+                size,
+                value,
+            } => {
+                if *global {
+                    writeln!(f, ".globl {}", name)?;
+                }
+                let (align, directive) = match size {
+                    Size::Byte => (1, ".byte"),
+                    Size::Word => (2, ".short"),
+                    Size::Dword => (4, ".long"),
+                    Size::Qword => (8, ".quad"),
+                };
+                writeln!(f, ".balign {}", align)?;
+                write!(f, "{}: {} {}", name, directive, value)
             }
         }
     }
@@ -330,6 +383,7 @@ impl Display for Src {
             Src::Reg(reg) => write!(f, "{}", reg),
             Src::StackOffset(stack) => write!(f, "{}", stack),
             Src::Label(label) => write!(f, "[rip + {}]", label),
+            Src::Global(global) => write!(f, "{} PTR [rip + {}]", global.size, global.name),
             Src::Immediate(imm) => write!(f, "{}", imm),
         }
     }
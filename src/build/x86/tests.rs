@@ -40,6 +40,42 @@ main:
     );
 }
 
+#[test]
+fn test_void_return() {
+    let expect = r#"
+.intel_syntax noprefix
+.section .data
+
+.section .text
+
+f:
+    push rbp
+    mov rbp, rsp
+    leave
+    ret
+
+.section .note.GNU-stack,"",@progbits
+        "#;
+
+    compare(
+        r#"
+func f() {
+    return
+}
+        "#,
+        expect,
+    );
+
+    // Falling off the end of a void function returns the same way
+    compare(
+        r#"
+func f() {
+}
+        "#,
+        expect,
+    );
+}
+
 #[test]
 fn test_string_return() {
     compare(
@@ -52,7 +88,9 @@ func f() string {
 .intel_syntax noprefix
 .section .data
 
-.D0: .asciz "Hello"
+.balign 8
+.quad 5
+.D0: .byte 72, 101, 108, 108, 111, 0
 
 .section .text
 
@@ -81,20 +119,22 @@ func f(a int, b string) int {
 .intel_syntax noprefix
 .section .data
 
-.D0: .asciz "Hello"
+.balign 8
+.quad 5
+.D0: .byte 72, 101, 108, 108, 111, 0
 .section .text
 
 f:
     push rbp
     mov rbp, rsp
-    sub rsp, 16
+    sub rsp, 32
     mov DWORD PTR [rbp-4], edi
-    mov QWORD PTR [rbp-12], rsi
+    mov QWORD PTR [rbp-16], rsi
     mov edi, 1
     lea rsi, [rip + .D0]
     call f
-    mov DWORD PTR [rbp-16], eax
-    mov eax, DWORD PTR [rbp-16]
+    mov DWORD PTR [rbp-20], eax
+    mov eax, DWORD PTR [rbp-20]
     leave
     ret
 
@@ -126,8 +166,8 @@ f:
     sub rsp, 16
     mov BYTE PTR [rbp-1], 1
     mov BYTE PTR [rbp-1], 0
-    mov DWORD PTR [rbp-5], 1
-    mov DWORD PTR [rbp-5], 0
+    mov DWORD PTR [rbp-8], 1
+    mov DWORD PTR [rbp-8], 0
     leave
     ret
 
@@ -151,8 +191,12 @@ func f() {
 .intel_syntax noprefix
 .section .data
 
-.D0: .asciz "Hello"
-.D1: .asciz "World"
+.balign 8
+.quad 5
+.D0: .byte 72, 101, 108, 108, 111, 0
+.balign 8
+.quad 5
+.D1: .byte 87, 111, 114, 108, 100, 0
 .section .text
 
 f:
@@ -175,6 +219,68 @@ f:
     );
 }
 
+#[test]
+fn test_string_escapes() {
+    compare(
+        r#"
+func f() {
+    s := "a\tb\n\"c\"\0"
+}
+        "#,
+        r#"
+.intel_syntax noprefix
+.section .data
+
+.balign 8
+.quad 8
+.D0: .byte 97, 9, 98, 10, 34, 99, 34, 0, 0
+.section .text
+
+f:
+    push rbp
+    mov rbp, rsp
+    sub rsp, 16
+    lea rax, [rip + .D0]
+    mov QWORD PTR [rbp-8], rax
+    leave
+    ret
+
+.section .note.GNU-stack,"",@progbits
+        "#,
+    );
+}
+
+#[test]
+fn test_hex_byte_escape_keeps_null_byte() {
+    compare(
+        r#"
+func f() {
+    s := "a\x00b"
+}
+        "#,
+        r#"
+.intel_syntax noprefix
+.section .data
+
+.balign 8
+.quad 3
+.D0: .byte 97, 0, 98, 0
+.section .text
+
+f:
+    push rbp
+    mov rbp, rsp
+    sub rsp, 16
+    lea rax, [rip + .D0]
+    mov QWORD PTR [rbp-8], rax
+    leave
+    ret
+
+.section .note.GNU-stack,"",@progbits
+        "#,
+    );
+}
+
 #[test]
 fn test_binary_add() {
     compare(
@@ -517,10 +623,10 @@ f:
     sub rsp, 32
     mov DWORD PTR [rbp-4], edi
     mov BYTE PTR [rbp-5], sil
-    mov QWORD PTR [rbp-13], rdx
+    mov QWORD PTR [rbp-16], rdx
     mov DWORD PTR [rbp-4], 0
     mov BYTE PTR [rbp-5], 0
-    mov DWORD PTR [rbp-17], 123
+    mov DWORD PTR [rbp-20], 123
     mov eax, 0
     leave
     ret
@@ -530,6 +636,43 @@ f:
     );
 }
 
+#[test]
+fn test_if_no_else() {
+    compare(
+        r#"
+func f(a bool) int {
+    n := 0
+    if a {
+        n = 7
+    }
+    return n
+}
+        "#,
+        r#"
+.intel_syntax noprefix
+.section .data
+
+.section .text
+
+f:
+    push rbp
+    mov rbp, rsp
+    sub rsp, 16
+    mov BYTE PTR [rbp-1], dil
+    mov DWORD PTR [rbp-8], 0
+    cmp BYTE PTR [rbp-1], 0
+    jz .Lf_cond_end_0
+    mov DWORD PTR [rbp-8], 7
+    .Lf_cond_end_0:
+    mov eax, DWORD PTR [rbp-8]
+    leave
+    ret
+
+.section .note.GNU-stack,"",@progbits
+        "#,
+    );
+}
+
 #[test]
 fn test_if_else() {
     compare(
@@ -718,7 +861,6 @@ f:
     jz .Lf_cond_end_0
     leave
     ret
-    jmp .Lf_cond_end_0
     .Lf_cond_end_0:
     leave
     ret
@@ -1066,6 +1208,59 @@ f:
     );
 }
 
+#[test]
+fn test_for_counting() {
+    compare(
+        r#"
+func f(n int) int {
+    sum := 0
+    for i := 0; i < n; i += 1 {
+        sum += i
+    }
+    return sum
+}
+        "#,
+        r#"
+.intel_syntax noprefix
+.section .data
+
+.section .text
+
+f:
+    push rbp
+    mov rbp, rsp
+    sub rsp, 16
+    mov DWORD PTR [rbp-4], edi
+    mov DWORD PTR [rbp-8], 0
+    mov DWORD PTR [rbp-12], 0
+    jmp .Lf_loop_1
+    .Lf_loop_0:
+    mov r10d, 1
+    mov eax, DWORD PTR [rbp-12]
+    add eax, r10d
+    mov DWORD PTR [rbp-12], eax
+    .Lf_loop_1:
+    mov r10d, DWORD PTR [rbp-4]
+    mov eax, DWORD PTR [rbp-12]
+    cmp eax, r10d
+    setl al
+    cmp al, 0
+    jz .Lf_loop_end_0
+    mov r10d, DWORD PTR [rbp-12]
+    mov eax, DWORD PTR [rbp-8]
+    add eax, r10d
+    mov DWORD PTR [rbp-8], eax
+    jmp .Lf_loop_0
+    .Lf_loop_end_0:
+    mov eax, DWORD PTR [rbp-8]
+    leave
+    ret
+
+.section .note.GNU-stack,"",@progbits
+        "#,
+    );
+}
+
 #[test]
 fn test_while_nested() {
     // Each while claims its own cond/end label pair via next_cond_label /
@@ -1109,3 +1304,43 @@ f:
         "#,
     );
 }
+
+#[test]
+fn test_return_bool_literals() {
+    compare(
+        r#"
+func f() bool {
+    return true
+}
+
+func g() bool {
+    a := false
+    return a
+}
+        "#,
+        r#"
+.intel_syntax noprefix
+.section .data
+
+.section .text
+
+f:
+    push rbp
+    mov rbp, rsp
+    mov al, 1
+    leave
+    ret
+
+g:
+    push rbp
+    mov rbp, rsp
+    sub rsp, 16
+    mov BYTE PTR [rbp-1], 0
+    mov al, BYTE PTR [rbp-1]
+    leave
+    ret
+
+.section .note.GNU-stack,"",@progbits
+        "#,
+    );
+}
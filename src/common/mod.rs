@@ -10,3 +10,9 @@ pub use vartable::VarTable;
 
 #[cfg(test)]
 mod source_test;
+
+#[cfg(test)]
+mod testing_test;
+
+#[cfg(test)]
+mod vartable_test;
@@ -1,5 +1,6 @@
 use std::{
     collections::{HashMap, hash_map::Values},
+    fs,
     sync::atomic::{AtomicUsize, Ordering},
 };
 
@@ -103,6 +104,14 @@ impl Source {
         }
     }
 
+    /// Create new source by reading the file at the given path.
+    pub fn from_path(filepath: FilePath) -> Result<Source, String> {
+        match fs::read(filepath.path_buf()) {
+            Err(err) => Err(format!("failed to read file '{}': {}", filepath, err)),
+            Ok(src) => Ok(Self::new(filepath, src)),
+        }
+    }
+
     /// Create new source from string.
     pub fn new_str(filepath: String, src: String) -> Source {
         Self::new(filepath.into(), src.into_bytes())
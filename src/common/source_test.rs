@@ -1,4 +1,4 @@
-use crate::common::new_source;
+use crate::common::{FilePath, Source, new_source};
 
 #[test]
 fn test_file_line_offsets() {
@@ -27,3 +27,20 @@ fn test_line_offset_empty_input() {
     let file = new_source("");
     assert_eq!(vec![0], file.lines);
 }
+
+#[test]
+fn test_source_from_path() {
+    let path = std::env::temp_dir().join("koi_source_from_path.koi");
+    std::fs::write(&path, "func main() {}\n").unwrap();
+
+    let file = Source::from_path(FilePath::from(path.clone())).unwrap();
+    assert_eq!(file.src, b"func main() {}\n");
+
+    std::fs::remove_file(path).unwrap();
+}
+
+#[test]
+fn test_source_from_nonexistent_path() {
+    let path = FilePath::from("does/not/exist.koi");
+    assert!(Source::from_path(path).is_err());
+}
@@ -5,7 +5,7 @@ use crate::{
     common::{Source, SourceMap},
     config::Config,
     context::Context,
-    error::Diagnostics,
+    error::{Diagnostics, Severity},
     ir::Unit,
     lower::emit_ir,
     module::{ImportPath, ModuleId, ModulePath},
@@ -132,6 +132,27 @@ pub fn check_string(ctx: &mut Context, src: &str) -> Result<ModuleId, ErrorStrea
         .map(|create| ctx.modules.add(create))
 }
 
+/// Type check source in a new test context. Panics on error.
+pub fn must_check(src: &str) -> (Context, ModuleId) {
+    let mut ctx = Context::new(Config::test());
+    let id = must(check_string(&mut ctx, src));
+    (ctx, id)
+}
+
+/// Type check source and run a warning pass on it. Returns the messages of the
+/// reported warnings. Panics if the pass reports anything else.
+pub fn check_warnings(src: &str, check: fn(&Context, ModuleId) -> Diagnostics) -> Vec<String> {
+    let (ctx, id) = must_check(src);
+    let diag = check(&ctx, id);
+
+    (0..diag.num_errors())
+        .map(|i| {
+            assert_eq!(diag.get(i).severity(), Severity::Warning);
+            diag.get(i).message.clone()
+        })
+        .collect()
+}
+
 pub fn emit_string(src: &str) -> Result<Unit, ErrorStream> {
     let config = Config::test();
     let mut ctx = Context::new(config);
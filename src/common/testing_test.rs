@@ -0,0 +1,30 @@
+use crate::common::{emit_string, must, must_check, parse_string, scan_string};
+
+#[test]
+fn test_helpers_pass_on_valid_input() {
+    let src = "func main() int {\n    return 0\n}";
+    must(scan_string(src));
+    must(parse_string(src));
+    must(emit_string(src));
+
+    let (ctx, id) = must_check(src);
+    assert!(ctx.modules.get(id).is_main());
+}
+
+#[test]
+#[should_panic(expected = "unexpected error")]
+fn test_must_scan_fails_on_invalid_input() {
+    must(scan_string("func main() { $ }"));
+}
+
+#[test]
+#[should_panic(expected = "unexpected error: expected function name")]
+fn test_must_parse_fails_on_invalid_input() {
+    must(parse_string("func () {}"));
+}
+
+#[test]
+#[should_panic(expected = "unexpected error: not declared")]
+fn test_must_check_fails_on_invalid_input() {
+    must_check("func f() {\n    a = 1\n}");
+}
@@ -39,6 +39,11 @@ impl<T> VarTable<T> {
         self.scopes.last_mut().unwrap().insert(name, t).is_none()
     }
 
+    /// Number of scopes pushed on top of the base scope.
+    pub fn depth(&self) -> usize {
+        self.scopes.len() - 1
+    }
+
     fn cur_scope(&self) -> &HashMap<String, T> {
         self.scopes.last().unwrap() // never empty
     }
@@ -53,6 +58,25 @@ impl<T> VarTable<T> {
         None
     }
 
+    /// Look up a name like get, returning a mutable reference to its value.
+    pub fn get_mut(&mut self, name: &str) -> Option<&mut T> {
+        self.scopes
+            .iter_mut()
+            .rev()
+            .find_map(|scope| scope.get_mut(name))
+    }
+
+    /// Look up a name like get, also reporting whether it was found in the
+    /// current scope (true) or an outer one (false).
+    pub fn resolve(&self, name: &str) -> Option<(&T, bool)> {
+        let depth = self.depth();
+        self.scopes
+            .iter()
+            .enumerate()
+            .rev()
+            .find_map(|(i, scope)| scope.get(name).map(|t| (t, i == depth)))
+    }
+
     /// Clear table
     pub fn clear(&mut self) {
         self.scopes.clear();
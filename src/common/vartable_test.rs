@@ -0,0 +1,47 @@
+use crate::common::VarTable;
+
+#[test]
+fn test_depth() {
+    let mut vars: VarTable<i32> = VarTable::new();
+    assert_eq!(vars.depth(), 0);
+
+    vars.push_scope();
+    vars.push_scope();
+    assert_eq!(vars.depth(), 2);
+
+    vars.pop_scope();
+    assert_eq!(vars.depth(), 1);
+
+    vars.push_scope();
+    vars.clear();
+    assert_eq!(vars.depth(), 0);
+}
+
+#[test]
+fn test_get_from_outer_scope() {
+    let mut vars = VarTable::new();
+    assert!(vars.bind("a".into(), 1));
+
+    vars.push_scope();
+    assert!(vars.bind("a".into(), 2));
+    assert!(!vars.bind("a".into(), 3));
+    assert_eq!(vars.get("a"), Some(&2));
+
+    vars.pop_scope();
+    assert_eq!(vars.get("a"), Some(&1));
+}
+
+#[test]
+fn test_resolve_reports_current_scope() {
+    let mut vars = VarTable::new();
+    assert!(vars.bind("a".into(), 1));
+
+    vars.push_scope();
+    assert!(vars.bind("b".into(), 2));
+    assert_eq!(vars.resolve("a"), Some((&1, false)));
+    assert_eq!(vars.resolve("b"), Some((&2, true)));
+    assert_eq!(vars.resolve("c"), None);
+
+    vars.pop_scope();
+    assert_eq!(vars.resolve("a"), Some((&1, true)));
+}
@@ -16,6 +16,11 @@ link-with=[]      # Additional libraries to link with
 [options]
 debug-mode = false
 codegen = "c"
+
+[warnings]
+unused-funcs = true       # Private functions never used
+const-conditions = true   # Conditions which are always true or false
+bool-comparisons = true   # Comparisons with true or false, eg. x == true
 "#;
 
 #[derive(Deserialize)]
@@ -23,6 +28,8 @@ codegen = "c"
 pub struct ConfigFile {
     pub project: Project,
     pub options: Options,
+    #[serde(default)]
+    pub warnings: Warnings,
 }
 
 /// Specifies what the output code (or bytecode) will look
@@ -93,6 +100,56 @@ pub struct Options {
     pub codegen: Codegen,
 }
 
+/// Warnings selects which warning passes are run after type checking. All
+/// warnings except missing_fields are enabled by default.
+#[derive(Deserialize, Clone, Debug, PartialEq)]
+#[serde(rename_all = "kebab-case", default)]
+pub struct Warnings {
+    /// Warn about private functions never reached from main or exported functions.
+    pub unused_funcs: bool,
+    /// Warn about conditions which are always true or always false.
+    pub const_conditions: bool,
+    /// Warn about comparisons with boolean literals, eg. 'x == true'.
+    pub bool_comparisons: bool,
+    /// Warn about statements following a return, break, or continue.
+    pub unreachable_code: bool,
+    /// Warn about exported functions using private types in their signature.
+    pub private_types: bool,
+    /// Warn about struct literals leaving out fields.
+    pub missing_fields: bool,
+    /// Warn about function parameters named after builtin types.
+    pub builtin_shadowing: bool,
+}
+
+impl Default for Warnings {
+    fn default() -> Self {
+        Self {
+            unused_funcs: true,
+            const_conditions: true,
+            bool_comparisons: true,
+            unreachable_code: true,
+            private_types: true,
+            missing_fields: false,
+            builtin_shadowing: true,
+        }
+    }
+}
+
+impl Warnings {
+    /// All warnings disabled.
+    pub fn none() -> Self {
+        Self {
+            unused_funcs: false,
+            const_conditions: false,
+            bool_comparisons: false,
+            unreachable_code: false,
+            private_types: false,
+            missing_fields: false,
+            builtin_shadowing: false,
+        }
+    }
+}
+
 /// DriverPhase tells the driver at which phase compilation should be terminated.
 /// This is purely a debug/dev tools for inspecting the source code at each stage.
 #[derive(Debug, Clone)]
@@ -122,6 +179,8 @@ pub struct Config {
     pub comment_assembly: bool,
     /// Which phase of compilation to terminate at.
     pub driver_phase: DriverPhase,
+    /// Which warnings to report after type checking.
+    pub warnings: Warnings,
 }
 
 impl Config {
@@ -132,6 +191,7 @@ impl Config {
             print_symbol_tables: false,
             comment_assembly: true,
             driver_phase: DriverPhase::Full,
+            warnings: Warnings::default(),
         }
     }
 
@@ -142,6 +202,7 @@ impl Config {
             print_symbol_tables: false,
             comment_assembly: false,
             driver_phase: DriverPhase::Full,
+            warnings: Warnings::none(),
         }
     }
 
@@ -152,6 +213,7 @@ impl Config {
             print_symbol_tables: true,
             comment_assembly: true,
             driver_phase: DriverPhase::Full,
+            warnings: Warnings::default(),
         }
     }
 }
@@ -167,11 +229,12 @@ pub fn load_config_file_ex(path: &str) -> Result<(Project, Options, Config), Str
         .map_err(|_| "Failed to open koi.toml. Run `koi init` if missing.".to_string())?;
     let config_file: ConfigFile = toml::from_str(&src).map_err(|e| e.to_string())?;
 
-    let config = if config_file.options.debug_mode {
+    let mut config = if config_file.options.debug_mode {
         Config::debug()
     } else {
         Config::normal()
     };
+    config.warnings = config_file.warnings;
 
     Ok((config_file.project, config_file.options, config))
 }
@@ -2,7 +2,7 @@ mod modules;
 mod symbols;
 mod types;
 
-use crate::config::Config;
+use crate::{config::Config, typecheck::Rule};
 
 pub use modules::*;
 pub use symbols::*;
@@ -13,6 +13,8 @@ pub struct Context {
     pub modules: ModuleInterner,
     pub symbols: SymbolInterner,
     pub config: Config,
+    /// Custom rules run on each file after type checking.
+    pub rules: Vec<Box<dyn Rule>>,
 }
 
 impl Context {
@@ -22,6 +24,15 @@ impl Context {
             types: TypeInterner::new(),
             modules: ModuleInterner::new(),
             config,
+            rules: Vec::new(),
         }
     }
+
+    /// Register a custom rule to run alongside type checking.
+    pub fn add_rule(&mut self, rule: impl Rule + 'static) {
+        self.rules.push(Box::new(rule));
+    }
 }
+
+#[cfg(test)]
+mod types_test;
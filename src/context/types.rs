@@ -1,4 +1,6 @@
-use crate::types::{FunctionType, NO_TYPE, PrimitiveType, Type, TypeId, TypeKind};
+use crate::types::{
+    FunctionType, NO_TYPE, PTR_SIZE, PrimitiveType, Type, TypeId, TypeKind, ZeroValue,
+};
 use std::collections::{HashMap, HashSet};
 use strum::IntoEnumIterator;
 
@@ -105,6 +107,11 @@ impl TypeInterner {
         }
     }
 
+    /// Reports whether values of this type can be null.
+    pub fn is_nilable(&self, id: TypeId) -> bool {
+        matches!(self.lookup(self.resolve(id)).kind, TypeKind::Pointer(_))
+    }
+
     /// Get a types internal kind. Resolves array item types, pointer target
     /// types, and unique types underlying kind. Do not use for general type comparisons.
     pub fn inner_kind(&self, id: TypeId) -> TypeId {
@@ -138,6 +145,82 @@ impl TypeInterner {
         .contains(&id)
     }
 
+    /// Size of a value of this type in bytes. Arrays are laid out without
+    /// padding between elements as the element size is always a multiple of
+    /// its alignment. Slices are a pointer followed by a length. Struct and
+    /// tuple fields are laid out in order, each aligned, with padding at the
+    /// end so the size is a multiple of the alignment.
+    pub fn size_of(&self, id: TypeId) -> usize {
+        match &self.lookup(id).kind {
+            TypeKind::Primitive(p) => p.bytes(),
+            TypeKind::Array(elem, len) => self.size_of(*elem) * len,
+            TypeKind::Slice(_) => PTR_SIZE * 2,
+            TypeKind::Pointer(_) | TypeKind::Function(_) => PTR_SIZE,
+            TypeKind::Alias(target) | TypeKind::Unique(_, target) => self.size_of(*target),
+            TypeKind::Struct(s) => self.fields_size(s.fields.iter().map(|(_, ty)| *ty), id),
+            TypeKind::Tuple(types) => self.fields_size(types.iter().copied(), id),
+        }
+    }
+
+    /// Size of the given fields laid out in order, padded to the alignment of
+    /// the type holding them.
+    fn fields_size(&self, fields: impl Iterator<Item = TypeId>, id: TypeId) -> usize {
+        let end = fields.fold(0usize, |offset, ty| {
+            offset.next_multiple_of(self.align_of(ty)) + self.size_of(ty)
+        });
+        end.next_multiple_of(self.align_of(id))
+    }
+
+    /// Required alignment of a value of this type in bytes.
+    pub fn align_of(&self, id: TypeId) -> usize {
+        match &self.lookup(id).kind {
+            TypeKind::Primitive(p) => p.align(),
+            TypeKind::Array(elem, _) => self.align_of(*elem),
+            TypeKind::Pointer(_) | TypeKind::Slice(_) | TypeKind::Function(_) => PTR_SIZE,
+            TypeKind::Alias(target) | TypeKind::Unique(_, target) => self.align_of(*target),
+            TypeKind::Struct(s) => s
+                .fields
+                .iter()
+                .map(|(_, ty)| self.align_of(*ty))
+                .max()
+                .unwrap_or(1),
+            TypeKind::Tuple(types) => types.iter().map(|ty| self.align_of(*ty)).max().unwrap_or(1),
+        }
+    }
+
+    /// Zero value of this type, used for values which are not explicitly
+    /// initialized. Void has no value.
+    pub fn zero_value(&self, id: TypeId) -> Option<ZeroValue> {
+        let value = match &self.lookup(id).kind {
+            TypeKind::Primitive(p) => match p {
+                PrimitiveType::Void => return None,
+                PrimitiveType::F32 | PrimitiveType::F64 => ZeroValue::Float,
+                PrimitiveType::Bool => ZeroValue::Bool,
+                PrimitiveType::String => ZeroValue::String,
+                _ => ZeroValue::Int,
+            },
+            TypeKind::Pointer(_) | TypeKind::Slice(_) | TypeKind::Function(_) => ZeroValue::Null,
+            TypeKind::Alias(target) | TypeKind::Unique(_, target) => {
+                return self.zero_value(*target);
+            }
+            TypeKind::Array(elem, len) => ZeroValue::Aggregate(vec![self.zero_value(*elem)?; *len]),
+            TypeKind::Struct(s) => ZeroValue::Aggregate(
+                s.fields
+                    .iter()
+                    .map(|(_, ty)| self.zero_value(*ty))
+                    .collect::<Option<_>>()?,
+            ),
+            TypeKind::Tuple(types) => ZeroValue::Aggregate(
+                types
+                    .iter()
+                    .map(|ty| self.zero_value(*ty))
+                    .collect::<Option<_>>()?,
+            ),
+        };
+
+        Some(value)
+    }
+
     /// Shorthand for getting void type
     pub fn void(&self) -> TypeId {
         self.primitive(PrimitiveType::Void)
@@ -163,7 +246,8 @@ impl TypeInterner {
 
             refs.insert(current);
             match &self.lookup(current).kind {
-                TypeKind::Array(inner)
+                TypeKind::Array(inner, _)
+                | TypeKind::Slice(inner)
                 | TypeKind::Pointer(inner)
                 | TypeKind::Alias(inner)
                 | TypeKind::Unique(_, inner) => stack.push(*inner),
@@ -173,6 +257,8 @@ impl TypeInterner {
                     }
                     stack.push(func.ret);
                 }
+                TypeKind::Struct(s) => stack.extend(s.fields.iter().map(|(_, ty)| *ty)),
+                TypeKind::Tuple(types) => stack.extend(types),
                 TypeKind::Primitive(p) => {
                     refs.insert(self.primitive(p.clone()));
                 }
@@ -186,10 +272,13 @@ impl TypeInterner {
     pub fn type_to_string(&self, id: TypeId) -> String {
         match &self.lookup(id).kind {
             TypeKind::Primitive(p) => format!("{p}"),
-            TypeKind::Array(inner) => format!("[]{}", self.type_to_string(*inner)),
+            TypeKind::Array(inner, len) => format!("[{}]{}", len, self.type_to_string(*inner)),
+            TypeKind::Slice(inner) => format!("[]{}", self.type_to_string(*inner)),
             TypeKind::Pointer(inner) => format!("*{}", self.type_to_string(*inner)),
             TypeKind::Alias(id) => self.type_to_string(*id).to_string(),
             TypeKind::Unique(name, _) => name.into(),
+            TypeKind::Struct(s) => s.name.clone(),
+            TypeKind::Tuple(types) => format!("({})", self.types_to_string(types)),
             TypeKind::Function(f) => {
                 let params_str = f
                     .params
@@ -204,13 +293,35 @@ impl TypeInterner {
         }
     }
 
+    /// Comma separated list of types, eg. i32, string
+    fn types_to_string(&self, types: &[TypeId]) -> String {
+        types
+            .iter()
+            .map(|ty| self.type_to_string(*ty))
+            .collect::<Vec<_>>()
+            .join(", ")
+    }
+
     pub fn type_to_string_debug(&self, id: TypeId) -> String {
         match &self.lookup(id).kind {
             TypeKind::Primitive(p) => format!("{p}"),
-            TypeKind::Array(inner) => format!("Array<{}>", self.type_to_string(*inner)),
+            TypeKind::Array(inner, len) => {
+                format!("Array<{}, {}>", self.type_to_string(*inner), len)
+            }
+            TypeKind::Slice(inner) => format!("Slice<{}>", self.type_to_string(*inner)),
             TypeKind::Pointer(inner) => format!("Pointer<{}>", self.type_to_string(*inner)),
             TypeKind::Alias(id) => format!("Alias({})", self.type_to_string(*id)),
             TypeKind::Unique(name, id) => format!("Unique({name} {})", self.type_to_string(*id)),
+            TypeKind::Tuple(types) => format!("Tuple<{}>", self.types_to_string(types)),
+            TypeKind::Struct(s) => {
+                let fields_str = s
+                    .fields
+                    .iter()
+                    .map(|(name, ty)| format!("{} {}", name, self.type_to_string(*ty)))
+                    .collect::<Vec<_>>()
+                    .join(", ");
+                format!("Struct({} {{ {} }})", s.name, fields_str)
+            }
             TypeKind::Function(f) => {
                 let params_str = f
                     .params
@@ -0,0 +1,205 @@
+use crate::{
+    context::TypeInterner,
+    ir::align_to,
+    types::{FunctionType, PrimitiveType, StructType, TypeKind},
+};
+
+#[test]
+fn test_primitive_size_and_align() {
+    let types = TypeInterner::new();
+
+    let cases = [
+        (PrimitiveType::Void, 0, 1),
+        (PrimitiveType::Bool, 1, 1),
+        (PrimitiveType::U8, 1, 1),
+        (PrimitiveType::I16, 2, 2),
+        (PrimitiveType::I32, 4, 4),
+        (PrimitiveType::F32, 4, 4),
+        (PrimitiveType::U64, 8, 8),
+        (PrimitiveType::F64, 8, 8),
+        (PrimitiveType::String, 8, 8),
+    ];
+
+    for (kind, size, align) in cases {
+        let id = types.primitive(kind.clone());
+        assert_eq!(types.size_of(id), size, "size of {}", kind);
+        assert_eq!(types.align_of(id), align, "align of {}", kind);
+    }
+}
+
+#[test]
+fn test_pointer_and_function_size_and_align() {
+    let mut types = TypeInterner::new();
+    let byte = types.primitive(PrimitiveType::Byte);
+
+    let ptr = types.get_or_intern(TypeKind::Pointer(byte));
+    assert_eq!(types.size_of(ptr), 8);
+    assert_eq!(types.align_of(ptr), 8);
+
+    let func = types.get_or_intern(TypeKind::Function(FunctionType {
+        params: vec![byte],
+        ret: byte,
+    }));
+    assert_eq!(types.size_of(func), 8);
+    assert_eq!(types.align_of(func), 8);
+}
+
+#[test]
+fn test_array_size_and_align() {
+    let mut types = TypeInterner::new();
+    let i32 = types.primitive(PrimitiveType::I32);
+
+    let arr = types.get_or_intern(TypeKind::Array(i32, 5));
+    assert_eq!(types.size_of(arr), 20);
+    assert_eq!(types.align_of(arr), 4);
+
+    let nested = types.get_or_intern(TypeKind::Array(arr, 3));
+    assert_eq!(types.size_of(nested), 60);
+    assert_eq!(types.align_of(nested), 4);
+
+    let empty = types.get_or_intern(TypeKind::Array(i32, 0));
+    assert_eq!(types.size_of(empty), 0);
+    assert_eq!(types.align_of(empty), 4);
+}
+
+#[test]
+fn test_slice_size_align_and_interning() {
+    let mut types = TypeInterner::new();
+    let u8 = types.primitive(PrimitiveType::U8);
+
+    let slice = types.get_or_intern(TypeKind::Slice(u8));
+    assert_eq!(types.size_of(slice), 16);
+    assert_eq!(types.align_of(slice), 8);
+    assert_eq!(types.get_or_intern(TypeKind::Slice(u8)), slice);
+
+    let arr = types.get_or_intern(TypeKind::Array(u8, 2));
+    assert_ne!(slice, arr);
+    assert_eq!(types.type_to_string(slice), "[]u8");
+
+    let nested = types.get_or_intern(TypeKind::Slice(slice));
+    assert_eq!(types.type_to_string(nested), "[][]u8");
+}
+
+#[test]
+fn test_alias_and_unique_size_and_align() {
+    let mut types = TypeInterner::new();
+    let i16 = types.primitive(PrimitiveType::I16);
+
+    let alias = types.get_or_intern(TypeKind::Alias(i16));
+    assert_eq!(types.size_of(alias), 2);
+    assert_eq!(types.align_of(alias), 2);
+
+    let unique = types.get_or_intern(TypeKind::Unique("Id".to_string(), alias));
+    assert_eq!(types.size_of(unique), 2);
+    assert_eq!(types.align_of(unique), 2);
+}
+
+#[test]
+fn test_struct_size_and_align() {
+    let mut types = TypeInterner::new();
+    let u8 = types.primitive(PrimitiveType::U8);
+    let i32 = types.primitive(PrimitiveType::I32);
+    let u64 = types.primitive(PrimitiveType::U64);
+
+    // Padding after a and b, and at the end after c
+    let s = types.get_or_intern(TypeKind::Struct(StructType {
+        name: "S".to_string(),
+        module: "app".to_string(),
+        fields: vec![
+            ("a".to_string(), u8),
+            ("b".to_string(), u64),
+            ("c".to_string(), i32),
+        ],
+    }));
+    assert_eq!(types.size_of(s), 24);
+    assert_eq!(types.align_of(s), 8);
+    assert_eq!(types.type_to_string(s), "S");
+
+    let empty = types.get_or_intern(TypeKind::Struct(StructType {
+        name: "Empty".to_string(),
+        module: "app".to_string(),
+        fields: vec![],
+    }));
+    assert_eq!(types.size_of(empty), 0);
+    assert_eq!(types.align_of(empty), 1);
+}
+
+#[test]
+fn test_primitive_zero_value() {
+    let types = TypeInterner::new();
+
+    let cases = [
+        (PrimitiveType::I32, "0"),
+        (PrimitiveType::U64, "0"),
+        (PrimitiveType::Byte, "0"),
+        (PrimitiveType::F32, "0.0"),
+        (PrimitiveType::F64, "0.0"),
+        (PrimitiveType::Bool, "false"),
+        (PrimitiveType::String, "\"\""),
+    ];
+
+    for (kind, zero) in cases {
+        let id = types.primitive(kind.clone());
+        let value = types
+            .zero_value(id)
+            .expect("primitive should have a zero value");
+        assert_eq!(value.to_string(), zero, "zero value of {}", kind);
+    }
+
+    assert_eq!(types.zero_value(types.void()), None);
+}
+
+#[test]
+fn test_aggregate_zero_value() {
+    let mut types = TypeInterner::new();
+    let i32 = types.primitive(PrimitiveType::I32);
+    let f64 = types.primitive(PrimitiveType::F64);
+    let string = types.primitive(PrimitiveType::String);
+
+    let arr = types.get_or_intern(TypeKind::Array(i32, 3));
+    let ptr = types.get_or_intern(TypeKind::Pointer(i32));
+    let s = types.get_or_intern(TypeKind::Struct(StructType {
+        name: "S".to_string(),
+        module: "app".to_string(),
+        fields: vec![
+            ("a".to_string(), f64),
+            ("b".to_string(), string),
+            ("c".to_string(), arr),
+            ("d".to_string(), ptr),
+        ],
+    }));
+
+    assert_eq!(types.zero_value(arr).unwrap().to_string(), "{0, 0, 0}");
+    assert_eq!(
+        types.zero_value(s).unwrap().to_string(),
+        "{0.0, \"\", {0, 0, 0}, null}"
+    );
+}
+
+#[test]
+fn test_align_to() {
+    assert_eq!(align_to(0, 8), 0);
+    assert_eq!(align_to(1, 8), 8);
+    assert_eq!(align_to(8, 8), 8);
+    assert_eq!(align_to(5, 4), 8);
+    assert_eq!(align_to(5, 1), 5);
+}
+
+#[test]
+fn test_struct_interned_per_module() {
+    let mut types = TypeInterner::new();
+    let i32 = types.primitive(PrimitiveType::I32);
+
+    let mut point = |module: &str| {
+        types.get_or_intern(TypeKind::Struct(StructType {
+            name: "Point".to_string(),
+            module: module.to_string(),
+            fields: vec![("x".to_string(), i32)],
+        }))
+    };
+
+    let a = point("app.a");
+    let b = point("app.b");
+    assert_ne!(a, b);
+    assert_eq!(a, point("app.a"));
+}
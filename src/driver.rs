@@ -7,17 +7,23 @@ use tracing::{debug, info};
 use walkdir::WalkDir;
 
 use crate::{
-    ast::{FileSet, Printer},
+    ast::{FileSet, Printer, TokenKind},
     build::{self, c, x86},
     common::{FilePath, Source, SourceMap, create_dir_if_not_exist, get_root_dir, write_file},
     config::{Codegen, Config, DriverPhase, Options, PathManager, Project, ProjectType},
     context::Context,
+    error::Diagnostics,
     imports::{LibrarySet, create_header_file, read_header_file},
     ir::{ProgramIR, Unit, print_ir},
     lower::emit_ir,
     module::{Module, ModuleId, ModulePath},
     parser::{SortResult, parse_source_map, sort_by_dependency_graph, validate_imports},
-    typecheck::check_filesets,
+    scanner::scan_with_comments,
+    typecheck::{
+        check_bool_comparisons, check_builtin_shadowing, check_const_conditions,
+        check_exported_signatures, check_filesets, check_missing_fields, check_unreachable_code,
+        check_unused_funcs,
+    },
 };
 
 #[cfg(test)]
@@ -31,6 +37,146 @@ type Res<T> = Result<T, String>;
 
 /// Compile the project using the given global config and build configuration.
 pub fn compile(project: Project, options: Options, config: Config) -> Res<()> {
+    // Recursively search the given source directory for files and
+    // return a list of SourceDir of all source files found.
+    let source_dirs = collect_all_source_dirs(&project.src, &project.ignore_dirs, &project)?;
+
+    compile_source_dirs(source_dirs, project, options, config)
+}
+
+/// Compile a single source string as the main module of the project. The projects
+/// source directory is ignored, but output directories and target name are used.
+pub fn compile_str(
+    filename: &str,
+    src: &str,
+    project: Project,
+    options: Options,
+    config: Config,
+) -> Res<()> {
+    let source_dirs = vec![source_dir_from_str(filename, src, &project)];
+    compile_source_dirs(source_dirs, project, options, config)
+}
+
+/// Parse and type check a single source string as the main module. Returns the
+/// context containing the checked module. Imports of external libraries are
+/// not supported as no library set is loaded.
+pub fn check_str(filename: &str, src: &str, config: Config) -> Res<Context> {
+    let dir = source_dir_from_str(filename, src, &check_only_project());
+    let filesets = parse_source_dirs(std::slice::from_ref(&dir), &config)?;
+
+    let libset = LibrarySet::new();
+    validate_external_imports(&filesets, &dir.map, &libset)?;
+
+    let sort_result = sort_by_dependency_graph(filesets)?;
+    create_modules(sort_result, &dir.map, &libset, config)
+}
+
+/// Scan, parse, and type check a single source string as the main module and
+/// return all errors and warnings sorted by position. Never lowers to IR, so
+/// it is safe to run on invalid programs, eg. for editor diagnostics.
+pub fn check_file(filename: &str, src: &str, config: Config) -> Diagnostics {
+    let dir = source_dir_from_str(filename, src, &check_only_project());
+
+    let mut diag = match check_source_dir(dir, config) {
+        Ok(ctx) => collect_warnings(&ctx),
+        Err(diag) => diag,
+    };
+
+    diag.sort();
+    diag
+}
+
+/// Parse the file at path and print it back in the standard format. Returns
+/// true if formatting changes the file. The file is only written when write is
+/// set and the content changed, so unchanged files keep their modification
+/// time. Files containing comments are rejected as the printer drops them.
+/// The formatted text is parsed again before writing, and the file is left
+/// untouched if that fails.
+pub fn format_file(path: &str, write: bool, config: Config) -> Res<bool> {
+    let source = Source::from_path(FilePath::from(path))?;
+    let original = source.src.clone();
+    let formatted = format_source(source, &config)?;
+
+    let reparsed = Source::new_str(path.to_owned(), formatted.clone());
+    if let Err(err) = format_source(reparsed, &config) {
+        return Err(format!(
+            "error: cannot format '{}': formatted output does not parse\n{}",
+            path, err
+        ));
+    }
+
+    let changed = formatted.as_bytes() != original.as_slice();
+    if changed && write {
+        write_file(&FilePath::from(path), formatted)?;
+    }
+
+    Ok(changed)
+}
+
+/// Parse the source and print it back in the standard format.
+fn format_source(source: Source, config: &Config) -> Res<String> {
+    let path = source.filepath.to_string();
+    let map = SourceMap::one(source);
+    let source = map.sources().last().unwrap();
+    let tokens = scan_with_comments(source, config).map_err(|err| err.render(&map))?;
+
+    let has_comments = tokens.iter().any(|token| {
+        matches!(
+            token.kind,
+            TokenKind::LineComment(_) | TokenKind::BlockComment(_)
+        )
+    });
+
+    if has_comments {
+        return Err(format!(
+            "error: cannot format '{}': comments are not supported by the formatter yet",
+            path
+        ));
+    }
+
+    let modpath = filepath_to_module_path(&FilePath::from(""), "", &check_only_project());
+    let mut fs = parse_source_map(modpath, &map, config).map_err(|err| err.render(&map))?;
+
+    // The printer separates declarations with an empty line, so the trailing
+    // one is replaced by a single newline at the end of the file.
+    Ok(match fs.files.pop() {
+        Some(file) => format!("{}\n", Printer::format(&file.ast, source).trim_end()),
+        None => String::new(),
+    })
+}
+
+/// Parse and check a source dir without rendering errors.
+fn check_source_dir(dir: SourceDir, config: Config) -> Result<Context, Diagnostics> {
+    let fs = parse_source_map(dir.modpath, &dir.map, &config)?;
+    validate_imports(&fs, LibrarySet::new().import_paths())?;
+
+    let mut ctx = Context::new(config);
+    check_filesets(&mut ctx, vec![fs])?;
+    Ok(ctx)
+}
+
+/// Project used when only checking a source string. Nothing is written to disk.
+fn check_only_project() -> Project {
+    Project {
+        name: "main".into(),
+        bin: String::new(),
+        src: String::new(),
+        out: String::new(),
+        project_type: ProjectType::App,
+        includes: None,
+        ignore_dirs: vec![],
+        link_with: vec![],
+    }
+}
+
+/// Compile the given source directories. This is the shared tail of the
+/// compilation pipeline, running from parsing to the final build step.
+fn compile_source_dirs(
+    source_dirs: Vec<SourceDir>,
+    project: Project,
+    options: Options,
+    config: Config,
+) -> Res<()> {
     let pm = PathManager::new(
         options
             .install_dir
@@ -40,10 +186,6 @@ pub fn compile(project: Project, options: Options, config: Config) -> Res<()> {
 
     create_dir_if_not_exist(&project.bin)?;
 
-    // Recursively search the given source directory for files and
-    // return a list of SourceDir of all source files found.
-    let source_dirs = collect_all_source_dirs(&project.src, &project.ignore_dirs, &project)?;
-
     // Parse all of the sources and return a list of FileSet.
     let filesets = parse_source_dirs(&source_dirs, &config)?;
 
@@ -79,6 +221,7 @@ pub fn compile(project: Project, options: Options, config: Config) -> Res<()> {
 
     // Do some high level passes at a module level before lowering
     check_main_function_present(&ctx, &project)?;
+    report_warnings(&ctx, &source_map);
     dump_debug_info(&ctx, &project)?;
 
     // Finished type check phase, exit early if specified.
@@ -166,6 +309,17 @@ struct SourceDir {
     map: SourceMap,
 }
 
+/// Create a source dir for the main module containing a single source string.
+fn source_dir_from_str(filename: &str, src: &str, project: &Project) -> SourceDir {
+    let mut map = SourceMap::new();
+    map.add(Source::new_str(filename.into(), src.into()));
+
+    SourceDir {
+        modpath: filepath_to_module_path(&FilePath::from(""), "", project),
+        map,
+    }
+}
+
 /// Recursively search the given source directory for files and return a list of FileSet of
 /// all source files found.
 fn collect_all_source_dirs(
@@ -229,17 +383,14 @@ fn dir_to_source_map(dir: &FilePath) -> Res<SourceMap> {
 
     let mut map = SourceMap::new();
     for file in files {
-        match fs::read(file.path_buf()) {
-            Err(err) => return Err(format!("failed to read file: {}", err)),
-            Ok(src) => map.add(Source::new(file, src)),
-        }
+        map.add(Source::from_path(file)?);
     }
 
     Ok(map)
 }
 
 /// Parse all files in each source directory.
-fn parse_source_dirs(dirs: &Vec<SourceDir>, config: &Config) -> Res<Vec<FileSet>> {
+fn parse_source_dirs(dirs: &[SourceDir], config: &Config) -> Res<Vec<FileSet>> {
     let mut filesets = Vec::new();
 
     for dir in dirs {
@@ -407,6 +558,55 @@ fn check_main_function_present(ctx: &Context, project: &Project) -> Res<()> {
     Ok(())
 }
 
+/// Print configured warnings for all source modules.
+fn report_warnings(ctx: &Context, map: &SourceMap) {
+    let diag = collect_warnings(ctx);
+    if !diag.is_empty() {
+        eprintln!("{}", diag.render(map));
+    }
+}
+
+/// Run all enabled warning passes on the modules to be built.
+fn collect_warnings(ctx: &Context) -> Diagnostics {
+    let mut diag = Diagnostics::new();
+
+    for module in ctx.modules.modules() {
+        if !module.should_be_built() {
+            continue;
+        }
+
+        if ctx.config.warnings.unused_funcs {
+            diag.extend(check_unused_funcs(ctx, module.id));
+        }
+
+        if ctx.config.warnings.const_conditions {
+            diag.extend(check_const_conditions(ctx, module.id));
+        }
+
+        if ctx.config.warnings.bool_comparisons {
+            diag.extend(check_bool_comparisons(ctx, module.id));
+        }
+
+        if ctx.config.warnings.unreachable_code {
+            diag.extend(check_unreachable_code(ctx, module.id));
+        }
+
+        if ctx.config.warnings.private_types {
+            diag.extend(check_exported_signatures(ctx, module.id));
+        }
+
+        if ctx.config.warnings.missing_fields {
+            diag.extend(check_missing_fields(ctx, module.id));
+        }
+
+        if ctx.config.warnings.builtin_shadowing {
+            diag.extend(check_builtin_shadowing(ctx, module.id));
+        }
+    }
+
+    diag
+}
+
 /// Print debug info if configured.
 fn dump_debug_info(ctx: &Context, project: &Project) -> Res<()> {
     if ctx.config.dump_types {
@@ -1,7 +1,7 @@
 use std::fs::create_dir_all;
 
 use crate::{
-    config::{Codegen, Config, DriverPhase, Options, Project, ProjectType},
+    config::{Codegen, Config, DriverPhase, Options, Project, ProjectType, Warnings},
     driver::compile,
 };
 
@@ -59,6 +59,7 @@ fn app_project(test_name: &str, install_dir: &super::FilePath) -> (Project, Opti
         print_symbol_tables: false,
         no_mangle_names: false,
         comment_assembly: false,
+        warnings: Warnings::none(),
     };
     (project, options, config)
 }
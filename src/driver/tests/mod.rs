@@ -8,8 +8,9 @@ use strum::IntoEnumIterator;
 
 use crate::{
     common::{FilePath, cmd},
-    config::{Codegen, Config, Options, Project, ProjectType},
-    driver::compile,
+    config::{Codegen, Config, Options, Project, ProjectType, Warnings},
+    driver::{check_file, check_str, compile, compile_str, format_file},
+    error::Severity,
 };
 
 static INIT: Once = Once::new();
@@ -70,6 +71,7 @@ fn new_config(case: &str, codegen: Codegen) -> (Project, Options, Config) {
         print_symbol_tables: false,
         no_mangle_names: false,
         comment_assembly: false,
+        warnings: Warnings::none(),
     };
 
     (project, options, config)
@@ -106,6 +108,7 @@ fn library_config(
         print_symbol_tables: false,
         no_mangle_names: false,
         comment_assembly: false,
+        warnings: Warnings::none(),
     };
 
     (project, options, config)
@@ -144,6 +147,407 @@ fn run_case_c_only(case: &str, status: i32) {
     expect_status(case, status);
 }
 
+#[test]
+fn test_compile_str() {
+    let src = r#"
+func add(a int, b int) int {
+    return a + b
+}
+
+func main() int {
+    return add(1, 2)
+}
+"#;
+
+    for target in Codegen::iter() {
+        let (project, options, config) = new_config("compile_str", target);
+        compile_str("main.koi", src, project, options, config).unwrap();
+        expect_status("compile_str", 3);
+    }
+}
+
+#[test]
+fn test_check_str() {
+    let src = r#"
+func main() int {
+    return 0
+}
+"#;
+
+    let ctx = check_str("main.koi", src, Config::test()).unwrap();
+    let main = ctx.modules.main().expect("main module should exist");
+    assert!(main.symbols.get("main").is_ok());
+}
+
+#[test]
+fn test_check_str_error() {
+    let src = r#"
+func main() int {
+    return true
+}
+"#;
+
+    assert!(check_str("main.koi", src, Config::test()).is_err());
+}
+
+#[test]
+fn test_check_str_redeclared_shows_previous_line() {
+    let src = r#"
+func main() int {
+    a := 1
+    a := 2
+    return a
+}
+"#;
+
+    let err = check_str("main.koi", src, Config::test()).err().unwrap();
+    assert!(err.contains("already declared"), "{}", err);
+    assert!(err.contains("previously declared on line 3"), "{}", err);
+}
+
+#[test]
+fn test_check_str_missing_return_at_closing_brace() {
+    let src = r#"
+func f(c bool) int {
+    if c {
+        return 1
+    }
+}
+"#;
+
+    // Points at the closing brace of the function on line 6
+    let err = check_str("main.koi", src, Config::test()).err().unwrap();
+    assert!(err.contains("missing return in function 'f'"), "{}", err);
+    assert!(err.contains("6   |    }"), "{}", err);
+}
+
+#[test]
+fn test_check_file() {
+    let src = r#"
+func main() int {
+    return 0
+}
+"#;
+
+    assert!(check_file("main.koi", src, Config::test()).is_empty());
+}
+
+#[test]
+fn test_check_file_type_error() {
+    let src = r#"
+func main() int {
+    return true
+}
+"#;
+
+    let diag = check_file("main.koi", src, Config::test());
+    assert_eq!(diag.reports().len(), 1);
+    assert_eq!(
+        diag.get(0).message,
+        "incorrect return type: expected 'i32', got 'bool'"
+    );
+}
+
+#[test]
+fn test_check_file_parse_error() {
+    let src = r#"
+func main() int {
+    return 0
+"#;
+
+    let diag = check_file("main.koi", src, Config::test());
+    assert_eq!(diag.reports().len(), 1);
+    assert_eq!(diag.get(0).severity(), Severity::Error);
+}
+
+#[test]
+fn test_check_file_warnings_sorted() {
+    let src = r#"
+func main() int {
+    if 1 == 2 {
+        return 1
+    }
+    return 0
+}
+
+func unused() {}
+"#;
+
+    let mut config = Config::test();
+    config.warnings.unused_funcs = true;
+    config.warnings.const_conditions = true;
+
+    // Unused function warnings are produced first but come later in source
+    let diag = check_file("main.koi", src, config);
+    let messages = diag
+        .reports()
+        .iter()
+        .map(|r| r.message.as_str())
+        .collect::<Vec<_>>();
+    assert_eq!(
+        messages,
+        vec![
+            "condition is always false",
+            "function 'unused' is never used"
+        ]
+    );
+    assert!(
+        diag.reports()
+            .iter()
+            .all(|r| r.severity() == Severity::Warning)
+    );
+}
+
+fn warning_messages(warnings: Warnings) -> Vec<String> {
+    let src = r#"
+func main() int {
+    a := true
+    if a == true {
+        return 1
+    }
+    while 1 == 2 {
+        break
+        a = false
+    }
+    return 0
+}
+
+func unused() {}
+"#;
+
+    let mut config = Config::test();
+    config.warnings = warnings;
+    check_file("main.koi", src, config)
+        .reports()
+        .iter()
+        .map(|r| r.message.clone())
+        .collect()
+}
+
+#[test]
+fn test_check_file_all_warnings() {
+    assert_eq!(
+        warning_messages(Warnings::default()),
+        vec![
+            "redundant comparison with 'true', simplify to 'a'",
+            "condition is always false",
+            "unreachable code after 'break'",
+            "function 'unused' is never used",
+        ]
+    );
+}
+
+#[test]
+fn test_check_file_no_warnings() {
+    assert!(warning_messages(Warnings::none()).is_empty());
+}
+
+#[test]
+fn test_check_file_single_warning_enabled() {
+    let only = |set: fn(&mut Warnings)| {
+        let mut warnings = Warnings::none();
+        set(&mut warnings);
+        warning_messages(warnings)
+    };
+
+    assert_eq!(
+        only(|w| w.unused_funcs = true),
+        vec!["function 'unused' is never used"]
+    );
+    assert_eq!(
+        only(|w| w.const_conditions = true),
+        vec!["condition is always false"]
+    );
+    assert_eq!(
+        only(|w| w.bool_comparisons = true),
+        vec!["redundant comparison with 'true', simplify to 'a'"]
+    );
+    assert_eq!(
+        only(|w| w.unreachable_code = true),
+        vec!["unreachable code after 'break'"]
+    );
+}
+
+#[test]
+fn test_check_file_missing_fields_warning() {
+    let src = r#"
+struct Point { x int, y int }
+
+func main() int {
+    p := Point{x: 1}
+    return p.x
+}
+"#;
+
+    let messages = |warnings: Warnings| {
+        let mut config = Config::test();
+        config.warnings = warnings;
+        check_file("main.koi", src, config)
+            .reports()
+            .iter()
+            .map(|r| r.message.clone())
+            .collect::<Vec<_>>()
+    };
+
+    assert!(messages(Warnings::default()).is_empty());
+
+    let mut warnings = Warnings::none();
+    warnings.missing_fields = true;
+    assert_eq!(
+        messages(warnings),
+        vec!["missing field 'y' in literal of 'Point'"]
+    );
+}
+
+/// Write src to a file in the temp directory and return its path.
+fn temp_file(name: &str, src: &str) -> String {
+    let path = std::env::temp_dir().join(name);
+    std::fs::write(&path, src).unwrap();
+    path.to_string_lossy().to_string()
+}
+
+#[test]
+fn test_format_file_unchanged() {
+    let src = "func main() int {\n    return 0\n}\n";
+    let path = temp_file("koi_format_unchanged.koi", src);
+    let modified = std::fs::metadata(&path).unwrap().modified().unwrap();
+
+    assert_eq!(format_file(&path, true, Config::test()), Ok(false));
+    assert_eq!(std::fs::read_to_string(&path).unwrap(), src);
+    assert_eq!(
+        std::fs::metadata(&path).unwrap().modified().unwrap(),
+        modified
+    );
+
+    std::fs::remove_file(path).unwrap();
+}
+
+#[test]
+fn test_format_file_changed() {
+    let src = "func main()   int {\nreturn   0 }";
+    let path = temp_file("koi_format_changed.koi", src);
+
+    // Not written without the write flag
+    assert_eq!(format_file(&path, false, Config::test()), Ok(true));
+    assert_eq!(std::fs::read_to_string(&path).unwrap(), src);
+
+    assert_eq!(format_file(&path, true, Config::test()), Ok(true));
+    assert_eq!(
+        std::fs::read_to_string(&path).unwrap(),
+        "func main() int {\n    return 0\n}\n"
+    );
+    assert_eq!(format_file(&path, false, Config::test()), Ok(false));
+
+    std::fs::remove_file(path).unwrap();
+}
+
+#[test]
+fn test_format_file_errors() {
+    let path = temp_file("koi_format_parse_error.koi", "func main( {}");
+    assert!(format_file(&path, true, Config::test()).is_err());
+    assert_eq!(std::fs::read_to_string(&path).unwrap(), "func main( {}");
+    std::fs::remove_file(path).unwrap();
+
+    let path = temp_file("koi_format_comment.koi", "// comment\nfunc main() {}");
+    assert!(format_file(&path, true, Config::test()).is_err());
+    std::fs::remove_file(path).unwrap();
+
+    assert!(format_file("does/not/exist.koi", false, Config::test()).is_err());
+}
+
+/// Source in the standard format using escaped identifiers, literals in
+/// several forms, and a group of constants before other declarations.
+static FORMATTED_SRC: &str = r#"max :: 0xff
+mask u8 :: 0b1010_0101
+
+struct Point {
+    x int
+    #type int
+}
+
+func main() int {
+    #type := 1_000
+    s := "a\x41\tb"
+    f := .5
+    p := Point{x: 1, #type: 2}
+    return p.#type + #type
+}
+"#;
+
+#[test]
+fn test_format_file_keeps_source_forms() {
+    let path = temp_file("koi_format_source_forms.koi", FORMATTED_SRC);
+    assert_eq!(format_file(&path, true, Config::test()), Ok(false));
+    assert_eq!(std::fs::read_to_string(&path).unwrap(), FORMATTED_SRC);
+    std::fs::remove_file(path).unwrap();
+}
+
+/// Assert that formatting the source leaves it unchanged.
+fn assert_formatted(name: &str, src: &str) {
+    let path = temp_file(name, src);
+    assert_eq!(format_file(&path, true, Config::test()), Ok(false));
+    assert_eq!(std::fs::read_to_string(&path).unwrap(), src);
+    std::fs::remove_file(path).unwrap();
+}
+
+#[test]
+fn test_format_file_keeps_for_loops() {
+    assert_formatted(
+        "koi_format_for_loops.koi",
+        r#"func main() int {
+    x := 0
+    for x < 10 {
+        x += 1
+    }
+    for {
+        break
+    }
+    return x
+}
+"#,
+    );
+}
+
+#[test]
+fn test_format_file_keeps_arrow_body() {
+    assert_formatted(
+        "koi_format_arrow_body.koi",
+        "func sq(x int) int => x * x
+",
+    );
+}
+
+#[test]
+fn test_format_file_keeps_alias_eq() {
+    assert_formatted(
+        "koi_format_alias_eq.koi",
+        "type Celsius = f32
+type Id int
+",
+    );
+}
+
+#[test]
+fn test_format_file_round_trip() {
+    let src = r#"max::0xff
+mask   u8::0b1010_0101
+struct Point { x int, #type int }
+func main()int{
+#type:=1_000
+s:="a\x41\tb"
+f:=.5
+p:=Point{x:1,#type:2}
+return p.#type+#type }"#;
+
+    let path = temp_file("koi_format_round_trip.koi", src);
+    assert_eq!(format_file(&path, true, Config::test()), Ok(true));
+    assert_eq!(std::fs::read_to_string(&path).unwrap(), FORMATTED_SRC);
+
+    // Formatting the output again changes nothing
+    assert_eq!(format_file(&path, true, Config::test()), Ok(false));
+    std::fs::remove_file(path).unwrap();
+}
+
 #[test]
 fn test_exit0() {
     run_case_with_status("exit0", 0);
@@ -174,6 +578,11 @@ fn test_import() {
     run_case_with_status("import", 44);
 }
 
+#[test]
+fn test_global_const() {
+    run_case_with_status("global_const", 37);
+}
+
 #[test]
 fn test_library() {
     // Library compilation uses x86-64-specific infrastructure (entry.s, .a archives).
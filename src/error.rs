@@ -1,11 +1,22 @@
 use crate::common::{Pos, SourceMap, Span};
 
+#[cfg(test)]
+mod error_test;
+
 pub type Res<T> = Result<T, Diagnostics>;
 
 pub struct Report {
     pub message: String,
     info: Option<String>, // Additional info if any
     kind: ReportKind,
+    severity: Severity,
+}
+
+/// How severe a report is. Warnings do not stop compilation.
+#[derive(Clone, Copy, PartialEq, Eq, Debug)]
+pub enum Severity {
+    Error,
+    Warning,
 }
 
 enum ReportKind {
@@ -20,18 +31,28 @@ impl Report {
             message: msg.to_owned(),
             kind: ReportKind::Error,
             info: None,
+            severity: Severity::Error,
         }
     }
 
     /// Create new code error marking a section of code in the range from-to.
+    /// Only the first line is shown, so a range spanning several lines is
+    /// marked to the end of that line.
     pub fn code_error(msg: &str, from: &Pos, to: &Pos) -> Self {
+        let length = if to.row == from.row {
+            to.col.saturating_sub(from.col)
+        } else {
+            usize::MAX
+        };
+
         Self {
             message: msg.to_owned(),
             kind: ReportKind::CodeError {
                 pos: from.clone(),
-                length: to.col - from.col,
+                length,
             },
             info: None,
+            severity: Severity::Error,
         }
     }
 
@@ -44,6 +65,7 @@ impl Report {
                 length,
             },
             info: None,
+            severity: Severity::Error,
         }
     }
 
@@ -53,29 +75,62 @@ impl Report {
         self
     }
 
+    /// Turn this Report into a warning. Returns self for chaining.
+    pub fn as_warning(mut self) -> Self {
+        self.severity = Severity::Warning;
+        self
+    }
+
+    pub fn severity(&self) -> Severity {
+        self.severity
+    }
+
     fn render(&self, map: &SourceMap) -> String {
+        let label = match self.severity {
+            Severity::Error => "error",
+            Severity::Warning => "warning",
+        };
+
         match &self.kind {
-            ReportKind::Error => format!("error: {}", self.message),
+            ReportKind::Error => format!("{}: {}", label, self.message),
             ReportKind::CodeError { pos, length } => {
                 let source = map.get(pos.source_id).unwrap();
 
-                let line = pos.row + 1;
+                // Malformed positions are clamped to the source so
+                // formatting an error never panics
+                let row = pos.row.min(source.lines.len() - 1);
+                let line = row + 1;
                 let info = self.info.as_ref().map_or("", |s| s.as_str());
                 let length = *length;
 
-                let line_str = source.line(pos.row).to_owned();
-                let from = pos.col;
+                let line_str = source.line(row).to_owned();
+                let line_len = line_str.trim_end().chars().count();
+                let from = pos.col.min(line_str.chars().count());
+
+                // Never mark past the end of the line
+                let length = length.min(line_len.saturating_sub(from));
 
                 let pad = line_str.len() - line_str.trim_start().len();
                 let point_start = if from < pad { 1 } else { from - pad };
 
+                // Tabs before the caret are kept so it lines up with the
+                // source line however wide the terminal renders them
+                let caret_pad = line_str
+                    .trim()
+                    .chars()
+                    .map(|c| if c == '\t' { '\t' } else { ' ' })
+                    .chain(std::iter::repeat(' '))
+                    .take(point_start)
+                    .collect::<String>();
+
                 format!(
-                    "{}\nerror: {}\n    |\n{:<3} |    {}\n    |    {}{}\n{}",
+                    "{}\n{}: {}\n    |\n{:<3} |    {}\n    |    {}{}\n{}",
                     source.filepath,
+                    label,
                     self.message,
                     line,
                     line_str.trim(),
-                    " ".repeat(point_start),
+                    caret_pad,
                     "^".repeat(length.max(1)),
                     if !info.is_empty() {
                         let info = &info;
@@ -143,6 +198,20 @@ impl Diagnostics {
         &self.reports
     }
 
+    /// Move all reports from other into self.
+    pub fn extend(&mut self, other: Diagnostics) {
+        self.reports.extend(other.reports);
+    }
+
+    /// Sort reports by their position in source. Reports without a
+    /// position come first. Reports at the same position keep their order.
+    pub fn sort(&mut self) {
+        self.reports.sort_by_key(|report| match &report.kind {
+            ReportKind::Error => None,
+            ReportKind::CodeError { pos, .. } => Some((pos.source_id, pos.offset)),
+        });
+    }
+
     /// Returns self if containing errors, otherwise v.
     pub fn err_or<T>(self, v: T) -> Result<T, Diagnostics> {
         if !self.is_empty() { Err(self) } else { Ok(v) }
@@ -0,0 +1,57 @@
+use crate::{
+    common::{Pos, new_source_map},
+    error::Report,
+};
+
+/// Render a code error for the given (row, col) range and return the line
+/// holding the carets.
+fn render_caret(src: &str, from: (usize, usize), to: (usize, usize)) -> String {
+    let map = new_source_map(src);
+    let source_id = map.sources().last().unwrap().id;
+    let pos = |(row, col)| Pos {
+        row,
+        col,
+        offset: 0,
+        line_begin: 0,
+        source_id,
+    };
+
+    let report = Report::code_error("bad", &pos(from), &pos(to));
+    report.render(&map).lines().nth(4).unwrap().to_owned()
+}
+
+#[test]
+fn test_render_marks_range() {
+    let caret = render_caret("abc def", (0, 4), (0, 7));
+    assert_eq!(caret, format!("    |    {}^^^", " ".repeat(4)));
+}
+
+#[test]
+fn test_render_end_before_start_marks_one() {
+    let caret = render_caret("abc def", (0, 5), (0, 2));
+    assert_eq!(caret, format!("    |    {}^", " ".repeat(5)));
+}
+
+#[test]
+fn test_render_range_over_several_lines_marks_to_end_of_line() {
+    let caret = render_caret("abc def  \n}", (0, 4), (1, 1));
+    assert_eq!(caret, format!("    |    {}^^^", " ".repeat(4)));
+}
+
+#[test]
+fn test_render_col_past_end_of_line_is_clamped() {
+    let caret = render_caret("abc def", (0, 40), (0, 45));
+    assert_eq!(caret, format!("    |    {}^", " ".repeat(7)));
+}
+
+#[test]
+fn test_render_row_past_end_of_source_is_clamped() {
+    let caret = render_caret("abc\ndef", (9, 1), (9, 2));
+    assert_eq!(caret, format!("    |    {}^", " ".repeat(1)));
+}
+
+#[test]
+fn test_render_caret_keeps_tabs() {
+    let caret = render_caret("\tfoo\t:= bar", (0, 5), (0, 7));
+    assert_eq!(caret, "    |       \t^^");
+}
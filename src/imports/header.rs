@@ -1,5 +1,5 @@
 use std::{
-    collections::{HashMap, HashSet},
+    collections::{BTreeSet, HashMap},
     fs::read,
 };
 
@@ -58,7 +58,7 @@ impl HeaderFile {
             .exports()
             .values()
             .flat_map(|id| ctx.types.get_all_references(ctx.symbols.get(*id).ty))
-            .collect::<HashSet<_>>();
+            .collect::<BTreeSet<_>>();
 
         // Create a map from TypeId to HeaderTypeKind to store in the header file
         for ty in all_types_ids {
@@ -164,21 +164,31 @@ struct HeaderSymbol {
 }
 
 /// HeaderTypeKind is the header representation of a TypeKind from the type context.
+/// Variants are encoded by index, so new ones must be added at the end.
 #[derive(Debug, Serialize, Deserialize)]
 enum HeaderTypeKind {
     Primitive(HeaderPrimitiveType),
-    Array(Box<HeaderTypeKind>),
+    Array(Box<HeaderTypeKind>, usize),
     Pointer(Box<HeaderTypeKind>),
     Alias(Box<HeaderTypeKind>),
     Unique(String, Box<HeaderTypeKind>),
     Function(Vec<HeaderTypeKind>, Box<HeaderTypeKind>),
+    Struct(String, String, Vec<(String, HeaderTypeKind)>),
+    Slice(Box<HeaderTypeKind>),
+    Tuple(Vec<HeaderTypeKind>),
 }
 
 /// Convert real type kind into header type kind.
 fn real_to_header(ctx: &Context, kind: &TypeKind) -> HeaderTypeKind {
     match kind {
         TypeKind::Primitive(p) => HeaderTypeKind::Primitive(p.into()),
-        TypeKind::Array(id) => HeaderTypeKind::Array(boxed_kind(ctx, *id)),
+        TypeKind::Array(id, len) => HeaderTypeKind::Array(boxed_kind(ctx, *id), *len),
+        TypeKind::Slice(id) => HeaderTypeKind::Slice(boxed_kind(ctx, *id)),
+        TypeKind::Tuple(ids) => HeaderTypeKind::Tuple(
+            ids.iter()
+                .map(|id| real_to_header(ctx, &ctx.types.lookup(*id).kind))
+                .collect(),
+        ),
         TypeKind::Pointer(id) => HeaderTypeKind::Pointer(boxed_kind(ctx, *id)),
         TypeKind::Alias(id) => HeaderTypeKind::Alias(boxed_kind(ctx, *id)),
         TypeKind::Unique(name, id) => HeaderTypeKind::Unique(name.into(), boxed_kind(ctx, *id)),
@@ -191,6 +201,19 @@ fn real_to_header(ctx: &Context, kind: &TypeKind) -> HeaderTypeKind {
             let ret = boxed_kind(ctx, func.ret);
             HeaderTypeKind::Function(params, ret)
         }
+        TypeKind::Struct(s) => {
+            let fields = s
+                .fields
+                .iter()
+                .map(|(name, id)| {
+                    (
+                        name.clone(),
+                        real_to_header(ctx, &ctx.types.lookup(*id).kind),
+                    )
+                })
+                .collect();
+            HeaderTypeKind::Struct(s.name.clone(), s.module.clone(), fields)
+        }
     }
 }
 
@@ -202,7 +225,11 @@ fn boxed_kind(ctx: &Context, ty: TypeId) -> Box<HeaderTypeKind> {
 fn header_to_real(ctx: &mut Context, kind: &HeaderTypeKind) -> TypeId {
     let typekind = match kind {
         HeaderTypeKind::Primitive(p) => TypeKind::Primitive(p.into()),
-        HeaderTypeKind::Array(inner) => TypeKind::Array(header_to_real(ctx, inner)),
+        HeaderTypeKind::Array(inner, len) => TypeKind::Array(header_to_real(ctx, inner), *len),
+        HeaderTypeKind::Slice(inner) => TypeKind::Slice(header_to_real(ctx, inner)),
+        HeaderTypeKind::Tuple(kinds) => {
+            TypeKind::Tuple(kinds.iter().map(|k| header_to_real(ctx, k)).collect())
+        }
         HeaderTypeKind::Pointer(inner) => TypeKind::Pointer(header_to_real(ctx, inner)),
         HeaderTypeKind::Alias(inner) => TypeKind::Alias(header_to_real(ctx, inner)),
         HeaderTypeKind::Unique(name, inner) => {
@@ -216,6 +243,17 @@ fn header_to_real(ctx: &mut Context, kind: &HeaderTypeKind) -> TypeId {
                 ret: ret_id,
             })
         }
+        HeaderTypeKind::Struct(name, module, fields) => {
+            let fields = fields
+                .iter()
+                .map(|(name, kind)| (name.clone(), header_to_real(ctx, kind)))
+                .collect();
+            TypeKind::Struct(crate::types::StructType {
+                name: name.clone(),
+                module: module.clone(),
+                fields,
+            })
+        }
     };
     ctx.types.get_or_intern(typekind)
 }
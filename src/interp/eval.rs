@@ -0,0 +1,492 @@
+use std::collections::HashMap;
+
+use crate::{
+    common::{Span, VarTable},
+    context::Context,
+    error::{Report, error_span},
+    module::{ModuleId, ModuleKind},
+    types::{
+        self, AssignOp, BinaryOp, ElseBlock, Expr, LiteralKind, PrimitiveType, Stmt, TypeId,
+        TypeKind, TypedNode, UnaryOp,
+    },
+};
+
+/// Calls nested deeper than this are reported instead of overflowing the
+/// interpreters own stack. Each call takes several nested Rust calls, so this
+/// is kept low enough to fit in the default 2MB thread stack.
+const MAX_CALL_DEPTH: usize = 100;
+
+type Res<T> = Result<T, Report>;
+type EvalRes<T> = Result<T, Unwind>;
+
+/// Run the main function of a checked module by walking its typed AST and
+/// return the exit code. Only the scalar subset of the language is supported:
+/// numbers, booleans, strings, control flow, and calls to other functions in
+/// the module.
+pub fn interpret(ctx: &Context, id: ModuleId) -> Res<i64> {
+    let ModuleKind::Source { files, .. } = &ctx.modules.get(id).kind else {
+        return Err(Report::error("module has no source to interpret"));
+    };
+
+    let mut interp = Interpreter {
+        ctx,
+        funcs: HashMap::new(),
+        consts: HashMap::new(),
+        vars: VarTable::new(),
+        depth: 0,
+    };
+
+    for decl in files.iter().flat_map(|file| &file.ast.decls) {
+        match decl {
+            types::Decl::Func(node) => {
+                interp.funcs.insert(&node.name, node);
+            }
+            types::Decl::Const(node) => {
+                interp.consts.insert(&node.name, &node.value);
+            }
+            types::Decl::Extern(_) => {}
+        }
+    }
+
+    let Some(main) = interp.funcs.get("main").copied() else {
+        return Err(Report::error("no main function to interpret"));
+    };
+
+    match interp.call(main, Vec::new(), &main.meta)? {
+        Value::Int(n) => Ok(n),
+        _ => Ok(0),
+    }
+}
+
+/// A runtime value. Integers of all sizes are stored as i64 and wrapped to
+/// the width of their type after each operation.
+#[derive(Debug, Clone, PartialEq)]
+enum Value {
+    Int(i64),
+    Float(f64),
+    Bool(bool),
+    String(String),
+    Void,
+}
+
+/// How control leaves a statement.
+enum Flow {
+    Next,
+    Return(Value),
+    Break,
+    Continue,
+}
+
+/// Why evaluating an expression stopped early. Control flow leaving a block
+/// expression unwinds to the statement containing it.
+enum Unwind {
+    Error(Report),
+    Flow(Flow),
+}
+
+impl From<Report> for Unwind {
+    fn from(err: Report) -> Self {
+        Unwind::Error(err)
+    }
+}
+
+struct Interpreter<'a> {
+    ctx: &'a Context,
+    funcs: HashMap<&'a str, &'a types::FuncNode>,
+    consts: HashMap<&'a str, &'a types::LiteralNode>,
+    /// Variables of the function currently running.
+    vars: VarTable<Value>,
+    /// Number of calls currently running.
+    depth: usize,
+}
+
+impl<'a> Interpreter<'a> {
+    fn call(&mut self, func: &'a types::FuncNode, args: Vec<Value>, span: &dyn Span) -> Res<Value> {
+        if self.depth >= MAX_CALL_DEPTH {
+            return Err(error_span("maximum call depth exceeded", span));
+        }
+
+        // Each call gets its own variables, the caller's are restored after
+        let mut vars = VarTable::new();
+        for (param, value) in func.params.iter().zip(args) {
+            vars.bind(param.name.clone(), value);
+        }
+
+        let caller_vars = std::mem::replace(&mut self.vars, vars);
+        self.depth += 1;
+        let flow = self.exec_stmts(&func.body.stmts);
+        self.depth -= 1;
+        self.vars = caller_vars;
+
+        match flow? {
+            Flow::Return(value) => Ok(value),
+            _ => Ok(Value::Void),
+        }
+    }
+
+    fn exec_block(&mut self, block: &types::BlockNode) -> Res<Flow> {
+        self.vars.push_scope();
+        let flow = self.exec_stmts(&block.stmts);
+        self.vars.pop_scope();
+        flow
+    }
+
+    fn exec_stmts(&mut self, stmts: &[Stmt]) -> Res<Flow> {
+        for stmt in stmts {
+            match self.exec_stmt(stmt)? {
+                Flow::Next => {}
+                flow => return Ok(flow),
+            }
+        }
+        Ok(Flow::Next)
+    }
+
+    fn exec_stmt(&mut self, stmt: &Stmt) -> Res<Flow> {
+        match self.try_exec_stmt(stmt) {
+            Ok(flow) | Err(Unwind::Flow(flow)) => Ok(flow),
+            Err(Unwind::Error(err)) => Err(err),
+        }
+    }
+
+    fn try_exec_stmt(&mut self, stmt: &Stmt) -> EvalRes<Flow> {
+        match stmt {
+            Stmt::Return(node) => {
+                let value = match &node.expr {
+                    Some(expr) => self.eval(expr)?,
+                    None => Value::Void,
+                };
+                return Ok(Flow::Return(value));
+            }
+            Stmt::VarDecl(node) => {
+                let value = match &node.value {
+                    Some(expr) => self.eval(expr)?,
+                    None => self.zero_value(node.ty, &node.meta)?,
+                };
+                self.vars.bind(node.name.clone(), value);
+            }
+            Stmt::VarAssign(node) => {
+                let value = self.eval(&node.rval)?;
+                self.assign(&node.lval, value)?;
+            }
+            Stmt::OpAssign(node) => {
+                let lhs = self.eval(&node.lval)?;
+                let rhs = self.eval(&node.rval)?;
+                let op = match node.op {
+                    AssignOp::Plus => BinaryOp::Plus,
+                    AssignOp::Minus => BinaryOp::Minus,
+                    AssignOp::Mult => BinaryOp::Mult,
+                    AssignOp::Div => BinaryOp::Divide,
+                };
+                let value = self.arithmetic(&op, lhs, rhs, node.ty, &node.meta)?;
+                self.assign(&node.lval, value)?;
+            }
+            Stmt::ExprStmt(expr) => {
+                self.eval(expr)?;
+            }
+            Stmt::If(node) => return self.exec_if(node),
+            Stmt::While(node) => {
+                while self.eval_bool(&node.expr)? {
+                    match self.exec_block(&node.block)? {
+                        Flow::Return(value) => return Ok(Flow::Return(value)),
+                        Flow::Break => break,
+                        Flow::Next | Flow::Continue => {}
+                    }
+                }
+            }
+            Stmt::For(node) => {
+                // The initializer is scoped to the loop
+                self.vars.push_scope();
+                let flow = self.exec_for(node);
+                self.vars.pop_scope();
+                return flow;
+            }
+            Stmt::Break(_) => return Ok(Flow::Break),
+            Stmt::Continue(_) => return Ok(Flow::Continue),
+        }
+
+        Ok(Flow::Next)
+    }
+
+    fn exec_if(&mut self, node: &types::IfNode) -> EvalRes<Flow> {
+        // Variables declared in the init statement are visible in all branches
+        self.vars.push_scope();
+        let flow = self.exec_if_branches(node);
+        self.vars.pop_scope();
+        flow
+    }
+
+    fn exec_if_branches(&mut self, node: &types::IfNode) -> EvalRes<Flow> {
+        if let Some(init) = &node.init {
+            self.try_exec_stmt(init)?;
+        }
+
+        if self.eval_bool(&node.expr)? {
+            return Ok(self.exec_block(&node.block)?);
+        }
+
+        match &*node.elseif {
+            ElseBlock::ElseIf(node) => self.exec_if(node),
+            ElseBlock::Else(block) => Ok(self.exec_block(block)?),
+            ElseBlock::None => Ok(Flow::Next),
+        }
+    }
+
+    fn exec_for(&mut self, node: &types::ForNode) -> EvalRes<Flow> {
+        self.try_exec_stmt(&node.initializer)?;
+
+        while self.eval_bool(&node.condition)? {
+            match self.exec_block(&node.block)? {
+                Flow::Return(value) => return Ok(Flow::Return(value)),
+                Flow::Break => break,
+                Flow::Next | Flow::Continue => {}
+            }
+            self.try_exec_stmt(&node.increment)?;
+        }
+
+        Ok(Flow::Next)
+    }
+
+    fn assign(&mut self, lval: &Expr, value: Value) -> Res<()> {
+        let slot = lval
+            .try_identifier()
+            .and_then(|name| self.vars.get_mut(name));
+        match slot {
+            Some(slot) => {
+                *slot = value;
+                Ok(())
+            }
+            None => Err(unsupported("assignment target", lval)),
+        }
+    }
+
+    fn eval_bool(&mut self, expr: &Expr) -> EvalRes<bool> {
+        match self.eval(expr)? {
+            Value::Bool(b) => Ok(b),
+            _ => Err(error_span("expected a boolean value", expr).into()),
+        }
+    }
+
+    fn eval(&mut self, expr: &Expr) -> EvalRes<Value> {
+        match expr {
+            Expr::Literal(node) => self.eval_literal(node),
+            Expr::Call(node) => self.eval_call(node),
+            Expr::Binary(node) => self.eval_binary(node),
+            Expr::Unary(node) => {
+                let rhs = self.eval(&node.rhs)?;
+                match (&node.op, rhs) {
+                    (UnaryOp::Minus, Value::Int(n)) => {
+                        Ok(Value::Int(self.wrap(n.wrapping_neg(), node.ty)))
+                    }
+                    (UnaryOp::Minus, Value::Float(n)) => Ok(Value::Float(-n)),
+                    (UnaryOp::LogicNot, Value::Bool(b)) => Ok(Value::Bool(!b)),
+                    _ => Err(unsupported("unary operand", expr).into()),
+                }
+            }
+            Expr::Ternary(node) => {
+                if self.eval_bool(&node.cond)? {
+                    self.eval(&node.then)
+                } else {
+                    self.eval(&node.otherwise)
+                }
+            }
+            Expr::Block(node) => {
+                self.vars.push_scope();
+                let value = match self.exec_stmts(&node.block.stmts) {
+                    Ok(Flow::Next) => self.eval(&node.expr),
+                    Ok(flow) => Err(Unwind::Flow(flow)),
+                    Err(err) => Err(err.into()),
+                };
+                self.vars.pop_scope();
+                value
+            }
+            Expr::Cast(node) => {
+                let value = self.eval(&node.expr)?;
+                Ok(self.cast(value, node.ty, expr)?)
+            }
+            Expr::Member(_) => Err(unsupported("member access", expr).into()),
+            Expr::NamespaceMember(_) => Err(unsupported("imported symbol", expr).into()),
+            Expr::Index(_) => Err(unsupported("indexing", expr).into()),
+            Expr::StructLit(_) => Err(unsupported("struct literal", expr).into()),
+            Expr::Tuple(_) => Err(unsupported("multiple return values", expr).into()),
+        }
+    }
+
+    fn eval_literal(&mut self, node: &types::LiteralNode) -> EvalRes<Value> {
+        Ok(match &node.kind {
+            LiteralKind::Ident(name) => {
+                if let Some(value) = self.vars.get(name) {
+                    return Ok(value.clone());
+                }
+                match self.consts.get(name.as_str()) {
+                    Some(value) => return self.eval_literal(value),
+                    None => return Err(unsupported("reference to function", &node.meta).into()),
+                }
+            }
+            LiteralKind::Int(n) => Value::Int(*n),
+            LiteralKind::Uint(n) => Value::Int(*n as i64),
+            LiteralKind::Char(c) => Value::Int(*c as i64),
+            LiteralKind::Float(n) => Value::Float(*n),
+            LiteralKind::Bool(b) => Value::Bool(*b),
+            LiteralKind::String(s) => Value::String(s.clone()),
+            LiteralKind::Null => return Err(unsupported("null", &node.meta).into()),
+        })
+    }
+
+    fn eval_call(&mut self, node: &types::CallNode) -> EvalRes<Value> {
+        let func = node
+            .callee
+            .try_identifier()
+            .and_then(|name| self.funcs.get(name).copied())
+            .ok_or_else(|| unsupported("call to extern or imported function", &*node.callee))?;
+
+        let args = node
+            .args
+            .iter()
+            .map(|arg| self.eval(arg))
+            .collect::<EvalRes<Vec<_>>>()?;
+
+        Ok(self.call(func, args, &node.meta)?)
+    }
+
+    fn eval_binary(&mut self, node: &types::BinaryNode) -> EvalRes<Value> {
+        // Logical operators short circuit
+        match node.op {
+            BinaryOp::LogicAnd => {
+                return Ok(Value::Bool(
+                    self.eval_bool(&node.lhs)? && self.eval_bool(&node.rhs)?,
+                ));
+            }
+            BinaryOp::LogicOr => {
+                return Ok(Value::Bool(
+                    self.eval_bool(&node.lhs)? || self.eval_bool(&node.rhs)?,
+                ));
+            }
+            _ => {}
+        }
+
+        let unsigned = self.is_uint(node.lhs.type_id());
+        let lhs = self.eval(&node.lhs)?;
+        let rhs = self.eval(&node.rhs)?;
+
+        let ordering = match (&lhs, &rhs) {
+            (Value::Int(a), Value::Int(b)) if unsigned => (*a as u64).partial_cmp(&(*b as u64)),
+            (Value::Int(a), Value::Int(b)) => a.partial_cmp(b),
+            (Value::Float(a), Value::Float(b)) => a.partial_cmp(b),
+            _ => None,
+        };
+
+        let result = match node.op {
+            BinaryOp::Equal => lhs == rhs,
+            BinaryOp::NotEqual => lhs != rhs,
+            BinaryOp::Greater => ordering.is_some_and(|o| o.is_gt()),
+            BinaryOp::GreaterEq => ordering.is_some_and(|o| o.is_ge()),
+            BinaryOp::Less => ordering.is_some_and(|o| o.is_lt()),
+            BinaryOp::LessEq => ordering.is_some_and(|o| o.is_le()),
+            _ => return Ok(self.arithmetic(&node.op, lhs, rhs, node.ty, &node.meta)?),
+        };
+
+        Ok(Value::Bool(result))
+    }
+
+    fn arithmetic(
+        &self,
+        op: &BinaryOp,
+        lhs: Value,
+        rhs: Value,
+        ty: TypeId,
+        span: &dyn Span,
+    ) -> Res<Value> {
+        match (lhs, rhs) {
+            (Value::Int(a), Value::Int(b)) => {
+                if b == 0 && matches!(op, BinaryOp::Divide | BinaryOp::Modulo) {
+                    return Err(error_span("division by zero", span));
+                }
+
+                let n = match op {
+                    BinaryOp::Plus => a.wrapping_add(b),
+                    BinaryOp::Minus => a.wrapping_sub(b),
+                    BinaryOp::Mult => a.wrapping_mul(b),
+                    BinaryOp::Divide if self.is_uint(ty) => ((a as u64) / (b as u64)) as i64,
+                    BinaryOp::Modulo if self.is_uint(ty) => ((a as u64) % (b as u64)) as i64,
+                    BinaryOp::Divide => a.wrapping_div(b),
+                    BinaryOp::Modulo => a.wrapping_rem(b),
+                    _ => return Err(unsupported("operator", span)),
+                };
+                Ok(Value::Int(self.wrap(n, ty)))
+            }
+            (Value::Float(a), Value::Float(b)) => Ok(Value::Float(match op {
+                BinaryOp::Plus => a + b,
+                BinaryOp::Minus => a - b,
+                BinaryOp::Mult => a * b,
+                BinaryOp::Divide => a / b,
+                BinaryOp::Modulo => a % b,
+                _ => return Err(unsupported("operator", span)),
+            })),
+            _ => Err(unsupported("operands", span)),
+        }
+    }
+
+    fn cast(&self, value: Value, ty: TypeId, span: &dyn Span) -> Res<Value> {
+        let Some(p) = self.primitive(ty) else {
+            return Err(unsupported("cast", span));
+        };
+
+        Ok(match value {
+            Value::Int(n) if p.is_float() => Value::Float(n as f64),
+            Value::Float(n) if p.is_float() => Value::Float(n),
+            Value::Int(n) => Value::Int(self.wrap(n, ty)),
+            Value::Float(n) => Value::Int(self.wrap(n as i64, ty)),
+            value => value,
+        })
+    }
+
+    fn zero_value(&self, ty: TypeId, span: &dyn Span) -> Res<Value> {
+        match self.primitive(ty) {
+            Some(p) if p.is_int() || p.is_uint() || p == PrimitiveType::Byte => Ok(Value::Int(0)),
+            Some(p) if p.is_float() => Ok(Value::Float(0.0)),
+            Some(PrimitiveType::Bool) => Ok(Value::Bool(false)),
+            Some(PrimitiveType::String) => Ok(Value::String(String::new())),
+            _ => Err(unsupported("zero value of this type", span)),
+        }
+    }
+
+    /// Wrap an integer to the width of its type, like it would overflow at
+    /// runtime.
+    fn wrap(&self, n: i64, ty: TypeId) -> i64 {
+        let Some(p) = self.primitive(ty) else {
+            return n;
+        };
+
+        let bits = p.bytes() as u32 * 8;
+        if !(p.is_int() || p.is_uint() || p == PrimitiveType::Byte) || bits >= 64 {
+            return n;
+        }
+
+        let shift = 64 - bits;
+        if p.is_int() {
+            (n << shift) >> shift
+        } else {
+            ((n as u64) << shift >> shift) as i64
+        }
+    }
+
+    fn is_uint(&self, ty: TypeId) -> bool {
+        self.primitive(ty)
+            .is_some_and(|p| p.is_uint() || p == PrimitiveType::Byte)
+    }
+
+    fn primitive(&self, ty: TypeId) -> Option<PrimitiveType> {
+        let ty = self.ctx.types.deep_resolve(ty);
+        match &self.ctx.types.lookup(ty).kind {
+            TypeKind::Primitive(p) => Some(p.clone()),
+            _ => None,
+        }
+    }
+}
+
+fn unsupported(what: &str, span: &dyn Span) -> Report {
+    error_span(
+        &format!("{} is not supported by the interpreter yet", what),
+        span,
+    )
+}
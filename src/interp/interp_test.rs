@@ -0,0 +1,181 @@
+use crate::{
+    common::{must, must_check},
+    interp::interpret,
+};
+
+fn run(src: &str) -> i64 {
+    let (ctx, id) = must_check(src);
+    must(interpret(&ctx, id).map_err(|err| err.message))
+}
+
+fn run_error(src: &str) -> String {
+    let (ctx, id) = must_check(src);
+    interpret(&ctx, id).unwrap_err().message
+}
+
+#[test]
+fn test_return_literal() {
+    let src = r#"
+        func main() int {
+            return 42
+        }
+    "#;
+    assert_eq!(run(src), 42);
+}
+
+#[test]
+fn test_call_other_function() {
+    let src = r#"
+        func add(a int, b int) int {
+            return a + b
+        }
+
+        func main() int {
+            return add(40, 2)
+        }
+    "#;
+    assert_eq!(run(src), 42);
+}
+
+#[test]
+fn test_recursion() {
+    let src = r#"
+        func fib(n int) int {
+            if n < 2 {
+                return n
+            }
+            return fib(n - 1) + fib(n - 2)
+        }
+
+        func main() int {
+            return fib(10)
+        }
+    "#;
+    assert_eq!(run(src), 55);
+}
+
+#[test]
+fn test_loops_and_assignment() {
+    let src = r#"
+        func main() int {
+            sum := 0
+            for i := 0; i < 10; i += 1 {
+                if i == 5 {
+                    continue
+                }
+                sum += i
+            }
+
+            n := 0
+            while true {
+                n += 1
+                if n == 3 {
+                    break
+                }
+            }
+
+            return sum + n
+        }
+    "#;
+    assert_eq!(run(src), 43);
+}
+
+#[test]
+fn test_constants_and_ternary() {
+    let src = r#"
+        max :: 10
+
+        func main() int {
+            big := max > 5
+            return big ? max * 2 : 0
+        }
+    "#;
+    assert_eq!(run(src), 20);
+}
+
+#[test]
+fn test_integer_wraps_to_type() {
+    let src = r#"
+        func main() int {
+            a := 250 as u8
+            a += 10 as u8
+            return a as int
+        }
+    "#;
+    assert_eq!(run(src), 4);
+}
+
+#[test]
+fn test_return_from_block_expr() {
+    let src = r#"
+        func main() int {
+            x := {
+                if true {
+                    return 7
+                }
+                2
+            }
+            return x
+        }
+    "#;
+    assert_eq!(run(src), 7);
+}
+
+#[test]
+fn test_break_from_block_expr() {
+    let src = r#"
+        func main() int {
+            n := 0
+            while true {
+                n += 1
+                x := {
+                    if n == 3 {
+                        break
+                    }
+                    n
+                }
+                n = x
+            }
+            return n
+        }
+    "#;
+    assert_eq!(run(src), 3);
+}
+
+#[test]
+fn test_division_by_zero_error() {
+    let src = r#"
+        func div(a int, b int) int {
+            return a / b
+        }
+
+        func main() int {
+            return div(1, 0)
+        }
+    "#;
+    assert_eq!(run_error(src), "division by zero");
+}
+
+#[test]
+fn test_infinite_recursion_error() {
+    let src = r#"
+        func f() int {
+            return f()
+        }
+
+        func main() int {
+            return f()
+        }
+    "#;
+    assert_eq!(run_error(src), "maximum call depth exceeded");
+}
+
+#[test]
+fn test_missing_main_error() {
+    let src = r#"
+        func f() int {
+            return 1
+        }
+    "#;
+    assert_eq!(run_error(src), "no main function to interpret");
+}
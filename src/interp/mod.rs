@@ -0,0 +1,6 @@
+mod eval;
+
+pub use eval::interpret;
+
+#[cfg(test)]
+mod interp_test;
@@ -15,6 +15,8 @@ pub struct Unit {
     pub decls: Vec<Decl>,
     /// Data segments used in this unit
     pub data: Vec<Data>,
+    /// Global constants declared or referenced in this unit
+    pub globals: Vec<Global>,
 }
 
 /// Unique ID of a constant value
@@ -28,6 +30,18 @@ pub enum Data {
     String(String),
 }
 
+pub struct Global {
+    /// Is this global public (outside of comp unit)
+    pub public: bool,
+    /// Name of global
+    pub name: String,
+    /// Type of the value
+    pub ty: IRTypeId,
+    /// Constant value, a number or data segment. None if the
+    /// global is declared in another unit.
+    pub value: Option<RValue>,
+}
+
 pub enum Decl {
     Extern(ExternDecl),
     Func(FuncDecl),
@@ -225,6 +239,7 @@ pub enum RValue {
     Param(usize),
     Function(String),
     Data(DataIndex),
+    Global(String),
 }
 
 impl fmt::Display for IntrinsicKind {
@@ -246,6 +261,7 @@ impl fmt::Display for RValue {
             RValue::Param(s) => write!(f, "%{}", s),
             RValue::Function(s) => write!(f, "{}", s),
             RValue::Data(s) => write!(f, ".{}", s),
+            RValue::Global(s) => write!(f, "@{}", s),
         }
     }
 }
@@ -1,12 +1,43 @@
-use crate::ir::{Decl, Ins, Unit};
+use std::fmt;
+
+use crate::{
+    ast::escape_string,
+    ir::{Data, Decl, Ins, Unit},
+};
 
 pub fn print_ir(unit: &Unit) {
-    println!("{}", unit_to_string(unit));
+    println!("{}", unit);
+}
+
+impl fmt::Display for Unit {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "{}", unit_to_string(self))
+    }
 }
 
 pub fn unit_to_string(unit: &Unit) -> String {
     let mut s = String::new();
 
+    for (i, data) in unit.data.iter().enumerate() {
+        match data {
+            Data::String(content) => {
+                s += &format!("data .{} \"{}\"\n", i, escape_string(content));
+            }
+        }
+    }
+
+    for global in &unit.globals {
+        let ty = unit.types.type_to_string(global.ty);
+        s += &match &global.value {
+            Some(value) => format!("global @{} {} = {}\n", global.name, ty, value),
+            None => format!("extern global @{} {}\n", global.name, ty),
+        };
+    }
+
+    if !unit.data.is_empty() || !unit.globals.is_empty() {
+        s += "\n";
+    }
+
     for decl in &unit.decls {
         match decl {
             Decl::Extern(func) => {
@@ -150,9 +181,25 @@ fn ins_to_string(unit: &Unit, ins: &Ins, indent: usize) -> String {
             })
         ),
         Ins::While(ins) => format!(
-            "while {}\n{}",
-            ins.cond,
+            "while {}\n{}{}",
+            if ins.cond_ins.is_empty() {
+                ins.cond.to_string()
+            } else {
+                format!(
+                    "(\n{}{}): {}",
+                    ins_to_string_indent(unit, &ins.cond_ins, indent + 1),
+                    "    ".repeat(indent),
+                    ins.cond
+                )
+            },
             ins_to_string_indent(unit, &ins.block.ins, indent + 1),
+            ins.post.as_ref().map_or("".into(), |post| {
+                format!(
+                    "{}post\n{}",
+                    "    ".repeat(indent),
+                    ins_to_string_indent(unit, post, indent + 1)
+                )
+            })
         ),
         Ins::Conditional(ins) => format!(
             "${} = cond {} {} {}\n{}\n{}",
@@ -163,7 +210,6 @@ fn ins_to_string(unit: &Unit, ins: &Ins, indent: usize) -> String {
             ins_to_string_indent(unit, &ins.lhs_ins, indent + 1),
             ins_to_string_indent(unit, &ins.rhs_ins, indent + 1)
         ),
-        // TODO: print cond ins
         _ => ins_to_string_oneline(unit, ins),
     }
 }
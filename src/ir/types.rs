@@ -2,7 +2,7 @@ use std::{collections::HashMap, fmt};
 
 use crate::{
     context::Context,
-    types::{self, TypeId},
+    types::{self, PTR_SIZE, TypeId},
 };
 
 #[derive(Debug, Clone, PartialEq, Eq, Hash)]
@@ -48,8 +48,6 @@ impl From<types::PrimitiveType> for Primitive {
     }
 }
 
-const PTR_SIZE: usize = 8;
-
 impl IRType {
     /// Get size of type in bytes
     pub fn size(&self) -> usize {
@@ -61,9 +59,20 @@ impl IRType {
                 Primitive::F32 | Primitive::I32 | Primitive::U32 => 4,
                 Primitive::F64 | Primitive::U64 | Primitive::I64 | Primitive::String => PTR_SIZE,
             },
-            IRType::Function(_, _) => 8,
+            IRType::Function(_, _) => PTR_SIZE,
         }
     }
+
+    /// Get required alignment of type in bytes
+    pub fn align(&self) -> usize {
+        self.size().max(1)
+    }
+}
+
+/// Round n up to the nearest multiple of align, which must be a power of two.
+pub fn align_to(n: usize, align: usize) -> usize {
+    debug_assert!(align.is_power_of_two());
+    (n + align - 1) & !(align - 1)
 }
 
 impl fmt::Display for IRType {
@@ -115,12 +124,16 @@ impl IRTypeInterner {
         id
     }
 
-    pub fn to_ir_type_list(&mut self, ctx: &Context, list: &[TypeId]) -> Vec<IRTypeId> {
+    /// Convert a list of types to IR. Returns None if any of them cannot be
+    /// represented in IR.
+    pub fn to_ir_type_list(&mut self, ctx: &Context, list: &[TypeId]) -> Option<Vec<IRTypeId>> {
         list.iter().map(|ty| self.to_ir(ctx, *ty)).collect()
     }
 
-    pub fn to_ir(&mut self, ctx: &Context, id: TypeId) -> IRTypeId {
-        self.get_or_intern(to_ir_type(ctx, id))
+    /// Convert a type to IR. Returns None if the type cannot be represented
+    /// in IR yet, eg. arrays and pointers.
+    pub fn to_ir(&mut self, ctx: &Context, id: TypeId) -> Option<IRTypeId> {
+        to_ir_type(ctx, id).map(|ty| self.get_or_intern(ty))
     }
 
     pub fn dump(&self) -> String {
@@ -141,21 +154,28 @@ impl IRTypeInterner {
         ty.size()
     }
 
+    pub fn alignof(&self, id: IRTypeId) -> usize {
+        self.types[id].align()
+    }
+
     pub fn get(&self, id: IRTypeId) -> &IRType {
         &self.types[id]
     }
 }
 
-fn to_ir_type(ctx: &Context, id: TypeId) -> IRType {
+fn to_ir_type(ctx: &Context, id: TypeId) -> Option<IRType> {
     let id = ctx.types.deep_resolve(id);
     let ty = ctx.types.lookup(id);
 
     match &ty.kind {
-        types::TypeKind::Primitive(p) => IRType::Primitive(p.clone().into()),
-        types::TypeKind::Function(f) => IRType::Function(
-            f.params.iter().map(|p| to_ir_type(ctx, *p)).collect(),
-            Box::new(to_ir_type(ctx, f.ret)),
-        ),
-        _ => panic!("unhandled kind {:?}", ty.kind),
+        types::TypeKind::Primitive(p) => Some(IRType::Primitive(p.clone().into())),
+        types::TypeKind::Function(f) => Some(IRType::Function(
+            f.params
+                .iter()
+                .map(|p| to_ir_type(ctx, *p))
+                .collect::<Option<_>>()?,
+            Box::new(to_ir_type(ctx, f.ret)?),
+        )),
+        _ => None,
     }
 }
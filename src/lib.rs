@@ -7,6 +7,7 @@ pub mod context;
 pub mod driver;
 pub mod error;
 pub mod imports;
+pub mod interp;
 pub mod ir;
 pub mod lower;
 pub mod module;
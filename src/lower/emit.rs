@@ -1,27 +1,35 @@
 use std::collections::{HashMap, HashSet};
 
 use crate::{
-    common::VarTable,
+    common::{Span, VarTable},
     context::Context,
-    error::{self, Diagnostics, Report},
+    error::{self, Diagnostics, Report, error_span},
     ir::{
         AssignIns, BinaryIns, Block, CallIns, CastIns, CondIns, ConstId, Data, DataIndex, Decl,
-        ElseIf, ExternDecl, FuncDecl, IRBinaryOp, IRCondOp, IRType, IRTypeInterner, IRUnaryOp,
-        IfIns, Ins, LValue, ParamId, Primitive, RValue, StoreIns, UnaryIns, Unit, WhileIns,
+        ElseIf, ExternDecl, FuncDecl, Global, IRBinaryOp, IRCondOp, IRType, IRTypeId,
+        IRTypeInterner, IRUnaryOp, IfIns, Ins, LValue, ParamId, Primitive, RValue, StoreIns,
+        UnaryIns, Unit, WhileIns, align_to,
     },
     module::{
         Module, ModuleId, ModuleKind, ModuleSourceFile, NamespaceList, Symbol, SymbolId,
         SymbolKind, SymbolList, SymbolOrigin,
     },
-    types::{self, CastKind, Expr, LiteralKind, TypedAst, TypedNode},
+    types::{self, CastKind, Expr, LiteralKind, NodeMeta, TypeId, TypedAst, TypedNode, ZeroValue},
 };
 
 /// Emit standalone module IR unit for this module.
 pub fn emit_ir(ctx: &Context, id: ModuleId) -> error::Res<Unit> {
+    let (unit, diag) = emit_ir_partial(ctx, id);
+    diag.err_or(unit)
+}
+
+/// Emit IR for this module, skipping functions which could not be lowered.
+/// Returns the partial unit along with errors for each skipped function, eg.
+/// ones using constructs not yet supported by codegen.
+pub fn emit_ir_partial(ctx: &Context, id: ModuleId) -> (Unit, Diagnostics) {
     let module = ctx.modules.get(id);
     let emitter = ModuleEmitter::new(ctx, module);
-    let unit = emitter.emit()?;
-    Ok(unit)
+    emitter.emit()
 }
 
 type Res<T> = Result<T, Report>;
@@ -81,28 +89,43 @@ impl<'a> ModuleEmitter<'a> {
     }
 
     /// Emit IR for all module files and create bundled IR unit.
-    fn emit(mut self) -> error::Res<Unit> {
+    fn emit(mut self) -> (Unit, Diagnostics) {
         let ModuleKind::Source { files, .. } = &self.module.kind else {
             unreachable!();
         };
 
+        let mut diag = Diagnostics::new();
         let mut data = DataInterner::new();
         let mut externs = HashSet::new();
         let mut decls = Vec::new();
+        let mut globals = Vec::new();
+
+        // Values of constants declared in any file of the module
+        let consts = files
+            .iter()
+            .flat_map(|file| &file.ast.decls)
+            .filter_map(|decl| match decl {
+                types::Decl::Const(node) => Some((node.name.as_str(), &node.value)),
+                _ => None,
+            })
+            .collect::<HashMap<_, _>>();
 
         // Emit IR for each file in the module
         for file in files {
             let emitter = FileEmitter::new(
                 self.ctx,
                 &self.module.symbols,
+                &consts,
                 &mut self.types,
                 file,
                 &mut data,
             );
 
-            let result = emitter.emit()?;
+            let (result, file_diag) = emitter.emit();
             decls.extend(result.decls);
+            globals.extend(result.globals);
             externs.extend(result.externs);
+            diag.extend(file_diag);
         }
 
         // Declare all imported symbols as extern
@@ -117,25 +140,49 @@ impl<'a> ModuleEmitter<'a> {
         );
 
         for id in externs {
-            let mut diag = Diagnostics::new();
+            // Constants from other modules are referenced as extern globals
+            if matches!(self.ctx.symbols.get(id).kind, SymbolKind::Constant) {
+                match self.emit_extern_global(id) {
+                    Ok(global) => globals.push(global),
+                    Err(report) => diag.add(report),
+                }
+                continue;
+            }
 
             match self.emit_extern(id) {
                 Ok(decl) => extern_decls.push(decl),
                 Err(report) => diag.add(report),
             }
-
-            if !diag.is_empty() {
-                return Err(diag);
-            }
         }
 
         extern_decls.extend(decls);
 
-        Ok(Unit {
+        let unit = Unit {
             name: self.module.modpath.to_underscore(),
             data: data.into_data(),
             types: self.types,
             decls: extern_decls,
+            globals,
+        };
+
+        (unit, diag)
+    }
+
+    fn emit_extern_global(&mut self, id: SymbolId) -> Res<Global> {
+        let symbol = self.ctx.symbols.get(id);
+        let ty = self.types.to_ir(self.ctx, symbol.ty).ok_or_else(|| {
+            Report::error(&format!(
+                "constant '{}' has type '{}' which is not supported by codegen yet",
+                symbol.name,
+                self.ctx.types.type_to_string(symbol.ty)
+            ))
+        })?;
+
+        Ok(Global {
+            public: false,
+            name: mangle_symbol_name(self.ctx, symbol),
+            ty,
+            value: None,
         })
     }
 
@@ -143,10 +190,21 @@ impl<'a> ModuleEmitter<'a> {
         let symbol = self.ctx.symbols.get(id);
         let func = self.ctx.types.try_function(symbol.ty).unwrap();
 
+        let unsupported = || {
+            Report::error(&format!(
+                "extern '{}' has type '{}' which is not supported by codegen yet",
+                symbol.name,
+                self.ctx.types.type_to_string(symbol.ty)
+            ))
+        };
+
+        let params = self.types.to_ir_type_list(self.ctx, &func.params);
+        let ret = self.types.to_ir(self.ctx, func.ret);
+
         Ok(Decl::Extern(ExternDecl {
             name: mangle_symbol_name(self.ctx, symbol),
-            params: self.types.to_ir_type_list(self.ctx, &func.params),
-            ret: self.types.to_ir(self.ctx, func.ret),
+            params: params.ok_or_else(unsupported)?,
+            ret: ret.ok_or_else(unsupported)?,
         }))
     }
 }
@@ -160,7 +218,12 @@ pub struct FileEmitter<'a> {
     nsl: &'a NamespaceList,
     ast: &'a TypedAst,
     data: &'a mut DataInterner,
+    /// Values of constants declared in this module, used in place of
+    /// references to them.
+    consts: &'a HashMap<&'a str, &'a types::LiteralNode>,
 
+    /// Next constant id, reset for each function so every function body
+    /// numbers its registers from $0.
     const_id: ConstId,
     vars: VarTable<ConstId>,
     params: VarTable<ParamId>,
@@ -175,12 +238,15 @@ struct EmitResult {
     externs: HashSet<SymbolId>,
     /// Top-level declarations.
     decls: Vec<Decl>,
+    /// Global constants declared in this file.
+    globals: Vec<Global>,
 }
 
 impl<'a> FileEmitter<'a> {
     fn new(
         ctx: &'a Context,
         symbols: &'a SymbolList,
+        consts: &'a HashMap<&'a str, &'a types::LiteralNode>,
         types: &'a mut IRTypeInterner,
         file: &'a ModuleSourceFile,
         data: &'a mut DataInterner,
@@ -188,6 +254,7 @@ impl<'a> FileEmitter<'a> {
         Self {
             ctx,
             symbols,
+            consts,
             data,
             types,
             nsl: &file.namespaces,
@@ -200,14 +267,23 @@ impl<'a> FileEmitter<'a> {
         }
     }
 
-    /// Emit IR for this file. Mutates shared module state.
-    fn emit(mut self) -> error::Res<EmitResult> {
+    /// Emit IR for this file. Mutates shared module state. Functions which
+    /// fail to lower are left out of the result and reported instead.
+    fn emit(mut self) -> (EmitResult, Diagnostics) {
         let mut diag = Diagnostics::new();
         let mut decls = Vec::new();
+        let mut globals = Vec::new();
 
         for decl in &self.ast.decls {
             let res = match decl {
                 types::Decl::Func(node) => self.emit_func(node),
+                types::Decl::Const(node) => {
+                    match self.emit_const(node) {
+                        Ok(global) => globals.push(global),
+                        Err(report) => diag.add(report),
+                    }
+                    continue;
+                }
 
                 // extern symbols are declared at top using modules symbols list
                 types::Decl::Extern(_) => continue,
@@ -219,10 +295,13 @@ impl<'a> FileEmitter<'a> {
             }
         }
 
-        Ok(EmitResult {
+        let result = EmitResult {
             decls,
+            globals,
             externs: self.externs,
-        })
+        };
+
+        (result, diag)
     }
 
     /// Get next unique constant id to be used in this scope.
@@ -233,20 +312,29 @@ impl<'a> FileEmitter<'a> {
     }
 
     fn emit_func(&mut self, node: &types::FuncNode) -> Res<Decl> {
+        // Get function type
+        let func = self.ctx.types.try_function(node.ty).unwrap();
+        let params = func
+            .params
+            .iter()
+            .map(|ty| self.ir_type(*ty, &node.meta))
+            .collect::<Res<Vec<_>>>()?;
+        let ret = self.ir_type(func.ret, &node.meta)?;
+
+        let depth = (self.vars.depth(), self.params.depth());
         self.push_scope();
 
         // Bind parameters to local ids
         for (i, param) in node.params.iter().enumerate() {
-            self.params.bind(param.clone(), i);
+            self.params.bind(param.name.clone(), i);
         }
 
-        let body = self.emit_func_block(&node.body.stmts)?;
-        self.pop_scope();
-
-        // Get function type
-        let func = self.ctx.types.try_function(node.ty).unwrap();
-        let params = self.types.to_ir_type_list(self.ctx, &func.params);
-        let ret = self.types.to_ir(self.ctx, func.ret);
+        // Restore the scopes before checking the result. Lowering may fail
+        // inside nested blocks, leaving their scopes open, and they must not
+        // leak into the next function.
+        let body = self.emit_func_block(&node.body.stmts);
+        self.pop_scope_to(depth);
+        let body = body?;
 
         Ok(Decl::Func(FuncDecl {
             public: node.public,
@@ -258,6 +346,15 @@ impl<'a> FileEmitter<'a> {
         }))
     }
 
+    fn emit_const(&mut self, node: &types::ConstNode) -> Res<Global> {
+        Ok(Global {
+            public: node.public,
+            name: self.to_mangled_name(&node.name),
+            ty: self.ir_type(node.ty, &node.meta)?,
+            value: Some(self.lit_to_rval(&node.value)?),
+        })
+    }
+
     fn emit_func_block(&mut self, nodes: &Vec<types::Stmt>) -> Res<Block> {
         let mut ins = Vec::new();
 
@@ -301,7 +398,7 @@ impl<'a> FileEmitter<'a> {
     }
 
     fn emit_op_assign(&mut self, ins: &mut Vec<Ins>, node: &types::OpAssignNode) -> Res<()> {
-        let ty = self.types.to_ir(self.ctx, node.ty);
+        let ty = self.ir_type(node.ty, &node.meta)?;
         let lhs = self.expr_to_rval(ins, &node.lval)?;
         let rhs = self.expr_to_rval(ins, &node.rval)?;
         let result = self.next_id();
@@ -322,14 +419,17 @@ impl<'a> FileEmitter<'a> {
     }
 
     fn emit_block(&mut self, node: &types::BlockNode) -> Res<Block> {
+        self.vars.push_scope();
         let mut ins = Vec::new();
         for stmt in &node.stmts {
             self.emit_stmt(&mut ins, stmt)?;
         }
+        self.vars.pop_scope();
         Ok(Block { ins })
     }
 
     fn emit_for(&mut self, ins: &mut Vec<Ins>, node: &types::ForNode) -> Res<()> {
+        self.vars.push_scope();
         self.emit_stmt(ins, &node.initializer)?;
 
         let mut cond_ins = Vec::new();
@@ -339,6 +439,7 @@ impl<'a> FileEmitter<'a> {
 
         let mut post = Vec::new();
         self.emit_stmt(&mut post, &node.increment)?;
+        self.vars.pop_scope();
 
         ins.push(Ins::While(WhileIns {
             cond_ins,
@@ -363,6 +464,14 @@ impl<'a> FileEmitter<'a> {
     }
 
     fn emit_if(&mut self, ins: &mut Vec<Ins>, node: &types::IfNode) -> Res<()> {
+        // Each init statement opens a scope lasting to the end of the chain
+        let mut scopes = 0;
+        if let Some(init) = &node.init {
+            self.vars.push_scope();
+            scopes += 1;
+            self.emit_stmt(ins, init)?;
+        }
+
         let cond = self.expr_to_rval(ins, &node.expr)?;
         let block = self.emit_block(&node.block)?;
 
@@ -374,6 +483,12 @@ impl<'a> FileEmitter<'a> {
             match elseif {
                 types::ElseBlock::ElseIf(node) => {
                     let mut cond_ins = Vec::new();
+                    if let Some(init) = &node.init {
+                        self.vars.push_scope();
+                        scopes += 1;
+                        self.emit_stmt(&mut cond_ins, init)?;
+                    }
+
                     let cond = self.expr_to_rval(&mut cond_ins, &node.expr)?;
                     let block = self.emit_block(&node.block)?;
                     elseifs.push(ElseIf {
@@ -392,6 +507,10 @@ impl<'a> FileEmitter<'a> {
             };
         }
 
+        for _ in 0..scopes {
+            self.vars.pop_scope();
+        }
+
         ins.push(Ins::If(IfIns {
             cond,
             block,
@@ -402,7 +521,7 @@ impl<'a> FileEmitter<'a> {
     }
 
     fn emit_var_assign(&mut self, ins: &mut Vec<Ins>, node: &types::VarAssignNode) -> Res<()> {
-        let ty = self.types.to_ir(self.ctx, node.ty);
+        let ty = self.ir_type(node.ty, &node.meta)?;
         let rval = self.expr_to_rval(ins, &node.rval)?;
         let lval = self.expr_to_lval(ins, &node.lval)?;
         ins.push(Ins::Assign(AssignIns { ty, lval, rval }));
@@ -410,12 +529,18 @@ impl<'a> FileEmitter<'a> {
     }
 
     fn emit_var_decl(&mut self, ins: &mut Vec<Ins>, node: &types::VarDeclNode) -> Res<()> {
-        let ty = self.types.to_ir(self.ctx, node.ty);
-        let rval = self.expr_to_rval(ins, &node.value)?;
+        let ty = self.ir_type(node.ty, &node.meta)?;
+        let rval = match &node.value {
+            Some(value) => self.expr_to_rval(ins, value)?,
+            None => self.zero_to_rval(node.ty, &node.meta)?,
+        };
         let const_id = self.next_id();
         ins.push(Ins::Store(StoreIns { ty, const_id, rval }));
 
-        self.stacksize += self.types.sizeof(ty);
+        self.stacksize = align_to(
+            self.stacksize + self.types.sizeof(ty),
+            self.types.alignof(ty),
+        );
 
         // Bind locally to look up its ConstId later
         self.vars.bind(node.name.clone(), const_id);
@@ -426,7 +551,7 @@ impl<'a> FileEmitter<'a> {
     // ----------------------
 
     fn emit_return(&mut self, ins: &mut Vec<Ins>, node: &types::ReturnNode) -> Res<()> {
-        let ty = self.types.to_ir(self.ctx, node.ty);
+        let ty = self.ir_type(node.ty, &node.meta)?;
         let rval = match &node.expr {
             None => RValue::Void,
             Some(expr) => self.expr_to_rval(ins, expr)?,
@@ -441,7 +566,27 @@ impl<'a> FileEmitter<'a> {
             Expr::Literal(node) => self.lit_to_rval(node),
             Expr::Call(node) => self.call_to_rval(ins, node),
             Expr::NamespaceMember(node) => self.namespace_to_rval(node),
-            Expr::Member(_) => todo!(),
+            Expr::Member(node) => Err(error_span(
+                "member expressions are not supported by codegen yet",
+                &node.meta,
+            )),
+            Expr::Index(node) => Err(error_span(
+                "index expressions are not supported by codegen yet",
+                &node.meta,
+            )),
+            Expr::StructLit(node) => Err(error_span(
+                "struct literals are not supported by codegen yet",
+                &node.meta,
+            )),
+            Expr::Tuple(node) => Err(error_span(
+                "multiple return values are not supported by codegen yet",
+                &node.meta,
+            )),
+            Expr::Block(node) => self.block_to_rval(ins, node),
+            Expr::Ternary(node) => Err(error_span(
+                "ternary expressions are not supported by codegen yet",
+                &node.meta,
+            )),
             Expr::Binary(node) => self.binary_to_rval(ins, node),
             Expr::Unary(node) => self.unary_to_rval(ins, node),
             Expr::Cast(node) => self.cast_to_rval(ins, node),
@@ -455,8 +600,8 @@ impl<'a> FileEmitter<'a> {
             return Ok(rval);
         }
 
-        let from_ty = self.types.to_ir(self.ctx, node.expr.type_id());
-        let to_ty = self.types.to_ir(self.ctx, node.ty);
+        let from_ty = self.ir_type(node.expr.type_id(), &node.meta)?;
+        let to_ty = self.ir_type(node.ty, &node.meta)?;
         let result = self.next_id();
         ins.push(Ins::Cast(CastIns {
             from_ty,
@@ -468,7 +613,7 @@ impl<'a> FileEmitter<'a> {
     }
 
     fn unary_to_rval(&mut self, ins: &mut Vec<Ins>, node: &types::UnaryNode) -> Res<RValue> {
-        let ty = self.types.to_ir(self.ctx, node.ty);
+        let ty = self.ir_type(node.ty, &node.meta)?;
         let rhs = self.expr_to_rval(ins, &node.rhs)?;
         let result = self.next_id();
         ins.push(Ins::Unary(UnaryIns {
@@ -488,7 +633,7 @@ impl<'a> FileEmitter<'a> {
             return self.conditional_to_rval(ins, node);
         }
 
-        let ty = self.types.to_ir(self.ctx, node.ty);
+        let ty = self.ir_type(node.ty, &node.meta)?;
         let lhs = self.expr_to_rval(ins, &node.lhs)?;
         let rhs = self.expr_to_rval(ins, &node.rhs)?;
         let result = self.next_id();
@@ -528,18 +673,55 @@ impl<'a> FileEmitter<'a> {
         Ok(RValue::Const(result))
     }
 
+    /// Block expressions are inlined, the statements are emitted in their own
+    /// scope followed by the final expression giving the value.
+    fn block_to_rval(&mut self, ins: &mut Vec<Ins>, node: &types::BlockExprNode) -> Res<RValue> {
+        self.vars.push_scope();
+        for stmt in &node.block.stmts {
+            self.emit_stmt(ins, stmt)?;
+        }
+        let rval = self.expr_to_rval(ins, &node.expr)?;
+        self.vars.pop_scope();
+        Ok(rval)
+    }
+
     fn lit_to_rval(&mut self, node: &types::LiteralNode) -> Res<RValue> {
         Ok(match &node.kind {
             LiteralKind::Int(n) => RValue::Int(*n),
             LiteralKind::String(s) => RValue::Data(self.data.get_or_intern_string(s.to_owned())),
-            LiteralKind::Ident(name) => self.get_variable_rval(name),
+            LiteralKind::Ident(name) => {
+                // Constants in this module are replaced by their value unless
+                // shadowed by a local variable or parameter
+                let consts = self.consts;
+                match consts.get(name.as_str()) {
+                    Some(value) if !self.is_local(name) => self.lit_to_rval(value)?,
+                    _ => self.get_variable_rval(name),
+                }
+            }
             LiteralKind::Uint(n) => RValue::Uint(*n),
             LiteralKind::Float(n) => RValue::Float(*n),
             LiteralKind::Bool(n) => RValue::Uint(if *n { 1 } else { 0 }),
             LiteralKind::Char(n) => RValue::Uint(*n as u64),
+            LiteralKind::Null => RValue::Uint(0),
         })
     }
 
+    /// Zero value of a type, stored in variables declared without a value.
+    fn zero_to_rval(&mut self, ty: TypeId, meta: &NodeMeta) -> Res<RValue> {
+        match self.ctx.types.zero_value(ty) {
+            Some(ZeroValue::Int) => Ok(RValue::Int(0)),
+            Some(ZeroValue::Float) => Ok(RValue::Float(0.0)),
+            Some(ZeroValue::Bool) | Some(ZeroValue::Null) => Ok(RValue::Uint(0)),
+            Some(ZeroValue::String) => {
+                Ok(RValue::Data(self.data.get_or_intern_string(String::new())))
+            }
+            _ => Err(error_span(
+                "zero initialized aggregates are not supported by codegen yet",
+                meta,
+            )),
+        }
+    }
+
     fn namespace_to_rval(&mut self, node: &types::NamespaceMemberNode) -> Res<RValue> {
         // Both unwraps are guaranteed by type checker
         let symbol_id = self.nsl.get(&node.name).unwrap().get(&node.field).unwrap();
@@ -547,6 +729,9 @@ impl<'a> FileEmitter<'a> {
         let mangled_name = mangle_symbol_name(self.ctx, symbol);
         self.externs.insert(symbol_id);
 
+        if matches!(symbol.kind, SymbolKind::Constant) {
+            return Ok(RValue::Global(mangled_name));
+        }
         Ok(RValue::Function(mangled_name))
     }
 
@@ -555,7 +740,7 @@ impl<'a> FileEmitter<'a> {
             .args
             .iter()
             .map(|expr| {
-                let ty = self.types.to_ir(self.ctx, expr.type_id());
+                let ty = self.ir_type(expr.type_id(), expr)?;
                 let rval = self.expr_to_rval(ins, expr)?;
                 Ok((ty, rval))
             })
@@ -572,7 +757,7 @@ impl<'a> FileEmitter<'a> {
         let result_id = self.next_id();
 
         ins.push(Ins::Call(CallIns {
-            ty: self.types.to_ir(self.ctx, node.ty),
+            ty: self.ir_type(node.ty, &node.meta)?,
             result: LValue::Const(result_id),
             callee,
             args,
@@ -588,12 +773,26 @@ impl<'a> FileEmitter<'a> {
         if let Some(name) = expr.try_identifier() {
             return Ok(self.get_variable_lval(name));
         };
-        todo!()
+        Err(error_span(
+            "assignment target is not supported by codegen yet",
+            expr,
+        ))
     }
 
     // Helper methods
     // --------------
 
+    /// Convert a type to IR, reporting types codegen cannot represent yet.
+    fn ir_type(&mut self, ty: TypeId, span: &dyn Span) -> Res<IRTypeId> {
+        self.types.to_ir(self.ctx, ty).ok_or_else(|| {
+            let msg = format!(
+                "type '{}' is not supported by codegen yet",
+                self.ctx.types.type_to_string(ty)
+            );
+            error_span(&msg, span)
+        })
+    }
+
     fn push_scope(&mut self) {
         self.vars.push_scope();
         self.params.push_scope();
@@ -601,17 +800,32 @@ impl<'a> FileEmitter<'a> {
         self.stacksize = 0;
     }
 
-    fn pop_scope(&mut self) {
-        self.vars.pop_scope();
-        self.params.pop_scope();
+    /// Pop variable and parameter scopes until they are at the given depths.
+    fn pop_scope_to(&mut self, (vars, params): (usize, usize)) {
+        while self.vars.depth() > vars {
+            self.vars.pop_scope();
+        }
+        while self.params.depth() > params {
+            self.params.pop_scope();
+        }
+    }
+
+    /// Is the name a variable or parameter in the current function?
+    fn is_local(&self, name: &str) -> bool {
+        self.vars.get(name).is_some() || self.params.get(name).is_some()
     }
 
-    /// Get the RValue of a named value (variable or parameter).
+    /// Get the RValue of a named value (variable, parameter, or global constant).
     fn get_variable_rval(&self, name: &str) -> RValue {
         if let Some(id) = self.vars.get(name) {
             RValue::Const(*id)
+        } else if let Some(id) = self.params.get(name) {
+            RValue::Param(*id)
+        } else if self.symbols.get(name).is_ok() {
+            RValue::Global(self.to_mangled_name(name))
         } else {
-            RValue::Param(*self.params.get(name).expect("not an assigned name"))
+            // Guaranteed by type checker
+            unreachable!("unknown name '{name}'");
         }
     }
 
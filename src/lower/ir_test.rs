@@ -1,6 +1,7 @@
 use crate::{
-    common::{compare_string_lines_or_panic, emit_string, must},
-    ir::unit_to_string,
+    common::{compare_string_lines_or_panic, emit_string, must, must_check},
+    ir::{Data, Decl, unit_to_string},
+    lower::emit_ir_partial,
 };
 
 fn expect_equal(src: &str, expect: &str) {
@@ -8,6 +9,19 @@ fn expect_equal(src: &str, expect: &str) {
     compare_string_lines_or_panic(ir_str, expect.to_string());
 }
 
+#[test]
+fn test_unit_display_matches_unit_to_string() {
+    let unit = must(emit_string(
+        r#"
+        func f(a int) int {
+            return a + 1
+        }
+    "#,
+    ));
+
+    assert_eq!(unit.to_string(), unit_to_string(&unit));
+}
+
 #[test]
 fn test_function_explicit_empty_return() {
     expect_equal(
@@ -97,6 +111,45 @@ fn test_function_parameter_return() {
     );
 }
 
+#[test]
+fn test_function_parameters_by_index() {
+    expect_equal(
+        r#"
+        func add(a int, b int) int {
+            return a
+        }
+
+        func second(a int, b byte) byte {
+            return b
+        }
+    "#,
+        r#"
+        func add(i32, i32) i32
+            ret i32 %0
+
+        func second(i32, u8) u8
+            ret u8 %1
+        "#,
+    );
+}
+
+#[test]
+fn test_function_parameter_assign() {
+    expect_equal(
+        r#"
+        func f(a int) int {
+            a = 2
+            return a
+        }
+    "#,
+        r#"
+        func f(i32) i32
+            %0 i32 = 2
+            ret i32 %0
+        "#,
+    );
+}
+
 #[test]
 fn test_function_call() {
     expect_equal(
@@ -120,6 +173,35 @@ fn test_function_call() {
     );
 }
 
+#[test]
+fn test_register_numbering_restarts_per_function() {
+    expect_equal(
+        r#"
+        func f() int {
+            a := 1
+            b := 2
+            return a + b
+        }
+
+        func g() int {
+            c := 3
+            return c
+        }
+    "#,
+        r#"
+        func f() i32
+            $0 i32 = 1
+            $1 i32 = 2
+            $2 i32 = add $0 $1
+            ret i32 $2
+
+        func g() i32
+            $0 i32 = 3
+            ret i32 $0
+        "#,
+    );
+}
+
 #[test]
 fn test_function_call_with_params() {
     expect_equal(
@@ -215,6 +297,28 @@ fn test_variable_decl_simple() {
     );
 }
 
+#[test]
+fn test_var_decl_zero_value() {
+    expect_equal(
+        r#"
+        func f() {
+            var a int
+            var b f64
+            var c bool
+            var d = 2
+        }
+    "#,
+        r#"
+        func f() void
+            $0 i32 = 0
+            $1 f64 = 0
+            $2 u8 = 0
+            $3 i32 = 2
+            ret void
+        "#,
+    );
+}
+
 #[test]
 fn test_variable_decl_copy() {
     expect_equal(
@@ -266,6 +370,40 @@ fn test_binary_sub() {
     );
 }
 
+#[test]
+fn test_binary_nested_precedence() {
+    expect_equal(
+        r#"
+        func f() int {
+            return 1 + 2 * 3
+        }
+    "#,
+        r#"
+        func f() i32
+            $0 i32 = mul 2 3
+            $1 i32 = add 1 $0
+            ret i32 $1
+        "#,
+    );
+}
+
+#[test]
+fn test_binary_float() {
+    expect_equal(
+        r#"
+        func f(a float, b float) float {
+            return a / b - 1.5
+        }
+    "#,
+        r#"
+        func f(f32, f32) f32
+            $0 f32 = div %0 %1
+            $1 f32 = sub $0 1.5
+            ret f32 $1
+        "#,
+    );
+}
+
 #[test]
 fn test_binary_mul() {
     expect_equal(
@@ -594,6 +732,29 @@ fn test_variable_assign() {
     );
 }
 
+#[test]
+fn test_if_no_else() {
+    expect_equal(
+        r#"
+        func f(a int) int {
+            n := 0
+            if a > 3 {
+                n = 7
+            }
+            return n
+        }
+    "#,
+        r#"
+        func f(i32) i32
+            $0 i32 = 0
+            $1 u8 = gt %0 3
+            if $1
+                $0 i32 = 7
+            ret i32 $0
+        "#,
+    );
+}
+
 #[test]
 fn test_if_else() {
     expect_equal(
@@ -881,10 +1042,45 @@ fn test_while_break_continue_nested() {
     );
 }
 
+#[test]
+fn test_for_counting() {
+    expect_equal(
+        r#"
+        func f(n int) int {
+            sum := 0
+            for i := 0; i < n; i += 1 {
+                if i == 3 {
+                    continue
+                }
+                sum += i
+            }
+            return sum
+        }
+    "#,
+        r#"
+        func f(i32) i32
+            $0 i32 = 0
+            $1 i32 = 0
+            while (
+                $2 u8 = lt $1 %0
+            ): $2
+                $3 u8 = eq $1 3
+                if $3
+                    continue
+                $4 i32 = add $0 $1
+                $0 i32 = $4
+            post
+                $5 i32 = add $1 1
+                $1 i32 = $5
+            ret i32 $0
+        "#,
+    );
+}
+
 #[test]
 fn test_while_computed_condition() {
     // Condition requires computation — cond_ins are stored inside WhileIns
-    // and not shown in the IR text; only the resulting cond rvalue ($0) appears.
+    // and printed before the resulting cond rvalue ($0).
     expect_equal(
         r#"
         func f(a int, b int) {
@@ -895,7 +1091,9 @@ fn test_while_computed_condition() {
     "#,
         r#"
         func f(i32, i32) void
-            while $0
+            while (
+                $0 u8 = lt %0 %1
+            ): $0
                 $1 i32 = add %0 1
                 %0 i32 = $1
             ret void
@@ -1016,3 +1214,327 @@ fn test_cast_stored_in_var() {
         "#,
     );
 }
+
+#[test]
+fn test_if_init() {
+    expect_equal(
+        r#"
+        func f(a int) int {
+            if x := a + 1; x > 3 {
+                return x
+            }
+            x := 2
+            return x
+        }
+    "#,
+        r#"
+        func f(i32) i32
+            $0 i32 = add %0 1
+            $1 i32 = $0
+            $2 u8 = gt $1 3
+            if $2
+                ret i32 $1
+            $3 i32 = 2
+            ret i32 $3
+        "#,
+    );
+}
+
+#[test]
+fn test_shadowed_variables_in_sibling_blocks() {
+    expect_equal(
+        r#"
+        func f(a bool) int {
+            n := 0
+            if a {
+                v := 1
+                n = v
+            } else {
+                v := 2
+                n = v
+            }
+            return n
+        }
+    "#,
+        r#"
+        func f(u8) i32
+            $0 i32 = 0
+            if %0
+                $1 i32 = 1
+                $0 i32 = $1
+            else
+                $2 i32 = 2
+                $0 i32 = $2
+            ret i32 $0
+        "#,
+    );
+}
+
+#[test]
+fn test_prefixed_int_literals() {
+    expect_equal(
+        r#"
+        func f() int {
+            return 0xff + 0o17 + 0b101
+        }
+    "#,
+        r#"
+        func f() i32
+            $0 i32 = add 255 15
+            $1 i32 = add $0 5
+            ret i32 $1
+        "#,
+    );
+}
+
+#[test]
+fn test_unsupported_param_type_is_error() {
+    let Err(errs) = emit_string(
+        r#"
+        func f(a [3]int) {
+        }
+    "#,
+    ) else {
+        panic!("expected error");
+    };
+
+    assert_eq!(errs.len(), 1);
+    assert_eq!(
+        errs.get(0).message,
+        "type '[3]i32' is not supported by codegen yet"
+    );
+}
+
+#[test]
+fn test_partial_ir_skips_failed_functions() {
+    let (ctx, id) = must_check(
+        r#"
+        func f(a [3]int) {
+        }
+
+        func g(a [2]bool) int {
+            return 0
+        }
+
+        func h() int {
+            return 1
+        }
+    "#,
+    );
+
+    let (unit, diag) = emit_ir_partial(&ctx, id);
+    assert_eq!(diag.reports().len(), 2);
+
+    let names = unit
+        .decls
+        .iter()
+        .filter_map(|decl| match decl {
+            Decl::Func(func) => Some(func.name.as_str()),
+            Decl::Extern(_) => None,
+        })
+        .collect::<Vec<_>>();
+    assert_eq!(names, vec!["h"]);
+}
+
+#[test]
+fn test_failed_function_does_not_leak_scopes() {
+    let (ctx, id) = must_check(
+        r#"
+        func f() {
+            a := 1
+            if true {
+                b := true ? 1 : 2
+            }
+        }
+
+        func g(a int) int {
+            return a
+        }
+    "#,
+    );
+
+    let (unit, diag) = emit_ir_partial(&ctx, id);
+    assert_eq!(diag.reports().len(), 1);
+    compare_string_lines_or_panic(
+        unit_to_string(&unit),
+        r#"
+        func g(i32) i32
+            ret i32 %0
+        "#
+        .to_string(),
+    );
+}
+
+#[test]
+fn test_block_expression_inlined() {
+    expect_equal(
+        r#"
+        func f(n int) int {
+            x := {
+                a := n
+                a + 1
+            }
+            return x
+        }
+    "#,
+        r#"
+        func f(i32) i32
+            $0 i32 = %0
+            $1 i32 = add $0 1
+            $2 i32 = $1
+            ret i32 $2
+        "#,
+    );
+}
+
+#[test]
+fn test_global_constant() {
+    expect_equal(
+        r#"
+        max :: 10
+        size :: 4 * 8
+
+        func f() int {
+            return max + size
+        }
+    "#,
+        r#"
+        global @max i32 = 10
+        global @size i32 = 32
+
+        func f() i32
+            $0 i32 = add 10 32
+            ret i32 $0
+        "#,
+    );
+}
+
+#[test]
+fn test_global_constant_referencing_constant() {
+    expect_equal(
+        r#"
+        size :: count * 4
+        count :: 8
+
+        func f() int {
+            return size
+        }
+    "#,
+        r#"
+        global @size i32 = 32
+        global @count i32 = 8
+
+        func f() i32
+            ret i32 32
+        "#,
+    );
+}
+
+#[test]
+fn test_global_constant_with_type() {
+    expect_equal(
+        r#"
+        max i64 :: 10
+
+        func f() i64 {
+            return max
+        }
+    "#,
+        r#"
+        global @max i64 = 10
+
+        func f() i64
+            ret i64 10
+        "#,
+    );
+}
+
+#[test]
+fn test_global_constant_with_float_type() {
+    expect_equal(
+        r#"
+        ratio f64 :: 0.5
+
+        func f() f64 {
+            return ratio
+        }
+    "#,
+        r#"
+        global @ratio f64 = 0.5
+
+        func f() f64
+            ret f64 0.5
+        "#,
+    );
+}
+
+#[test]
+fn test_global_string_constant_shares_data() {
+    expect_equal(
+        r#"
+        name :: "koi"
+
+        func f() string {
+            return name
+        }
+
+        func g() string {
+            return "koi"
+        }
+    "#,
+        r#"
+        data .0 "koi"
+        global @name string = .0
+
+        func f() string
+            ret string .0
+
+        func g() string
+            ret string .0
+        "#,
+    );
+}
+
+#[test]
+fn test_string_data_keeps_null_byte() {
+    let src = r#"
+        func f() string {
+            return "a\x00b"
+        }
+    "#;
+
+    expect_equal(
+        src,
+        r#"
+        data .0 "a\0b"
+
+        func f() string
+            ret string .0
+        "#,
+    );
+
+    let unit = must(emit_string(src));
+    let Data::String(s) = &unit.data[0];
+    assert_eq!(s.len(), 3);
+}
+
+#[test]
+fn test_local_shadows_global_constant() {
+    expect_equal(
+        r#"
+        max :: 10
+
+        func f() int {
+            max := 1
+            return max
+        }
+    "#,
+        r#"
+        global @max i32 = 10
+
+        func f() i32
+            $0 i32 = 1
+            ret i32 $0
+        "#,
+    );
+}
@@ -1,6 +1,6 @@
 mod emit;
 
-pub use emit::emit_ir;
+pub use emit::{emit_ir, emit_ir_partial};
 
 #[cfg(test)]
 mod ir_test;
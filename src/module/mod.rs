@@ -12,7 +12,7 @@ mod symbol_test;
 #[cfg(test)]
 mod module_test;
 
-use std::collections::HashMap;
+use std::collections::BTreeMap;
 
 use crate::{common::FilePath, types::TypedAst};
 
@@ -70,8 +70,9 @@ impl Module {
         matches!(self.kind, ModuleKind::Source { .. })
     }
 
-    /// Collect all exported symbols from this module.
-    pub fn exports(&self) -> HashMap<&String, SymbolId> {
+    /// Collect all exported symbols from this module, sorted by name so that
+    /// anything generated from the exports has a stable order.
+    pub fn exports(&self) -> BTreeMap<&String, SymbolId> {
         self.symbols
             .symbols()
             .iter()
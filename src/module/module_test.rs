@@ -1,5 +1,5 @@
 use crate::{
-    common::{FilePath, must, parse_string},
+    common::{FilePath, must, must_check, parse_string},
     module::{ImportPath, ModulePath},
 };
 
@@ -150,3 +150,26 @@ fn test_non_std_app_import_unchanged() {
     let impath = ImportPath::from(&ast.imports[0]);
     assert_eq!(impath.path(), "myapp.utils");
 }
+
+#[test]
+fn test_exports_sorted_by_name() {
+    let (ctx, id) = must_check(
+        r#"
+        pub func zeta() {}
+        pub func alpha() {}
+        func hidden() {}
+        pub func mid() {}
+        pub func beta() {}
+    "#,
+    );
+
+    let names = ctx
+        .modules
+        .get(id)
+        .exports()
+        .into_keys()
+        .cloned()
+        .collect::<Vec<_>>();
+
+    assert_eq!(names, vec!["alpha", "beta", "mid", "zeta"]);
+}
@@ -62,6 +62,7 @@ impl fmt::Display for Symbol {
                 }
             }
             SymbolKind::Type => specs.push("type"),
+            SymbolKind::Constant => specs.push("constant"),
         }
         write!(
             f,
@@ -115,6 +116,8 @@ pub enum SymbolKind {
         is_naked: bool,
     },
     Type,
+    /// Top-level constant with a value known at compile time.
+    Constant,
 }
 
 impl fmt::Display for SymbolKind {
@@ -125,6 +128,7 @@ impl fmt::Display for SymbolKind {
             match self {
                 SymbolKind::Function { .. } => "function",
                 SymbolKind::Type => "type",
+                SymbolKind::Constant => "constant",
             }
         )
     }
@@ -0,0 +1,79 @@
+use std::collections::HashMap;
+
+use crate::{
+    ast::{Ast, Token},
+    common::Source,
+    config::Config,
+    error::Res,
+    scanner::scan_with_comments,
+};
+
+use super::parse::parse_tokens;
+
+/// A parsed file which keeps every token of the source, including comments,
+/// along with the whitespace between them. The exact source can be rebuilt
+/// from it, which lets tools rewrite code without losing formatting.
+pub struct LosslessAst {
+    pub ast: Ast,
+    /// All tokens in source order, including comments and newlines.
+    pub tokens: Vec<Token>,
+    /// Source text of each token, keyed by token id. Literal tokens hold
+    /// their parsed value, so the original text is kept here.
+    text: HashMap<usize, String>,
+    /// Whitespace directly before each token, keyed by token id.
+    leading: HashMap<usize, String>,
+    /// Whitespace after the last token.
+    trailing: String,
+}
+
+impl LosslessAst {
+    /// Get the source text of a token.
+    pub fn text(&self, token: &Token) -> &str {
+        self.text.get(&token.id).map_or("", String::as_str)
+    }
+
+    /// Get the whitespace directly before a token.
+    pub fn leading(&self, token: &Token) -> &str {
+        self.leading.get(&token.id).map_or("", String::as_str)
+    }
+
+    /// Rebuild the source the tree was parsed from, byte for byte.
+    pub fn to_source(&self) -> String {
+        let mut out = String::new();
+        for token in &self.tokens {
+            out.push_str(self.leading(token));
+            out.push_str(self.text(token));
+        }
+        out.push_str(&self.trailing);
+        out
+    }
+}
+
+/// Parse a source in full fidelity mode, keeping all tokens and whitespace
+/// next to the regular Ast.
+pub fn parse_lossless(src: &Source, config: &Config) -> Res<LosslessAst> {
+    let tokens = scan_with_comments(src, config)?;
+    let ast = parse_tokens(src, tokens.clone(), config)?;
+
+    let mut text = HashMap::new();
+    let mut leading = HashMap::new();
+    let mut offset = 0;
+
+    for token in &tokens {
+        let start = token.pos.offset;
+        let end = start + token.length;
+        leading.insert(token.id, src.str_range(offset, start).to_owned());
+        text.insert(token.id, src.str_range(start, end).to_owned());
+        offset = end;
+    }
+
+    let trailing = src.str_range(offset, src.size).to_owned();
+
+    Ok(LosslessAst {
+        ast,
+        tokens,
+        text,
+        leading,
+        trailing,
+    })
+}
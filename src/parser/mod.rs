@@ -1,4 +1,5 @@
 mod depgraph;
+mod lossless;
 mod parse;
 mod passes;
 
@@ -6,5 +7,6 @@ mod passes;
 mod tests;
 
 pub use depgraph::{SortResult, sort_by_dependency_graph};
+pub use lossless::{LosslessAst, parse_lossless};
 pub use parse::parse_source_map;
 pub use passes::validate_imports;
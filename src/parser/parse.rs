@@ -1,19 +1,20 @@
-use std::collections::HashSet;
+use std::collections::{HashMap, HashSet};
 
 use tracing::info;
 
 use crate::{
     ast::{
-        Ast, BinaryExpr, BlockNode, BreakNode, CallExpr, CastExpr, ContinueNode, Decl, ElseBlock,
-        Expr, Field, File, FileSet, ForNode, FuncDeclNode, FuncNode, GroupExpr, IfNode, ImportNode,
-        MemberNode, Modifier, OpAssignNode, ReturnNode, Stmt, Token, TokenKind, TypeDeclNode,
-        TypeNode, UnaryExpr, VarAssignNode, VarDeclNode, WhileNode,
+        Ast, BinaryExpr, BlockNode, BreakNode, CallExpr, CastExpr, ConstDeclNode, ContinueNode,
+        Decl, ElseBlock, Expr, Field, FieldValue, File, FileSet, ForNode, FuncDeclNode, FuncNode,
+        GroupExpr, IfNode, ImportNode, IndexExpr, MemberNode, Modifier, OpAssignNode, PackageNode,
+        ReturnNode, Stmt, StructDeclNode, StructLitExpr, TernaryExpr, Token, TokenKind, TupleExpr,
+        TypeDeclNode, TypeNode, UnaryExpr, VarAssignNode, VarDeclNode, VarNode, WhileNode,
     },
-    common::{SourceMap, Span},
+    common::{Source, SourceMap, Span},
     config::Config,
     error::{Diagnostics, Report, Res, error_from_to, error_span},
     module::ModulePath,
-    scanner::scan,
+    scanner::{eof_token, scan_with_comments},
 };
 
 /// Parse a SourceMap into a FileSet.
@@ -21,10 +22,8 @@ pub fn parse_source_map(modpath: ModulePath, map: &SourceMap, config: &Config) -
     let mut files = Vec::new();
 
     for src in map.sources() {
-        let tokens = scan(src, config)?;
-
-        let parser = Parser::new(tokens, config);
-        let ast = parser.parse_file()?;
+        let tokens = scan_with_comments(src, config)?;
+        let ast = parse_tokens(src, tokens, config)?;
 
         let file = File::new(src, ast);
         files.push(file);
@@ -33,9 +32,18 @@ pub fn parse_source_map(modpath: ModulePath, map: &SourceMap, config: &Config) -
     Ok(FileSet::new(modpath, files))
 }
 
+/// Parse the tokens of a single source into an Ast. Comment tokens are used
+/// for doc comments.
+pub(super) fn parse_tokens(src: &Source, tokens: Vec<Token>, config: &Config) -> Res<Ast> {
+    Parser::new(tokens, eof_token(src), config).parse_file()
+}
+
 struct Parser<'a> {
     _config: &'a Config,
     tokens: Vec<Token>,
+    /// Token positioned just past the end of the source. Used when reporting
+    /// errors after the last token.
+    eof: Token,
 
     diag: Diagnostics,
     pos: usize,
@@ -48,27 +56,60 @@ struct Parser<'a> {
     // Functions which parse statements should have a check at the top for
     // panicMode, and return early with an invalid statement if set.
     panic_mode: bool,
+
+    /// Block expressions are not allowed in if, while, and for headers, as
+    /// the brace there starts the body.
+    no_block_expr: bool,
+
+    /// Line comments which are alone on their line, keyed by row.
+    comments: HashMap<usize, String>,
 }
 
 impl<'a> Parser<'a> {
-    pub fn new(tokens: Vec<Token>, config: &'a Config) -> Self {
+    /// Create a parser for the given tokens. Comment tokens are removed and
+    /// only used for doc comments.
+    pub fn new(tokens: Vec<Token>, eof: Token, config: &'a Config) -> Self {
+        let comments = standalone_comments(&tokens);
+        let tokens = tokens
+            .into_iter()
+            .filter(|t| {
+                !matches!(
+                    t.kind,
+                    TokenKind::LineComment(_) | TokenKind::BlockComment(_)
+                )
+            })
+            .collect();
+
         Self {
             tokens,
+            eof,
             diag: Diagnostics::new(),
             pos: 0,
             panic_mode: false,
+            no_block_expr: false,
             _config: config,
+            comments,
         }
     }
 
     pub fn parse_file(mut self) -> Result<Ast, Diagnostics> {
         if self.tokens.is_empty() {
             return Ok(Ast {
+                package: None,
                 imports: vec![],
                 decls: vec![],
             });
         }
 
+        // The package declaration must be the first thing in the file
+        let package = match self.parse_package() {
+            Ok(package) => package,
+            Err(err) => {
+                self.diag.add(err);
+                return Err(self.diag);
+            }
+        };
+
         // Parse all imports as they must come before the main code
         let imports = match self.parse_imports() {
             Ok(imports) => imports,
@@ -81,8 +122,12 @@ impl<'a> Parser<'a> {
         let mut decls = Vec::new();
 
         while self.skip_whitespace_and_not_eof() {
+            let row = self.cur_or_eof().pos.row;
             match self.parse_modifier() {
-                Ok(decl) => decls.push(decl),
+                Ok(mut decl) => {
+                    self.attach_doc(&mut decl, row);
+                    decls.push(decl);
+                }
                 Err(err) => {
                     self.diag.add(err);
                     self.recover_from_error();
@@ -95,7 +140,41 @@ impl<'a> Parser<'a> {
             return Err(self.diag);
         }
 
-        Ok(Ast { imports, decls })
+        Ok(Ast {
+            package,
+            imports,
+            decls,
+        })
+    }
+
+    /// Set the doc comment of a declaration starting at the given row. The
+    /// doc is the comment lines directly above it, with no empty line between.
+    fn attach_doc(&self, decl: &mut Decl, row: usize) {
+        let mut lines = Vec::new();
+        let mut row = row;
+        while row > 0 {
+            let Some(text) = self.comments.get(&(row - 1)) else {
+                break;
+            };
+
+            let text = text.strip_prefix("//").unwrap_or(text);
+            lines.push(text.strip_prefix(' ').unwrap_or(text));
+            row -= 1;
+        }
+
+        if lines.is_empty() {
+            return;
+        }
+
+        lines.reverse();
+        let doc = Some(lines.join("\n"));
+
+        match decl {
+            Decl::Func(node) => node.doc = doc,
+            Decl::Struct(node) => node.doc = doc,
+            Decl::Const(node) => node.doc = doc,
+            _ => {}
+        }
     }
 
     /// Consume newlines until first non-newline token or eof.
@@ -112,14 +191,41 @@ impl<'a> Parser<'a> {
 
     // Consume until next 'safe' token to recover. Sets panic_mode to false.
     fn recover_from_error(&mut self) {
+        let decl_start = [
+            TokenKind::Func,
+            TokenKind::Extern,
+            TokenKind::Pub,
+            TokenKind::Type,
+            TokenKind::Unique,
+            TokenKind::Struct,
+            TokenKind::At,
+        ];
+
         self.consume(); // consume at least first token in case it is the one causing the panic
-        while !self.eof() && !self.matches_any(&[TokenKind::Func, TokenKind::Extern]) {
+        while !self.eof() && !self.matches_any(&decl_start) {
             self.consume();
         }
 
         self.panic_mode = false;
     }
 
+    fn parse_package(&mut self) -> Result<Option<PackageNode>, Report> {
+        self.skip_whitespace_and_not_eof();
+        if !self.matches(TokenKind::Package) {
+            return Ok(None);
+        }
+
+        let kw = self.must_consume()?;
+        let name = self.expect_identifier("package name")?;
+
+        self.skip_whitespace_and_not_eof();
+        if self.matches(TokenKind::Package) {
+            return Err(self.error_token("package is already declared"));
+        }
+
+        Ok(Some(PackageNode { kw, name }))
+    }
+
     fn parse_imports(&mut self) -> Result<Vec<ImportNode>, Report> {
         let mut imports = Vec::new();
         self.skip_whitespace_and_not_eof();
@@ -223,8 +329,14 @@ impl<'a> Parser<'a> {
                     let sym = self.must_consume()?; // @
                     let modifier = self.expect_identifier("modifier name")?;
 
+                    // Arguments run until the end of the line, or until the
+                    // declaration itself if it is on the same line.
+                    let decl_start = [TokenKind::Func, TokenKind::Extern, TokenKind::Pub];
                     let mut args = Vec::new();
-                    while !self.eof() && !self.matches(TokenKind::Newline) {
+                    while !self.eof()
+                        && !self.matches(TokenKind::Newline)
+                        && !self.matches_any(&decl_start)
+                    {
                         args.push(self.must_consume()?);
                     }
 
@@ -233,7 +345,10 @@ impl<'a> Parser<'a> {
                         modifier,
                         args,
                     });
-                    self.expect(TokenKind::Newline)?;
+
+                    if !self.matches_any(&decl_start) {
+                        self.expect(TokenKind::Newline)?;
+                    }
                 }
                 TokenKind::Newline => {
                     return Err(self.error_token("expected declaration after modifier"));
@@ -254,6 +369,12 @@ impl<'a> Parser<'a> {
             TokenKind::Extern => self.parse_extern(false, modifiers),
             TokenKind::Type => self.parse_type_decl(false, false),
             TokenKind::Unique => self.parse_unique_type_decl(false),
+            TokenKind::Struct => self.parse_struct_decl(false),
+            TokenKind::IdentLit(_) => self.parse_const_decl(false),
+            TokenKind::Package => {
+                Err(self
+                    .error_token("package declaration must come before imports and declarations"))
+            }
             _ => Err(self.error_token("expected declaration")),
         }
     }
@@ -266,6 +387,17 @@ impl<'a> Parser<'a> {
     fn parse_type_decl(&mut self, public: bool, unique: bool) -> Result<Decl, Report> {
         let kw = self.expect(TokenKind::Type)?;
         let name = self.expect_identifier("type name")?;
+
+        // Aliases may also be written as type X = int
+        let eq = if self.matches(TokenKind::Eq) {
+            if unique {
+                return Err(self.error_token("unique type cannot be declared as an alias with '='"));
+            }
+            Some(self.must_consume()?)
+        } else {
+            None
+        };
+
         let ty = self.parse_type()?;
 
         Ok(Decl::Type(Box::new(TypeDeclNode {
@@ -273,10 +405,80 @@ impl<'a> Parser<'a> {
             unique,
             kw,
             name,
+            eq,
             ty,
         })))
     }
 
+    fn parse_struct_decl(&mut self, public: bool) -> Result<Decl, Report> {
+        let kw = self.expect(TokenKind::Struct)?;
+        let name = self.expect_identifier("struct name")?;
+        let lbrace = self.expect(TokenKind::LBrace)?;
+
+        // Fields are separated by commas, newlines, or both
+        let mut fields = Vec::new();
+        while !self.eof_or_panic() {
+            while self.matches(TokenKind::Newline) {
+                self.consume();
+            }
+
+            if self.matches(TokenKind::RBrace) {
+                break;
+            }
+
+            fields.push(self.parse_field("field name")?);
+            if !self.matches_any(&[TokenKind::RBrace, TokenKind::Newline]) {
+                self.expect(TokenKind::Comma)?;
+            }
+        }
+
+        let rbrace = self.expect(TokenKind::RBrace)?;
+
+        if let Some(name) = duplicate_field(&fields) {
+            return Err(self.error_from_to("duplicate field name", name, name));
+        }
+
+        Ok(Decl::Struct(Box::new(StructDeclNode {
+            public,
+            kw,
+            name,
+            lbrace,
+            fields,
+            rbrace,
+            doc: None,
+        })))
+    }
+
+    fn parse_const_decl(&mut self, public: bool) -> Result<Decl, Report> {
+        let name = self.expect_identifier("constant name")?;
+
+        // Only constants are allowed at the top level
+        if self.matches(TokenKind::ColonEq) {
+            return Err(
+                self.error_token("global variables are not allowed, use '::' for constants")
+            );
+        }
+
+        // Optional type before the operator, eg. max u8 :: 10
+        let ty = if self.matches(TokenKind::ColonColon) {
+            None
+        } else {
+            Some(self.parse_type()?)
+        };
+
+        let symbol = self.expect(TokenKind::ColonColon)?;
+        let expr = self.parse_value_expr()?;
+
+        Ok(Decl::Const(Box::new(ConstDeclNode {
+            public,
+            name,
+            ty,
+            symbol,
+            expr,
+            doc: None,
+        })))
+    }
+
     fn parse_public_decl(&mut self, modifiers: Vec<Modifier>) -> Result<Decl, Report> {
         self.consume(); // pub
         let token = self.cur_must("unexpected end of input")?;
@@ -286,6 +488,8 @@ impl<'a> Parser<'a> {
             TokenKind::Extern => self.parse_extern(true, modifiers),
             TokenKind::Type => self.parse_type_decl(true, false),
             TokenKind::Unique => self.parse_unique_type_decl(true),
+            TokenKind::Struct => self.parse_struct_decl(true),
+            TokenKind::IdentLit(_) => self.parse_const_decl(true),
             _ => Err(self.error_token("illegal public declaration")),
         }
     }
@@ -307,22 +511,17 @@ impl<'a> Parser<'a> {
         let lparen = self.expect(TokenKind::LParen)?;
 
         // If the next token is not a right paren we parse parameters.
+        // A lone void, eg. f(void), is the same as an empty parameter list.
         let mut params = Vec::new();
-        let mut param_names = HashSet::new();
-        if !self.matches(TokenKind::RParen) {
+        if self.at_void_param() && self.tokens[self.pos + 1].kind == TokenKind::RParen {
+            self.consume(); // void
+        } else if !self.matches(TokenKind::RParen) {
             while !self.eof_or_panic() {
-                let field = self.parse_field("parameter name")?;
-
-                // If name already exists
-                if !param_names.insert(field.name.to_string()) {
-                    return Err(self.error_from_to(
-                        "duplicate parameter name",
-                        &field.name,
-                        &field.name,
-                    ));
+                if self.at_void_param() {
+                    return Err(self.error_token("void must be the only parameter"));
                 }
 
-                params.push(field);
+                params.push(self.parse_field("parameter name")?);
 
                 // Done?
                 if self.matches(TokenKind::RParen) {
@@ -335,16 +534,21 @@ impl<'a> Parser<'a> {
 
             // Bug if empty
             assert!(!params.is_empty(), "function parameters cannot be empty");
+
+            if let Some(name) = duplicate_field(&params) {
+                return Err(self.error_from_to("duplicate parameter name", name, name));
+            }
         };
 
         // Closing parenthesis
         let rparen = self.expect(TokenKind::RParen)?;
 
         // Check for return type
-        let ret_type = if self.matches_any(&[TokenKind::LBrace, TokenKind::Newline]) || self.eof() {
+        let body_start = [TokenKind::LBrace, TokenKind::Newline, TokenKind::FatArrow];
+        let ret_type = if self.matches_any(&body_start) || self.eof() {
             None
         } else {
-            Some(self.parse_type()?)
+            Some(self.parse_return_type()?)
         };
 
         Ok(FuncDeclNode {
@@ -367,7 +571,11 @@ impl<'a> Parser<'a> {
             public = true;
         }
 
-        let body = self.parse_block()?;
+        let body = if self.matches(TokenKind::FatArrow) {
+            self.parse_arrow_body()?
+        } else {
+            self.parse_block()?
+        };
 
         Ok(Decl::Func(Box::new(FuncNode {
             modifiers,
@@ -378,9 +586,31 @@ impl<'a> Parser<'a> {
             rparen: decl.rparen,
             ret_type: decl.ret_type,
             body,
+            doc: None,
         })))
     }
 
+    /// Parse a single expression function body, `=> expr`. It is expanded
+    /// to a block returning the expression, so later passes never see it.
+    fn parse_arrow_body(&mut self) -> Result<BlockNode, Report> {
+        let arrow = self.expect(TokenKind::FatArrow)?;
+        let expr = self.parse_value_expr()?;
+        let last = self.tokens[self.pos - 1].clone();
+
+        // The return keyword gets its own token so the block and return
+        // statement do not share a node id
+        let kw = Token::synthetic(TokenKind::Return).with_pos(arrow.pos.clone());
+
+        Ok(BlockNode {
+            lbrace: arrow,
+            stmts: vec![Stmt::Return(ReturnNode {
+                kw,
+                expr: Some(expr),
+            })],
+            rbrace: last,
+        })
+    }
+
     fn parse_field(&mut self, field_name: &str) -> Result<Field, Report> {
         let name = self.expect_identifier(field_name)?;
         let typ = self.parse_type()?;
@@ -423,7 +653,7 @@ impl<'a> Parser<'a> {
             TokenKind::Return => Ok(Stmt::Return(self.parse_return()?)),
             TokenKind::If => Ok(Stmt::If(self.parse_if()?)),
             TokenKind::While => Ok(Stmt::While(self.parse_while()?)),
-            TokenKind::For => Ok(Stmt::For(self.parse_for()?)),
+            TokenKind::For => self.parse_for(),
             TokenKind::Break => {
                 let kw = self.expect(TokenKind::Break)?;
                 Ok(Stmt::Break(BreakNode { kw }))
@@ -432,10 +662,13 @@ impl<'a> Parser<'a> {
                 let kw = self.expect(TokenKind::Continue)?;
                 Ok(Stmt::Continue(ContinueNode { kw }))
             }
+            TokenKind::Var => self.parse_var(),
+            // Typed variable declaration, eg. x int = 0
+            TokenKind::IdentLit(_) if self.next_is_type() => self.parse_typed_var_decl(),
             _ => {
                 let expr = self.parse_expr()?;
 
-                match self.cur_or_last().kind {
+                match self.cur_or_eof().kind {
                     // Check for variable declaration or assignment
                     TokenKind::ColonColon => self.parse_var_decl(expr, true),
                     TokenKind::ColonEq => self.parse_var_decl(expr, false),
@@ -456,7 +689,7 @@ impl<'a> Parser<'a> {
 
     fn parse_op_assign(&mut self, lval: Expr) -> Result<Stmt, Report> {
         let op = self.must_consume()?;
-        let rval = self.parse_expr()?;
+        let rval = self.parse_value_expr()?;
 
         if let Expr::Literal(name) = &lval
             && matches!(&name.kind, TokenKind::IdentLit(_))
@@ -467,34 +700,60 @@ impl<'a> Parser<'a> {
         Err(error_span("invalid left hand value in assignment", &lval))
     }
 
-    fn parse_for(&mut self) -> Result<ForNode, Report> {
+    /// Parse a for loop. Besides the three clause form, a condition only
+    /// loop, eg. for x < 10 { }, and an infinite loop, for { }, are accepted.
+    /// They are expanded to while loops, so later passes never see them.
+    fn parse_for(&mut self) -> Result<Stmt, Report> {
         let kw = self.expect(TokenKind::For)?;
-        let initializer = Box::new(self.parse_stmt()?);
-        self.expect(TokenKind::Semi)?;
-        let condition = Box::new(self.parse_expr()?);
-        self.expect(TokenKind::Semi)?;
-        let increment = Box::new(self.parse_stmt()?);
+
+        if self.matches(TokenKind::LBrace) {
+            let cond = Token::synthetic(TokenKind::True).with_pos(kw.end_pos.clone());
+            let block = self.parse_block()?;
+            return Ok(Stmt::While(WhileNode {
+                kw,
+                expr: Expr::Literal(cond),
+                block,
+            }));
+        }
+
+        let first = self.without_block_expr(Self::parse_stmt)?;
+
+        if !self.matches(TokenKind::Semi)
+            && let Stmt::ExprStmt(expr) = first
+        {
+            let block = self.parse_block()?;
+            return Ok(Stmt::While(WhileNode { kw, expr, block }));
+        }
+
+        let initializer = Box::new(first);
+        let (condition, increment) = self.without_block_expr(|p| {
+            p.expect(TokenKind::Semi)?;
+            let condition = Box::new(p.parse_value_expr()?);
+            p.expect(TokenKind::Semi)?;
+            let increment = Box::new(p.parse_stmt()?);
+            Ok((condition, increment))
+        })?;
         let block = self.parse_block()?;
 
-        Ok(ForNode {
+        Ok(Stmt::For(ForNode {
             kw,
             initializer,
             condition,
             increment,
             block,
-        })
+        }))
     }
 
     fn parse_while(&mut self) -> Result<WhileNode, Report> {
         let kw = self.expect(TokenKind::While)?;
-        let expr = self.parse_expr()?;
+        let expr = self.without_block_expr(Self::parse_value_expr)?;
         let block = self.parse_block()?;
         Ok(WhileNode { kw, expr, block })
     }
 
     fn parse_if(&mut self) -> Result<IfNode, Report> {
         let kw = self.expect(TokenKind::If)?;
-        let expr = self.parse_expr()?;
+        let (init, expr) = self.without_block_expr(Self::parse_if_condition)?;
         let block = self.parse_block()?;
 
         let elseif = if self.matches(TokenKind::Else) {
@@ -510,15 +769,44 @@ impl<'a> Parser<'a> {
 
         Ok(IfNode {
             kw,
+            init,
             expr,
             block,
             elseif,
         })
     }
 
+    /// Run the parse function with block expressions disallowed.
+    fn without_block_expr<T>(
+        &mut self,
+        parse: impl FnOnce(&mut Self) -> Result<T, Report>,
+    ) -> Result<T, Report> {
+        let prev = std::mem::replace(&mut self.no_block_expr, true);
+        let result = parse(self);
+        self.no_block_expr = prev;
+        result
+    }
+
+    /// Parse the condition of an if statement with an optional init statement
+    /// before it, eg. x := f(); x > 0
+    fn parse_if_condition(&mut self) -> Result<(Option<Box<Stmt>>, Expr), Report> {
+        let stmt = self.parse_stmt()?;
+
+        if self.matches(TokenKind::Semi) {
+            self.consume();
+            let expr = self.parse_value_expr()?;
+            return Ok((Some(Box::new(stmt)), expr));
+        }
+
+        match stmt {
+            Stmt::ExprStmt(expr) => Ok((None, expr)),
+            _ => Err(error_span("assignment is not an expression", &stmt)),
+        }
+    }
+
     fn parse_var_assign(&mut self, lval: Expr) -> Result<Stmt, Report> {
         let equal = self.expect(TokenKind::Eq)?;
-        let expr = self.parse_expr()?;
+        let expr = self.parse_value_expr()?;
 
         if let Expr::Literal(name) = &lval
             && matches!(&name.kind, TokenKind::IdentLit(_))
@@ -529,9 +817,53 @@ impl<'a> Parser<'a> {
         Err(error_span("invalid left hand value in assignment", &lval))
     }
 
+    fn parse_typed_var_decl(&mut self) -> Result<Stmt, Report> {
+        let name = self.expect_identifier("variable name")?;
+        let ty = self.parse_type()?;
+        let symbol = self.expect(TokenKind::Eq)?;
+        let expr = self.parse_value_expr()?;
+
+        Ok(Stmt::VarDecl(VarDeclNode {
+            name,
+            ty: Some(ty),
+            symbol,
+            expr,
+            constant: false,
+        }))
+    }
+
+    fn parse_var(&mut self) -> Result<Stmt, Report> {
+        let kw = self.expect(TokenKind::Var)?;
+        let name = self.expect_identifier("variable name")?;
+
+        let end = [TokenKind::Eq, TokenKind::Newline, TokenKind::RBrace];
+        let ty = if self.eof() || self.matches_any(&end) {
+            None
+        } else {
+            Some(self.parse_type()?)
+        };
+
+        let expr = if self.matches(TokenKind::Eq) {
+            self.consume();
+            Some(self.parse_value_expr()?)
+        } else {
+            None
+        };
+
+        if ty.is_none() && expr.is_none() {
+            return Err(self.error_from_to(
+                "variable declared with var must have a type or a value",
+                &kw,
+                &name,
+            ));
+        }
+
+        Ok(Stmt::Var(VarNode { kw, name, ty, expr }))
+    }
+
     fn parse_var_decl(&mut self, lval: Expr, constant: bool) -> Result<Stmt, Report> {
         let symbol = self.must_consume()?;
-        let expr = self.parse_expr()?;
+        let expr = self.parse_value_expr()?;
 
         // To not use lval after move
         let err = error_span("invalid left hand value in declaration", &lval);
@@ -540,6 +872,7 @@ impl<'a> Parser<'a> {
         {
             return Ok(Stmt::VarDecl(VarDeclNode {
                 name,
+                ty: None,
                 symbol,
                 expr,
                 constant,
@@ -556,14 +889,54 @@ impl<'a> Parser<'a> {
         let expr = if self.matches(TokenKind::Newline) {
             None
         } else {
-            Some(self.parse_expr()?)
+            Some(self.parse_return_values()?)
         };
 
         Ok(ReturnNode { kw, expr })
     }
 
+    /// Parse one or more comma separated return values. Several values are
+    /// grouped in a tuple expression.
+    fn parse_return_values(&mut self) -> Result<Expr, Report> {
+        let first = self.parse_value_expr()?;
+        if !self.matches(TokenKind::Comma) {
+            return Ok(first);
+        }
+
+        let comma = self.must_consume()?;
+        let mut exprs = vec![first, self.parse_value_expr()?];
+        while self.matches(TokenKind::Comma) {
+            self.consume();
+            exprs.push(self.parse_value_expr()?);
+        }
+
+        Ok(Expr::Tuple(TupleExpr { exprs, comma }))
+    }
+
     fn parse_expr(&mut self) -> Result<Expr, Report> {
-        self.parse_logical()
+        self.parse_ternary()
+    }
+
+    /// Parse an expression where only a value is allowed, like conditions and
+    /// arguments. Assignment is a statement, so an assignment operator
+    /// following the expression is reported as an error.
+    fn parse_value_expr(&mut self) -> Result<Expr, Report> {
+        let expr = self.parse_expr()?;
+
+        if self.matches_any(&[
+            TokenKind::Eq,
+            TokenKind::ColonEq,
+            TokenKind::ColonColon,
+            TokenKind::PlusEq,
+            TokenKind::MinusEq,
+            TokenKind::SlashEq,
+            TokenKind::StarEq,
+            TokenKind::PercentEq,
+        ]) {
+            return Err(self.error_token("assignment is not an expression"));
+        }
+
+        Ok(expr)
     }
 
     fn parse_binary(
@@ -574,6 +947,12 @@ impl<'a> Parser<'a> {
         let mut lhs = next(self)?;
         while self.matches_any(tokens) {
             let op = self.must_consume()?;
+
+            // The right operand may continue on the next line
+            while self.matches(TokenKind::Newline) {
+                self.consume();
+            }
+
             let rhs = next(self)?;
             lhs = Expr::Binary(BinaryExpr {
                 op,
@@ -584,6 +963,28 @@ impl<'a> Parser<'a> {
         Ok(lhs)
     }
 
+    /// Parse a conditional expression. It has the lowest precedence and is
+    /// right associative, so a ? b : c ? d : e groups as a ? b : (c ? d : e).
+    fn parse_ternary(&mut self) -> Result<Expr, Report> {
+        let cond = self.parse_logical()?;
+        if !self.matches(TokenKind::Question) {
+            return Ok(cond);
+        }
+
+        let question = self.must_consume()?;
+        let then = self.parse_ternary()?;
+        let colon = self.expect(TokenKind::Colon)?;
+        let otherwise = self.parse_ternary()?;
+
+        Ok(Expr::Ternary(TernaryExpr {
+            cond: Box::new(cond),
+            question,
+            then: Box::new(then),
+            colon,
+            otherwise: Box::new(otherwise),
+        }))
+    }
+
     fn parse_logical(&mut self) -> Result<Expr, Report> {
         self.parse_binary(&[TokenKind::AndAnd, TokenKind::OrOr], Self::parse_equality)
     }
@@ -634,7 +1035,7 @@ impl<'a> Parser<'a> {
     }
 
     fn parse_unary(&mut self) -> Result<Expr, Report> {
-        if self.matches_any(&[TokenKind::Minus, TokenKind::Not]) {
+        if self.matches_any(&[TokenKind::Minus, TokenKind::Plus, TokenKind::Not]) {
             let op = self.must_consume()?;
             let rhs = self.parse_unary()?;
             return Ok(Expr::Unary(UnaryExpr {
@@ -655,13 +1056,25 @@ impl<'a> Parser<'a> {
                 let lparen = self.must_consume()?;
                 let mut args = Vec::new();
 
-                while !self.matches(TokenKind::RParen) {
-                    args.push(self.parse_expr()?);
+                loop {
+                    // Newlines are not emitted inside parens, so an unclosed
+                    // list runs until the end of the enclosing block or file
+                    if self.at_unclosed_args() {
+                        return Err(self.error_from_to(
+                            "unterminated argument list",
+                            &lparen,
+                            &lparen,
+                        ));
+                    }
+
                     if self.matches(TokenKind::RParen) {
                         break;
                     }
 
-                    self.expect(TokenKind::Comma)?;
+                    args.push(self.parse_value_expr()?);
+                    if !self.matches(TokenKind::RParen) && !self.at_unclosed_args() {
+                        self.expect(TokenKind::Comma)?;
+                    }
                 }
 
                 let rparen = self.expect(TokenKind::RParen)?;
@@ -683,6 +1096,19 @@ impl<'a> Parser<'a> {
                     field,
                 });
 
+            // Index expression
+            } else if self.matches(TokenKind::LBrack) {
+                let lbrack = self.must_consume()?;
+                let index = self.parse_value_expr()?;
+                let rbrack = self.expect(TokenKind::RBrack)?;
+
+                expr = Expr::Index(IndexExpr {
+                    expr: Box::new(expr),
+                    lbrack,
+                    index: Box::new(index),
+                    rbrack,
+                });
+
             // Done
             } else {
                 break;
@@ -695,7 +1121,7 @@ impl<'a> Parser<'a> {
     fn parse_group(&mut self) -> Result<Expr, Report> {
         if self.matches(TokenKind::LParen) {
             let lparen = self.must_consume()?;
-            let inner = self.parse_expr()?;
+            let inner = self.parse_value_expr()?;
             let rparen = self.expect(TokenKind::RParen)?;
 
             return Ok(Expr::Group(GroupExpr {
@@ -705,12 +1131,28 @@ impl<'a> Parser<'a> {
             }));
         }
 
+        // Block expression
+        if self.matches(TokenKind::LBrace) && !self.no_block_expr {
+            return Ok(Expr::Block(self.parse_block()?));
+        }
+
         self.parse_literal()
     }
 
     fn parse_literal(&mut self) -> Result<Expr, Report> {
         let token = self.cur_must("expected expression")?.clone();
 
+        // Struct literal, not allowed where a brace starts a body
+        if matches!(token.kind, TokenKind::IdentLit(_))
+            && !self.no_block_expr
+            && self
+                .tokens
+                .get(self.pos + 1)
+                .is_some_and(|tok| tok.kind == TokenKind::LBrace)
+        {
+            return self.parse_struct_lit();
+        }
+
         match token.kind {
             TokenKind::IntLit(_)
             | TokenKind::IdentLit(_)
@@ -718,6 +1160,7 @@ impl<'a> Parser<'a> {
             | TokenKind::StringLit(_)
             | TokenKind::True
             | TokenKind::False
+            | TokenKind::Null
             | TokenKind::CharLit(_) => {
                 self.consume();
                 Ok(Expr::Literal(token))
@@ -726,6 +1169,77 @@ impl<'a> Parser<'a> {
         }
     }
 
+    /// Parse a struct literal with named fields, eg. Point{x: 1, y: 2}.
+    /// Fields are separated by commas and may span several lines.
+    fn parse_struct_lit(&mut self) -> Result<Expr, Report> {
+        let name = self.expect_identifier("struct name")?;
+        let lbrace = self.expect(TokenKind::LBrace)?;
+
+        let mut fields = Vec::new();
+        while !self.eof_or_panic() {
+            while self.matches(TokenKind::Newline) {
+                self.consume();
+            }
+
+            if self.matches(TokenKind::RBrace) {
+                break;
+            }
+
+            let field = self.expect_identifier("field name")?;
+            self.expect(TokenKind::Colon)?;
+            fields.push(FieldValue {
+                name: field,
+                value: self.parse_value_expr()?,
+            });
+
+            while self.matches(TokenKind::Newline) {
+                self.consume();
+            }
+
+            if !self.matches(TokenKind::RBrace) {
+                self.expect(TokenKind::Comma)?;
+            }
+        }
+
+        let rbrace = self.expect(TokenKind::RBrace)?;
+
+        Ok(Expr::StructLit(StructLitExpr {
+            name,
+            lbrace,
+            fields,
+            rbrace,
+        }))
+    }
+
+    /// Parse a function return type. Unlike other types, it may be a tuple of
+    /// several types in parens, eg. (int, string).
+    fn parse_return_type(&mut self) -> Result<TypeNode, Report> {
+        if !self.matches(TokenKind::LParen) {
+            return self.parse_type();
+        }
+
+        let lparen = self.must_consume()?;
+
+        let mut types = vec![self.parse_type()?];
+        while self.matches(TokenKind::Comma) {
+            self.consume();
+            types.push(self.parse_type()?);
+        }
+
+        let rparen = self.expect(TokenKind::RParen)?;
+
+        // A single type in parens is just that type
+        if types.len() == 1 {
+            return Ok(types.pop().unwrap());
+        }
+
+        Ok(TypeNode::Tuple {
+            lparen,
+            types,
+            rparen,
+        })
+    }
+
     fn parse_type(&mut self) -> Result<TypeNode, Report> {
         let token = self.cur_must("exptected type")?.clone();
 
@@ -743,6 +1257,33 @@ impl<'a> Parser<'a> {
                     Ok(TypeNode::Ident(name))
                 }
             }
+            TokenKind::LBrack => {
+                let lbrack = self.must_consume()?;
+
+                // No size given, eg. []int
+                if self.matches(TokenKind::RBrack) {
+                    let rbrack = self.must_consume()?;
+                    let elem = self.parse_type()?;
+                    return Ok(TypeNode::Slice {
+                        lbrack,
+                        rbrack,
+                        elem: Box::new(elem),
+                    });
+                }
+
+                let size = self.parse_value_expr()?;
+                let rbrack = self.expect(TokenKind::RBrack)?;
+                let elem = self.parse_type()?;
+                Ok(TypeNode::Array {
+                    lbrack,
+                    size: Box::new(size),
+                    rbrack,
+                    elem: Box::new(elem),
+                })
+            }
+            TokenKind::LParen => {
+                Err(self.error_token("tuple types are only allowed as return types"))
+            }
             TokenKind::RParen | TokenKind::RBrace | TokenKind::RBrack => {
                 Err(self.error_token("expected type"))
             }
@@ -752,7 +1293,7 @@ impl<'a> Parser<'a> {
 
     /// Create error marking the current token.
     fn error_token(&self, message: &str) -> Report {
-        self.error_from_to(message, &self.cur_or_last(), &self.cur_or_last())
+        self.error_from_to(message, &self.cur_or_eof(), &self.cur_or_eof())
     }
 
     /// Create error marking the given token range.
@@ -769,11 +1310,11 @@ impl<'a> Parser<'a> {
         self.tokens.get(self.pos).ok_or(self.error_token(msg))
     }
 
-    fn cur_or_last(&self) -> Token {
+    fn cur_or_eof(&self) -> Token {
         if self.pos < self.tokens.len() {
             self.tokens.get(self.pos).unwrap().clone()
         } else {
-            self.tokens.last().unwrap().clone()
+            self.eof.clone()
         }
     }
 
@@ -849,7 +1390,74 @@ impl<'a> Parser<'a> {
         self.pos >= self.tokens.len()
     }
 
+    /// Reports whether the token after the current one starts a type. An
+    /// identifier can never directly follow another in an expression, so this
+    /// is used to tell typed declarations apart from expression statements.
+    /// Brackets are skipped since both array types and index expressions use
+    /// them, only a type has an identifier after the last closing bracket.
+    fn next_is_type(&self) -> bool {
+        let mut i = self.pos + 1;
+        let mut depth = 0;
+
+        while let Some(tok) = self.tokens.get(i) {
+            match tok.kind {
+                TokenKind::LBrack => depth += 1,
+                TokenKind::RBrack if depth > 0 => depth -= 1,
+                TokenKind::IdentLit(_) if depth == 0 => return true,
+                _ if depth == 0 => return false,
+                _ => {}
+            }
+            i += 1;
+        }
+
+        false
+    }
+
+    /// Reports whether the current token is a lone void parameter, a void
+    /// type with no name followed by a comma or closing paren.
+    fn at_void_param(&self) -> bool {
+        self.cur()
+            .is_some_and(|tok| matches!(&tok.kind, TokenKind::IdentLit(name) if name == "void"))
+            && self
+                .tokens
+                .get(self.pos + 1)
+                .is_some_and(|tok| matches!(tok.kind, TokenKind::Comma | TokenKind::RParen))
+    }
+
+    /// Reports whether the current token is eof or a closing brace, neither
+    /// of which can appear inside an argument list.
+    fn at_unclosed_args(&self) -> bool {
+        self.eof() || self.matches(TokenKind::RBrace)
+    }
+
     fn eof_or_panic(&self) -> bool {
         self.eof() || self.panic_mode
     }
 }
+
+/// Get the name of the first field whose name was already used by an earlier
+/// field in the list. Used for parameter lists and any other named fields.
+fn duplicate_field(fields: &[Field]) -> Option<&Token> {
+    let mut names = HashSet::new();
+    fields
+        .iter()
+        .find(|field| !names.insert(field.name.to_string()))
+        .map(|field| &field.name)
+}
+
+/// Collect line comments which are the first token on their line, keyed by row.
+fn standalone_comments(tokens: &[Token]) -> HashMap<usize, String> {
+    let mut comments = HashMap::new();
+    let mut line_start = true;
+
+    for token in tokens {
+        if let TokenKind::LineComment(text) = &token.kind
+            && line_start
+        {
+            comments.insert(token.pos.row, text.clone());
+        }
+        line_start = token.kind == TokenKind::Newline;
+    }
+
+    comments
+}
@@ -0,0 +1,61 @@
+use crate::{
+    ast::TokenKind,
+    common::{ErrorStream, must, new_source},
+    config::Config,
+    parser::{LosslessAst, parse_lossless},
+};
+
+fn lossless(src: &str) -> LosslessAst {
+    must(parse_lossless(&new_source(src), &Config::test()).map_err(ErrorStream::from))
+}
+
+#[test]
+fn test_lossless_reconstructs_source() {
+    let src = "package main
+
+import std.io as   io
+
+// Adds two numbers
+func add(a int,   b int) int {
+    return a+b // sum
+}
+
+/* block
+   comment */
+pub func main() int {
+\tname := \"k\\toi\"
+    x := add(
+        1,
+        .5 as int,
+    )
+    if x == 2 { return 0 }
+    return 1
+}
+";
+
+    let tree = lossless(src);
+    assert_eq!(tree.to_source(), src);
+    assert_eq!(tree.ast.decls.len(), 2);
+}
+
+#[test]
+fn test_lossless_keeps_comment_tokens() {
+    let src = "func f() {\n    // note\n}\n";
+    let tree = lossless(src);
+
+    let comment = tree
+        .tokens
+        .iter()
+        .find(|t| matches!(t.kind, TokenKind::LineComment(_)))
+        .unwrap();
+
+    assert_eq!(tree.text(comment), "// note");
+    assert_eq!(tree.leading(comment), "    ");
+}
+
+#[test]
+fn test_lossless_empty_source() {
+    let src = "  \n\n";
+    let tree = lossless(src);
+    assert_eq!(tree.to_source(), src);
+}
@@ -3,3 +3,6 @@ mod parser_test;
 
 #[cfg(test)]
 mod depgraph_test;
+
+#[cfg(test)]
+mod lossless_test;
@@ -1,5 +1,9 @@
-use crate::ast::Printer;
-use crate::common::{compare_string_lines_or_panic, must, parse_string};
+use crate::ast::{Decl, Expr, Node, Printer, Stmt, TokenKind};
+use crate::common::{
+    compare_string_lines_or_panic, must, new_modpath, new_source_map, parse_string,
+};
+use crate::config::Config;
+use crate::parser::parse_source_map;
 
 fn compare_string(src: &str) {
     let ast = must(parse_string(src));
@@ -20,6 +24,45 @@ fn expect_error(src: &str, error: &str) {
     }
 }
 
+#[test]
+fn test_printer_records_node_ranges() {
+    let ast = must(parse_string(
+        r#"
+        func f() {
+        }
+
+        func g() int {
+            return 0
+        }
+    "#,
+    ));
+
+    let (out, ranges) = Printer::to_string_with_ranges(&ast);
+
+    let g = &ast.decls[1];
+    assert_eq!(
+        &out[ranges[&g.id()].clone()],
+        "func g() int {\n    return 0\n}"
+    );
+
+    let Decl::Func(func) = g else {
+        panic!("expected function declaration");
+    };
+    let ret = &func.body.stmts[0];
+    assert_eq!(&out[ranges[&ret.id()].clone()], "return 0");
+}
+
+#[test]
+fn test_null_literal() {
+    compare_string(
+        r#"
+        func f() {
+            a == null
+        }
+    "#,
+    );
+}
+
 #[test]
 fn test_literal_identifiers() {
     compare_string(
@@ -78,6 +121,99 @@ fn test_function_with_param_and_return() {
     );
 }
 
+#[test]
+fn test_function_with_multiple_return_values() {
+    compare_string(
+        r#"
+        func f(a int, b string) (int, string) {
+            return a, b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_function_single_type_in_parens() {
+    let paren = must(parse_string("func f() (int) { return 0 }"));
+    let plain = must(parse_string("func f() int { return 0 }"));
+    assert_eq!(Printer::to_string(&paren), Printer::to_string(&plain));
+}
+
+#[test]
+fn test_tuple_type_outside_return_type_error() {
+    let msg = "tuple types are only allowed as return types";
+    expect_error("func f(a (int, int)) {}", msg);
+    expect_error("func f() { var a (int, int) }", msg);
+    expect_error("func f() [2](int, int) {}", msg);
+    expect_error("func f() (int, (int, int)) {}", msg);
+    expect_error("type Pair (int, int)", msg);
+}
+
+#[test]
+fn test_function_return_type_missing_rparen() {
+    expect_error("func f() (int, string { return 0, \"\" }", "expected )");
+}
+
+#[test]
+fn test_doc_comment_attached_to_function() {
+    let ast = must(parse_string(
+        r#"
+// Add two numbers.
+// Overflow wraps around.
+func add(a int, b int) int {
+    return a + b
+}
+"#,
+    ));
+
+    let Decl::Func(func) = &ast.decls[0] else {
+        panic!("expected function");
+    };
+    assert_eq!(
+        func.doc.as_deref(),
+        Some("Add two numbers.\nOverflow wraps around.")
+    );
+}
+
+#[test]
+fn test_doc_comment_separated_by_empty_line() {
+    let ast = must(parse_string(
+        r#"
+// Not a doc comment.
+
+func f() {}
+"#,
+    ));
+    assert!(ast.docs().is_empty());
+}
+
+#[test]
+fn test_doc_comments_by_name() {
+    let ast = must(parse_string(
+        r#"
+// Maximum size.
+max :: 10 // Not part of the next doc
+
+// A point.
+pub struct Point { x int }
+
+/* Block comments are not docs */
+func f() {}
+
+func g() {} // Trailing comment
+// Documents h, not g.
+@inline
+func h() {}
+"#,
+    ));
+
+    let docs = ast.docs();
+    assert_eq!(docs.len(), 3);
+    assert_eq!(docs["max"], "Maximum size.");
+    assert_eq!(docs["Point"], "A point.");
+    assert_eq!(docs["h"], "Documents h, not g.");
+}
+
 #[test]
 fn test_function_with_multiple_params() {
     compare_string(
@@ -182,6 +318,38 @@ fn test_function_error_duplicate_param_name() {
     );
 }
 
+#[test]
+fn test_function_void_params_same_as_empty() {
+    let void = must(parse_string("func f(void) int { return 0 }"));
+    let empty = must(parse_string("func f() int { return 0 }"));
+    assert_eq!(Printer::to_string(&void), Printer::to_string(&empty));
+}
+
+#[test]
+fn test_extern_void_params() {
+    assert_pass("extern func f(void) void");
+}
+
+#[test]
+fn test_function_error_void_not_first_param() {
+    expect_error(
+        r#"
+        func f(void, a int) {}
+    "#,
+        "void must be the only parameter",
+    );
+}
+
+#[test]
+fn test_function_error_duplicate_param_marks_second() {
+    let map = new_source_map("func f(a int, b int, a bool) {}");
+    let Err(diag) = parse_source_map(new_modpath("main"), &map, &Config::test()) else {
+        panic!("expected error");
+    };
+    let caret = diag.render(&map).lines().nth(4).unwrap().to_owned();
+    assert_eq!(caret, format!("    |    {}^", " ".repeat(21)));
+}
+
 #[test]
 fn test_function_call_no_args() {
     compare_string(
@@ -286,6 +454,53 @@ fn test_variable_decl_multiple_types() {
     );
 }
 
+#[test]
+fn test_variable_decl_typed() {
+    compare_string(
+        r#"
+        func f() {
+            x int = 5
+            y foo.Bar = x
+            z [4]u8 = y
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_variable_decl_typed_disambiguation() {
+    let ast = must(parse_string(
+        r#"
+        func f() {
+            x int = 5
+            x := 5
+            x()
+        }
+    "#,
+    ));
+
+    let Decl::Func(func) = &ast.decls[0] else {
+        panic!("expected function");
+    };
+
+    let stmts = &func.body.stmts;
+    assert!(matches!(&stmts[0], Stmt::VarDecl(node) if node.ty.is_some()));
+    assert!(matches!(&stmts[1], Stmt::VarDecl(node) if node.ty.is_none()));
+    assert!(matches!(&stmts[2], Stmt::ExprStmt(Expr::Call(_))));
+}
+
+#[test]
+fn test_variable_decl_typed_missing_value_error() {
+    expect_error(
+        r#"
+        func f() {
+            x int
+        }
+    "#,
+        "expected =",
+    );
+}
+
 #[test]
 fn test_variable_decl_from_variable() {
     compare_string(
@@ -310,6 +525,43 @@ fn test_variable_decl_with_return() {
     );
 }
 
+#[test]
+fn test_var_decl() {
+    compare_string(
+        r#"
+        func f() {
+            var x int
+            var y = 5
+            var z []int = a
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_var_decl_error_missing_type_and_value() {
+    expect_error(
+        r#"
+        func f() {
+            var z
+        }
+    "#,
+        "variable declared with var must have a type or a value",
+    );
+}
+
+#[test]
+fn test_var_decl_error_missing_value() {
+    expect_error(
+        r#"
+        func f() {
+            var z int =
+        }
+    "#,
+        "expected expression",
+    );
+}
+
 #[test]
 fn test_variable_decl_error_missing_value_assign() {
     expect_error(
@@ -371,6 +623,97 @@ fn test_variable_assign() {
     );
 }
 
+#[test]
+fn test_op_assign_round_trip() {
+    compare_string(
+        r#"
+        func f() {
+            a += 1
+            b -= a
+            c *= 2
+            d /= c
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_assign_to_literal_error() {
+    expect_error(
+        r#"
+        func f() {
+            1 = 2
+        }
+    "#,
+        "invalid left hand value in assignment",
+    );
+}
+
+#[test]
+fn test_op_assign_to_call_error() {
+    expect_error(
+        r#"
+        func f() {
+            g() += 1
+        }
+    "#,
+        "invalid left hand value in assignment",
+    );
+}
+
+#[test]
+fn test_op_assign_to_binary_error() {
+    expect_error(
+        r#"
+        func f() {
+            a + b *= 2
+        }
+    "#,
+        "invalid left hand value in assignment",
+    );
+}
+
+#[test]
+fn test_package_decl() {
+    compare_string(
+        r#"
+        package main
+
+        import foo
+
+        func f() int {
+            return 0
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_package_decl_error_missing_name() {
+    expect_error("package\nfunc f() {}", "expected package name");
+}
+
+#[test]
+fn test_package_decl_error_after_func() {
+    expect_error(
+        "func f() {}\npackage main",
+        "package declaration must come before imports and declarations",
+    );
+}
+
+#[test]
+fn test_package_decl_error_after_import() {
+    expect_error(
+        "import foo\npackage main",
+        "package declaration must come before imports and declarations",
+    );
+}
+
+#[test]
+fn test_package_decl_error_declared_twice() {
+    expect_error("package main\npackage foo", "package is already declared");
+}
+
 #[test]
 fn test_import_module_path() {
     compare_string(
@@ -569,7 +912,7 @@ fn test_unclosed_call_paren_error() {
             a(b
         }
     "#,
-        "expected ,",
+        "unterminated argument list",
     );
 }
 
@@ -595,73 +938,203 @@ fn test_recovery_reports_errors_but_continues() {
 }
 
 #[test]
-fn test_param_list_missing_comma_reports_error() {
-    let src = r#"
-        func bad(a int b int) {}
-    "#;
-    match parse_string(src) {
-        Ok(_) => panic!("expected parse errors"),
-        Err(errs) => assert!(errs.len() >= 1),
-    }
-}
+fn test_missing_function_name() {
+    expect_error(
+        r#"
+        func () void {}
 
-#[test]
-fn test_unclosed_group_reports_error() {
-    let src = r#"
-        func f() {
-            (1
+        func good() {
+            return
         }
-    "#;
-    match parse_string(src) {
-        Ok(_) => panic!("expected parse errors"),
-        Err(errs) => assert!(errs.len() >= 1),
-    }
+    "#,
+        "expected function name",
+    );
 }
 
 #[test]
-fn test_pub_alone_reports_error_and_recovers() {
+fn test_missing_function_name_recovers_at_next_decl() {
     let src = r#"
-        pub
+        func () void {}
 
-        func good() {}
+        pub type Num 1
+
+        @inline
+        func bad() {
+            .
+        }
     "#;
     match parse_string(src) {
         Ok(_) => panic!("expected parse errors"),
         Err(errs) => {
-            // Should report at least one error but still attempt recovery
-            assert!(errs.len() >= 1);
+            assert_eq!(errs.len(), 3);
+            assert_eq!(errs.get(0).message, "expected function name");
+            assert_eq!(errs.get(1).message, "invalid type");
+            assert_eq!(errs.get(2).message, "expected expression");
         }
     }
 }
 
 #[test]
-fn test_malformed_import_reports_error() {
-    let src = r#"
-        import foo {
-            , bar
+fn test_assignment_in_if_condition() {
+    expect_error(
+        r#"
+        func f() {
+            if x = 1 { }
         }
-        func good() {}
-    "#;
-    match parse_string(src) {
-        Ok(_) => panic!("expected parse errors"),
-        Err(errs) => assert!(errs.len() >= 1),
-    }
+    "#,
+        "assignment is not an expression",
+    );
 }
 
 #[test]
-fn test_binary_addition() {
-    assert_pass(
+fn test_assignment_in_while_condition() {
+    expect_error(
         r#"
-        func sum(a int, b int) int {
-            return a + b
+        func f() {
+            while x := 1 { }
         }
     "#,
+        "assignment is not an expression",
     );
 }
 
 #[test]
-fn test_binary_subtraction_chained() {
-    assert_pass(
+fn test_assignment_in_call_argument() {
+    expect_error(
+        r#"
+        func f() {
+            g(x += 1)
+        }
+    "#,
+        "assignment is not an expression",
+    );
+}
+
+#[test]
+fn test_modulo_assignment_in_call_argument() {
+    expect_error(
+        r#"
+        func f() {
+            g(x %= 2)
+        }
+    "#,
+        "assignment is not an expression",
+    );
+}
+
+#[test]
+fn test_chained_assignment() {
+    expect_error(
+        r#"
+        func f() {
+            a = b = 1
+        }
+    "#,
+        "assignment is not an expression",
+    );
+}
+
+#[test]
+fn test_unterminated_argument_list_eof() {
+    expect_error("func f() {\n    g(1, 2", "unterminated argument list");
+}
+
+#[test]
+fn test_unterminated_argument_list_after_comma() {
+    expect_error(
+        r#"
+        func f() {
+            g(1,
+        }
+    "#,
+        "unterminated argument list",
+    );
+}
+
+#[test]
+fn test_param_list_missing_comma_reports_error() {
+    let src = r#"
+        func bad(a int b int) {}
+    "#;
+    match parse_string(src) {
+        Ok(_) => panic!("expected parse errors"),
+        Err(errs) => assert!(errs.len() >= 1),
+    }
+}
+
+#[test]
+fn test_unclosed_group_reports_error() {
+    let src = r#"
+        func f() {
+            (1
+        }
+    "#;
+    match parse_string(src) {
+        Ok(_) => panic!("expected parse errors"),
+        Err(errs) => assert!(errs.len() >= 1),
+    }
+}
+
+#[test]
+fn test_pub_alone_reports_error_and_recovers() {
+    let src = r#"
+        pub
+
+        func good() {}
+    "#;
+    match parse_string(src) {
+        Ok(_) => panic!("expected parse errors"),
+        Err(errs) => {
+            // Should report at least one error but still attempt recovery
+            assert!(errs.len() >= 1);
+        }
+    }
+}
+
+#[test]
+fn test_import_error_missing_path() {
+    expect_error("import\nfunc f() {}", "expected module name");
+}
+
+#[test]
+fn test_import_error_string_path() {
+    // Modules are imported by name, not by a path string
+    expect_error("import \"foo\"", "expected module name");
+}
+
+#[test]
+fn test_import_error_missing_alias() {
+    expect_error("import foo as\nfunc f() {}", "expected import alias name");
+}
+
+#[test]
+fn test_malformed_import_reports_error() {
+    let src = r#"
+        import foo {
+            , bar
+        }
+        func good() {}
+    "#;
+    match parse_string(src) {
+        Ok(_) => panic!("expected parse errors"),
+        Err(errs) => assert!(errs.len() >= 1),
+    }
+}
+
+#[test]
+fn test_binary_addition() {
+    assert_pass(
+        r#"
+        func sum(a int, b int) int {
+            return a + b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_subtraction_chained() {
+    assert_pass(
         r#"
         func op(a int, b int, c int) int {
             return a + b - c
@@ -692,6 +1165,69 @@ fn test_binary_mixed_precedence() {
     );
 }
 
+/// Parse a single expression statement and print it with every binary and
+/// unary expression wrapped in parens, showing how the operands were grouped.
+fn grouping(expr: &str) -> String {
+    fn group(expr: &Expr) -> String {
+        match expr {
+            Expr::Binary(bin) => format!("({} {} {})", group(&bin.lhs), bin.op, group(&bin.rhs)),
+            Expr::Unary(un) => format!("({}{})", un.op, group(&un.rhs)),
+            Expr::Group(grp) => group(&grp.inner),
+            Expr::Index(idx) => format!("({}[{}])", group(&idx.expr), group(&idx.index)),
+            Expr::Member(mem) => format!("({}.{})", group(&mem.expr), mem.field),
+            Expr::StructLit(lit) => format!("{}{{}}", lit.name),
+            Expr::Call(call) => {
+                let args: Vec<_> = call.args.iter().map(group).collect();
+                format!("({}({}))", group(&call.callee), args.join(", "))
+            }
+            Expr::Literal(tok) => tok.to_string(),
+            _ => panic!("unexpected expression"),
+        }
+    }
+
+    let ast = must(parse_string(&format!("func f() {{\n{}\n}}", expr)));
+    let Decl::Func(func) = &ast.decls[0] else {
+        panic!("expected function");
+    };
+    let Stmt::ExprStmt(expr) = &func.body.stmts[0] else {
+        panic!("expected expression statement");
+    };
+    group(expr)
+}
+
+#[test]
+fn test_binary_precedence_grouping() {
+    assert_eq!(grouping("1 + 2 * 3"), "(1 + (2 * 3))");
+    assert_eq!(grouping("1 * 2 + 3"), "((1 * 2) + 3)");
+    assert_eq!(grouping("a % b - c / d"), "((a % b) - (c / d))");
+}
+
+#[test]
+fn test_binary_left_associative() {
+    assert_eq!(grouping("1 - 2 - 3"), "((1 - 2) - 3)");
+    assert_eq!(grouping("a / b * c % d"), "(((a / b) * c) % d)");
+}
+
+#[test]
+fn test_comparison_precedence_grouping() {
+    assert_eq!(grouping("a < b == c"), "((a < b) == c)");
+    assert_eq!(grouping("a != b >= c"), "(a != (b >= c))");
+    assert_eq!(grouping("a + 1 <= b * 2"), "((a + 1) <= (b * 2))");
+    assert_eq!(grouping("a == b != c"), "((a == b) != c)");
+}
+
+#[test]
+fn test_comparison_chain_round_trip() {
+    compare_string(
+        r#"
+        func f() {
+            a < b == c > d
+            a <= b != c >= d
+        }
+    "#,
+    );
+}
+
 #[test]
 fn test_binary_logical_mixed() {
     assert_pass(
@@ -785,6 +1321,34 @@ fn test_if_stmt_with_complex_conditions() {
     );
 }
 
+#[test]
+fn test_if_stmt_with_init() {
+    compare_string(
+        r#"
+        func f() {
+            if x := f(); x > 0 {
+                g(x)
+            } else if y = x; y {
+                h()
+            }
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_if_stmt_init_missing_condition() {
+    expect_error(
+        r#"
+        func f() {
+            if x := f(); {
+            }
+        }
+    "#,
+        "expected expression",
+    );
+}
+
 #[test]
 fn test_if_stmt_error_missing_condition() {
     expect_error(
@@ -810,6 +1374,19 @@ fn test_if_stmt_error_missing_body() {
     );
 }
 
+#[test]
+fn test_if_stmt_error_else_without_block() {
+    expect_error(
+        r#"
+        func f() {
+            if true {
+            } else x
+        }
+    "#,
+        "expected {",
+    );
+}
+
 #[test]
 fn test_while_stmt_simple() {
     compare_string(
@@ -979,6 +1556,40 @@ fn test_unary_neg_grouped() {
     );
 }
 
+#[test]
+fn test_unary_round_trip() {
+    compare_string(
+        r#"
+        func f() {
+            -5
+            !true
+            -(a + b)
+            --x
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_unary_grouping() {
+    assert_eq!(grouping("--x"), "(-(-x))");
+    assert_eq!(grouping("-(a + b)"), "(-(a + b))");
+    assert_eq!(grouping("!a == b"), "((!a) == b)");
+    assert_eq!(grouping("-a * b"), "((-a) * b)");
+}
+
+#[test]
+fn test_unary_plus() {
+    compare_string(
+        r#"
+        func f() {
+            +a
+            -+b
+        }
+    "#,
+    );
+}
+
 #[test]
 fn test_nomangle_modifier_on_func() {
     compare_string(
@@ -1091,6 +1702,30 @@ fn test_modifier_on_pub_extern() {
     );
 }
 
+#[test]
+fn test_modifier_same_line_as_func() {
+    let ast = must(parse_string("@inline func foo() {}"));
+    assert_eq!(ast.decls.len(), 1);
+    match &ast.decls[0] {
+        Decl::Func(node) => {
+            assert_eq!(node.modifiers.len(), 1);
+            assert_eq!(node.modifiers[0].modifier.to_string(), "inline");
+            assert!(node.modifiers[0].args.is_empty());
+        }
+        _ => panic!("expected function declaration"),
+    }
+}
+
+#[test]
+fn test_modifiers_same_line_as_pub_func() {
+    assert_pass("@inline @naked pub func foo() {}");
+}
+
+#[test]
+fn test_modifier_same_line_as_extern() {
+    assert_pass("@alias puts extern func print(s string)");
+}
+
 #[test]
 fn test_modifier_missing_decl_error() {
     expect_error(
@@ -1129,6 +1764,57 @@ fn test_for_loop_basic() {
     );
 }
 
+#[test]
+fn test_for_loop_condition_only_is_while() {
+    let src = r#"
+        func f() {
+            for x < 10 {
+                g()
+            }
+        }
+    "#;
+    let ast = must(parse_string(src));
+    let Decl::Func(func) = &ast.decls[0] else {
+        panic!("expected function");
+    };
+    assert!(matches!(&func.body.stmts[0], Stmt::While(_)));
+
+    // Printed as written
+    compare_string_lines_or_panic(Printer::to_string(&ast), src.to_string());
+}
+
+#[test]
+fn test_for_loop_infinite_is_while_true() {
+    let ast = must(parse_string("func f() {\nfor {\nbreak\n}\n}"));
+    let Decl::Func(func) = &ast.decls[0] else {
+        panic!("expected function");
+    };
+    let Stmt::While(node) = &func.body.stmts[0] else {
+        panic!("expected while loop");
+    };
+    assert!(matches!(&node.expr, Expr::Literal(tok) if tok.kind == TokenKind::True));
+    assert_eq!(node.block.stmts.len(), 1);
+
+    // Printed as written
+    compare_string_lines_or_panic(
+        Printer::to_string(&ast),
+        "func f() {\n    for {\n        break\n    }\n}".to_string(),
+    );
+}
+
+#[test]
+fn test_for_loop_declaration_without_condition_error() {
+    expect_error(
+        r#"
+        func f() {
+            for i := 0 {
+            }
+        }
+    "#,
+        "expected ;",
+    );
+}
+
 #[test]
 fn test_for_loop_with_call_in_init_and_post() {
     assert_pass(
@@ -1199,6 +1885,265 @@ fn test_type_decl_imported_base_type() {
     compare_string(r#"type Foo bar.Baz"#);
 }
 
+#[test]
+fn test_type_decl_alias_with_eq() {
+    let ast = must(parse_string("type Celsius = f32"));
+    let Decl::Type(node) = &ast.decls[0] else {
+        panic!("expected type declaration");
+    };
+    assert!(!node.unique);
+    assert!(node.eq.is_some());
+    assert_eq!(node.name.to_string(), "Celsius");
+
+    // Printed as written
+    compare_string_lines_or_panic(Printer::to_string(&ast), "type Celsius = f32".to_string());
+}
+
+#[test]
+fn test_type_decl_unique_with_eq_error() {
+    expect_error(
+        r#"unique type ID = u64"#,
+        "unique type cannot be declared as an alias with '='",
+    );
+}
+
+#[test]
+fn test_struct_decl() {
+    compare_string(
+        r#"
+        struct Person {
+            name string
+            age int
+        }
+
+        pub struct Empty {
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_struct_decl_comma_separated_fields() {
+    let ast = must(parse_string("struct Point { x int, y int, }"));
+    let Decl::Struct(node) = &ast.decls[0] else {
+        panic!("expected struct declaration");
+    };
+    assert_eq!(node.name.to_string(), "Point");
+    assert_eq!(node.fields.len(), 2);
+    assert_eq!(node.fields[1].name.to_string(), "y");
+}
+
+#[test]
+fn test_struct_decl_duplicate_field_error() {
+    expect_error(
+        r#"
+        struct Point {
+            x int
+            x int
+        }
+    "#,
+        "duplicate field name",
+    );
+}
+
+#[test]
+fn test_struct_decl_missing_field_type_error() {
+    expect_error(r#"struct Point { x, y int }"#, "invalid type");
+}
+
+#[test]
+fn test_struct_literal() {
+    compare_string(
+        r#"
+        func f() {
+            p := Point{x: 1, y: a + 2}
+            e := Empty{}
+            g(Line{from: p, to: Point{x: 3, y: 4}})
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_struct_literal_multiline_fields() {
+    let ast = must(parse_string(
+        r#"
+        func f() {
+            p := Person{
+                name: "John",
+                age: 32,
+            }
+        }
+    "#,
+    ));
+    let Decl::Func(func) = &ast.decls[0] else {
+        panic!("expected function");
+    };
+    let Stmt::VarDecl(decl) = &func.body.stmts[0] else {
+        panic!("expected variable declaration");
+    };
+    let Expr::StructLit(lit) = &decl.expr else {
+        panic!("expected struct literal");
+    };
+    assert_eq!(lit.name.to_string(), "Person");
+    assert_eq!(lit.fields.len(), 2);
+}
+
+#[test]
+fn test_struct_literal_member_grouping() {
+    assert_eq!(grouping("Point{x: 1}.x"), "(Point{}.x)");
+}
+
+#[test]
+fn test_struct_literal_not_parsed_in_if_header() {
+    compare_string(
+        r#"
+        func f() {
+            if a {
+                b
+            }
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_struct_literal_positional_fields_error() {
+    // Only the named field form is supported
+    expect_error(
+        r#"
+        func f() {
+            p := Point{1, 2}
+        }
+    "#,
+        "expected field name",
+    );
+}
+
+#[test]
+fn test_struct_literal_missing_comma_error() {
+    expect_error(
+        r#"
+        func f() {
+            p := Point{x: 1 y: 2}
+        }
+    "#,
+        "expected ,",
+    );
+}
+
+#[test]
+fn test_type_decl_array() {
+    compare_string(r#"type Buf [4]int"#);
+}
+
+#[test]
+fn test_type_decl_array_const_expr_size() {
+    compare_string(r#"type Buf [2 + 2]int"#);
+}
+
+#[test]
+fn test_array_param_type() {
+    compare_string(
+        r#"
+        func f(a [4][2]int) {
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_slice_type() {
+    compare_string(
+        r#"
+        func f(a []int, b [][]string) {
+            c [][4]bool = g()
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_slice_type_missing_rbrack_error() {
+    expect_error(
+        r#"
+        func f(a [int) {
+        }
+    "#,
+        "expected ]",
+    );
+}
+
+#[test]
+fn test_index_expression() {
+    compare_string(
+        r#"
+        func f(a [4][2]int, i int) {
+            x := a[i + 1][0]
+            y [2]int = a[3]
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_operand_on_next_line() {
+    assert_eq!(grouping("1 +\n2"), "(1 + 2)");
+    assert_eq!(grouping("a &&\n\n  b ||\n c"), "((a && b) || c)");
+    assert_eq!(grouping("1 *\n2 + 3"), "((1 * 2) + 3)");
+}
+
+#[test]
+fn test_complete_expression_ends_at_newline() {
+    let ast = must(parse_string(
+        r#"
+        func f() {
+            a := 1 + 2
+            -3
+        }
+    "#,
+    ));
+    let Decl::Func(func) = &ast.decls[0] else {
+        panic!("expected function");
+    };
+    assert_eq!(func.body.stmts.len(), 2);
+    assert!(matches!(func.body.stmts[1], Stmt::ExprStmt(Expr::Unary(_))));
+}
+
+#[test]
+fn test_index_chaining_grouping() {
+    assert_eq!(grouping("a[0][1]"), "((a[0])[1])");
+    assert_eq!(grouping("foo()[0]"), "((foo())[0])");
+    assert_eq!(grouping("foo(1)[0](2)"), "(((foo(1))[0])(2))");
+    assert_eq!(grouping("-a[i + 1]"), "(-(a[(i + 1)]))");
+    assert_eq!(grouping("a[0] * b[1]"), "((a[0]) * (b[1]))");
+}
+
+#[test]
+fn test_member_chaining_grouping() {
+    assert_eq!(grouping("a.b.c"), "((a.b).c)");
+    assert_eq!(grouping("a[0].b"), "((a[0]).b)");
+    assert_eq!(grouping("a.b[0]"), "((a.b)[0])");
+    assert_eq!(grouping("-p.x + q.y"), "((-(p.x)) + (q.y))");
+}
+
+#[test]
+fn test_index_missing_rbrack_error() {
+    expect_error(
+        r#"
+        func f(a [4]int) {
+            x := a[0
+        }
+    "#,
+        "expected ]",
+    );
+}
+
+#[test]
+fn test_array_type_missing_rbrack_error() {
+    expect_error(r#"type Buf [4 int"#, "expected ]");
+}
+
 #[test]
 fn test_type_decl_missing_name_error() {
     expect_error(r#"type 123 int"#, "expected type name");
@@ -1311,3 +2256,237 @@ fn test_cast_missing_type_error() {
         "invalid type",
     );
 }
+
+#[test]
+fn test_multi_line_call_arguments() {
+    let ast = must(parse_string(
+        r#"
+        func f() {
+            g(
+                1,
+                2
+            )
+            return
+        }
+    "#,
+    ));
+
+    let Decl::Func(func) = &ast.decls[0] else {
+        panic!("expected function");
+    };
+    assert_eq!(func.body.stmts.len(), 2);
+    let Stmt::ExprStmt(Expr::Call(call)) = &func.body.stmts[0] else {
+        panic!("expected call statement");
+    };
+    assert_eq!(call.args.len(), 2);
+}
+
+#[test]
+fn test_multi_line_group_expression() {
+    assert_pass(
+        r#"
+        func f() int {
+            a := (1 +
+                2)
+            return a
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_statement_still_terminated_by_newline() {
+    let ast = must(parse_string(
+        r#"
+        func f() {
+            g(1)
+            h(2)
+        }
+    "#,
+    ));
+
+    let Decl::Func(func) = &ast.decls[0] else {
+        panic!("expected function");
+    };
+    assert_eq!(func.body.stmts.len(), 2);
+}
+
+#[test]
+fn test_error_at_eof_points_past_last_token() {
+    let src = "func f()   ";
+    let map = new_source_map(src);
+    let Err(diag) = parse_source_map(new_modpath("main"), &map, &Config::test()) else {
+        panic!("expected error");
+    };
+
+    // The caret is placed after the trailing whitespace, not on ')'
+    let caret = diag.render(&map).lines().nth(4).unwrap().to_owned();
+    assert_eq!(caret, format!("    |    {}^", " ".repeat(src.len())));
+}
+
+#[test]
+fn test_arrow_function_expands_to_return() {
+    let ast = must(parse_string("func sq(x int) int => x * x"));
+
+    let Decl::Func(func) = &ast.decls[0] else {
+        panic!("expected function");
+    };
+    assert_eq!(func.body.stmts.len(), 1);
+    let Stmt::Return(ret) = &func.body.stmts[0] else {
+        panic!("expected return statement");
+    };
+    assert!(matches!(ret.expr, Some(Expr::Binary(_))));
+    assert_ne!(ret.id(), func.body.id());
+
+    // Printed as written
+    compare_string_lines_or_panic(
+        Printer::to_string(&ast),
+        "func sq(x int) int => x * x".to_string(),
+    );
+}
+
+#[test]
+fn test_arrow_function_without_return_type() {
+    assert_pass(
+        r#"
+        func f() => g()
+        func g() {}
+    "#,
+    );
+}
+
+#[test]
+fn test_arrow_function_missing_expression() {
+    expect_error("func f() int =>", "expected expression");
+}
+
+#[test]
+fn test_string_escapes_printed_back() {
+    compare_string(
+        r#"
+        func f() {
+            s := "a\n\"b\"\t\\"
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_ternary_expression() {
+    compare_string(
+        r#"
+        func f(a bool, b bool) int {
+            return a ? 1 : b ? 2 : 3
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_ternary_is_right_associative() {
+    let ast = must(parse_string("func f() {\na ? 1 : b ? 2 : 3\n}"));
+    let Decl::Func(func) = &ast.decls[0] else {
+        panic!("expected function");
+    };
+    let Stmt::ExprStmt(Expr::Ternary(outer)) = &func.body.stmts[0] else {
+        panic!("expected ternary expression");
+    };
+    assert!(matches!(*outer.otherwise, Expr::Ternary(_)));
+}
+
+#[test]
+fn test_ternary_missing_colon_error() {
+    expect_error(
+        r#"
+        func f(a bool) int {
+            return a ? 1
+        }
+    "#,
+        "expected :",
+    );
+}
+
+#[test]
+fn test_block_expression() {
+    compare_string(
+        r#"
+        func f() int {
+            x := {
+                a := 1
+                a + 1
+            }
+            return {
+                x * 2
+            }
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_block_expression_as_argument() {
+    compare_string(
+        r#"
+        func f() {
+            g({
+                a := 1
+                -a
+            })
+            h(1, {
+                if a {
+                    return
+                }
+                2
+            })
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_block_expression_not_allowed_in_condition() {
+    expect_error(
+        r#"
+        func f() {
+            while { true } {
+            }
+        }
+    "#,
+        "expected expression",
+    );
+}
+
+#[test]
+fn test_const_decl() {
+    compare_string(
+        r#"
+        max :: 10
+        pub name :: "koi"
+        size :: 4 * 8
+    "#,
+    );
+}
+
+#[test]
+fn test_const_decl_with_type() {
+    compare_string(
+        r#"
+        max u8 :: 10
+        pub name string :: "koi"
+        id foo.Id :: 4 * 8
+    "#,
+    );
+}
+
+#[test]
+fn test_const_decl_with_type_missing_value() {
+    expect_error("max int ::", "expected expression");
+}
+
+#[test]
+fn test_global_variable_error() {
+    expect_error(
+        r#"count := 0"#,
+        "global variables are not allowed, use '::' for constants",
+    );
+}
@@ -15,12 +15,65 @@ pub fn scan(src: &Source, config: &Config) -> Res<Vec<Token>> {
     scanner.scan()
 }
 
+/// Scan the source, keeping comments as LineComment and BlockComment tokens
+/// instead of skipping them. The parser reads doc comments from these tokens
+/// and removes them before parsing.
+pub fn scan_with_comments(src: &Source, config: &Config) -> Res<Vec<Token>> {
+    let mut scanner = Scanner::new(src, config);
+    scanner.keep_comments = true;
+    scanner.scan()
+}
+
+/// Scan the source, calling progress with the number of bytes consumed so far
+/// and the total number of bytes at the end of every line. It is always
+/// called a final time with both equal once the whole source is scanned.
+/// Meant for tools showing progress while scanning large files.
+pub fn scan_with_progress(
+    src: &Source,
+    config: &Config,
+    progress: &mut dyn FnMut(usize, usize),
+) -> Res<Vec<Token>> {
+    let mut scanner = Scanner::new(src, config);
+    scanner.progress = Some(progress);
+    scanner.scan()
+}
+
+/// Create the EOF token for a source. Its position is the byte just past the
+/// last character, so any trailing whitespace and newlines are accounted for.
+pub fn eof_token(src: &Source) -> Token {
+    let offset = src.src.len();
+    let line_begin = src
+        .src
+        .iter()
+        .rposition(|&b| b == b'\n')
+        .map_or(0, |i| i + 1);
+
+    let pos = Pos {
+        source_id: src.id,
+        row: src.src.iter().filter(|&&b| b == b'\n').count(),
+        col: Scanner::char_count(&src.src[line_begin..]),
+        offset,
+        line_begin,
+    };
+
+    Token::new(TokenKind::Eof, 0, pos)
+}
+
 struct Scanner<'a> {
     source: &'a Source,
     pos: usize,
     row: usize,
     col: usize,
     line_begin: usize,
+    /// Unmatched open parens, brackets, and braces. Newlines directly inside
+    /// parens or brackets are not significant and are not emitted as tokens.
+    /// They are again inside a brace, eg. a block expression passed as an
+    /// argument.
+    brackets: Vec<TokenKind>,
+    /// Emit comment tokens instead of skipping them.
+    keep_comments: bool,
+    /// Called with (consumed, total) bytes after each line.
+    progress: Option<&'a mut dyn FnMut(usize, usize)>,
     _config: &'a Config,
     diag: Diagnostics,
 }
@@ -34,6 +87,9 @@ impl<'a> Scanner<'a> {
             col: 0,
             row: 0,
             line_begin: 0,
+            brackets: Vec::new(),
+            keep_comments: false,
+            progress: None,
             diag: Diagnostics::new(),
         }
     }
@@ -44,6 +100,7 @@ impl<'a> Scanner<'a> {
         // No input
         if self.eof() {
             info!("No input");
+            self.report_progress();
             return Ok(Vec::new());
         }
 
@@ -54,6 +111,7 @@ impl<'a> Scanner<'a> {
                 Ok(toks) => {
                     if self.diag.is_empty() {
                         debug!("Success: {} tokens", toks.len());
+                        self.report_progress();
                         return Ok(toks);
                     }
                 }
@@ -66,14 +124,22 @@ impl<'a> Scanner<'a> {
         }
 
         info!("Fail: finished with {} errors", self.diag.num_errors());
+        self.report_progress();
         Err(self.diag)
     }
 
+    fn report_progress(&mut self) {
+        let total = self.len();
+        if let Some(progress) = &mut self.progress {
+            progress(self.pos.min(total), total);
+        }
+    }
+
     fn scan_all(&mut self) -> Result<Vec<Token>, Report> {
         let mut tokens = Vec::new();
 
         while !self.eof() {
-            let (token, consumed) = match self.cur() {
+            let (mut token, consumed) = match self.cur() {
                 // Whitespace tokens are ignored and not added to token list
                 v if Scanner::is_whitespace(v) => (
                     Token::new(TokenKind::Whitespace, 0, self.pos()),
@@ -83,11 +149,16 @@ impl<'a> Scanner<'a> {
                 // Line comment
                 b'/' if matches!(self.peek(), Some(b'/')) => {
                     let len = self.peek_while(|b| b != b'\n');
-                    (Token::new(TokenKind::LineComment, 0, self.pos()), len)
+                    let text = self.source.str_range(self.pos, self.pos + len).to_owned();
+                    (
+                        Token::new(TokenKind::LineComment(text), len, self.pos()),
+                        len,
+                    )
                 }
 
                 // Block comment
                 b'/' if matches!(self.peek(), Some(b'*')) => {
+                    let start = self.pos();
                     let mut depth = 1;
                     let mut i = self.pos + 2; // Skip opening /*
 
@@ -123,12 +194,16 @@ impl<'a> Scanner<'a> {
                             self.col = 0;
                         }
                     }
-                    self.col = i - self.line_begin;
+                    self.col = self.width(self.line_begin, i);
 
-                    (
-                        Token::new(TokenKind::BlockComment, 0, self.pos()),
-                        i - self.pos,
-                    )
+                    let text = self.source.str_range(self.pos, i).to_owned();
+                    let mut token = Token::new(TokenKind::BlockComment(text), i - self.pos, start);
+                    token.end_pos = Pos {
+                        offset: i,
+                        ..self.pos()
+                    };
+
+                    (token, i - self.pos)
                 }
 
                 // Newline character resets the row and col.
@@ -141,8 +216,8 @@ impl<'a> Scanner<'a> {
                 }
 
                 // Identifier or keyword
-                v if Scanner::is_alpha(v) => {
-                    let length = self.peek_while(Scanner::is_alphanum);
+                _ if self.char_at(self.pos).is_some_and(Scanner::is_ident_start) => {
+                    let length = self.ident_len(self.pos);
                     let lexeme = self.source.str_range(self.pos, self.pos + length);
 
                     if let Some(k) = str_to_token(lexeme) {
@@ -155,47 +230,43 @@ impl<'a> Scanner<'a> {
                     }
                 }
 
-                // Number
-                v if Scanner::is_number(v) => {
-                    // Hex literal: 0x… or 0X…
-                    if v == b'0' && matches!(self.peek(), Some(b'x') | Some(b'X')) {
-                        let prefix_len = 2; // "0x"
-                        let digits_start = self.pos + prefix_len;
-                        let digits_len = {
-                            let mut n = 0;
-                            while digits_start + n < self.len()
-                                && Scanner::is_hex_digit(self.source.src[digits_start + n])
-                            {
-                                n += 1;
-                            }
-                            n
-                        };
-
-                        if digits_len == 0 {
-                            return Err(self.error("hex literal has no digits", prefix_len));
-                        }
-
-                        let length = prefix_len + digits_len;
-                        let digits = self
-                            .source
-                            .str_range(digits_start, digits_start + digits_len);
-                        let value = i64::from_str_radix(digits, 16)
-                            .map_err(|_| self.error("invalid hex literal", length))?;
+                // Escaped identifier, never treated as a keyword
+                b'#' if self
+                    .char_at(self.pos + 1)
+                    .is_some_and(Scanner::is_ident_start) =>
+                {
+                    let length = self.ident_len(self.pos + 1) + 1;
+                    let name = self.source.str_range(self.pos + 1, self.pos + length);
+                    (
+                        Token::new(TokenKind::IdentLit(name.to_owned()), length, self.pos()),
+                        length,
+                    )
+                }
 
-                        (
-                            Token::new(TokenKind::IntLit(value), length, self.pos()),
-                            length,
-                        )
+                // Number, or float with a leading period like .5
+                v if Scanner::is_number(v) || self.is_leading_dot_float() => {
+                    // Prefixed integer literal: 0x…, 0o…, or 0b…
+                    if v == b'0'
+                        && let Some((radix, name)) = self.peek().and_then(Scanner::int_prefix)
+                    {
+                        self.scan_prefixed_int(radix, name)?
                     } else {
                         let mut length = self.peek_while(Scanner::is_numeric);
                         let mut lexeme = self.source.str_range(self.pos, self.pos + length);
 
-                        // Ignore ending period for now, checked by Checker
-                        if lexeme.ends_with(".") {
+                        // A period followed by a name is a member access on the
+                        // number, like 1.foo, otherwise 1. is a float.
+                        if lexeme.ends_with(".")
+                            && self
+                                .char_at(self.pos + length)
+                                .is_some_and(Scanner::is_ident_start)
+                        {
                             length -= 1;
                             lexeme = lexeme.trim_end_matches(".");
                         }
 
+                        let lexeme = &self.strip_separators(0, lexeme, Scanner::is_number)?;
+
                         let kind = if lexeme.contains('.') {
                             match lexeme.parse() {
                                 Ok(f) => TokenKind::FloatLit(f),
@@ -208,6 +279,7 @@ impl<'a> Scanner<'a> {
                             }
                         };
 
+                        self.check_number_end(length)?;
                         (Token::new(kind, length, self.pos()), length)
                     }
                 }
@@ -217,15 +289,21 @@ impl<'a> Scanner<'a> {
 
                 // Byte string
                 b'\'' => {
-                    let (tokens, length) = self.scan_string(b'\'')?;
-                    if length != 3 {
+                    let (token, length) = self.scan_string(b'\'')?;
+                    if !matches!(&token.kind, TokenKind::StringLit(s) if s.len() == 1) {
                         return Err(self.error("byte string must be exactly one character", length));
                     }
 
-                    (tokens, length)
+                    (token, length)
                 }
 
-                // Match either one or two tokens (single/double symbol)
+                // All symbols are ascii, any other character is illegal
+                v if !v.is_ascii() => return Err(self.error("illegal token", 1)),
+
+                // Match either one or two tokens (single/double symbol). The
+                // two character lexeme is looked up directly so the longest
+                // match always wins. Symbols are ascii, so a following
+                // multibyte character can never be part of one.
                 _ => {
                     let try_match = |len| {
                         let lexeme = self.source.str_range(self.pos, self.pos + len);
@@ -235,7 +313,7 @@ impl<'a> Scanner<'a> {
 
                     if let Some(token) = self
                         .peek()
-                        .filter(|&c| !Scanner::is_alphanum(c))
+                        .filter(|&c| c.is_ascii() && !Scanner::is_alphanum(c))
                         .and_then(|_| try_match(2))
                     {
                         (token, 2)
@@ -248,20 +326,49 @@ impl<'a> Scanner<'a> {
             };
 
             trace!("consumed token: '{}'", token);
+
+            // Columns count characters while lengths and offsets are in bytes,
+            // so the end column must be corrected for multibyte characters.
+            // Tokens spanning several lines already have their end set.
+            if token.pos.row == token.end_pos.row {
+                token.end_pos.col = token.pos.col + self.width(self.pos, self.pos + token.length);
+            }
+            let width = self.width(self.pos, self.pos + consumed);
             self.pos += consumed;
 
             // Col must not advance after a newline. It is reset to 0 above and must remain 0
             // before next iteration. Incrementing now would cause the first token on the new
             // line to have col=1
             // Block comment must also be skipped here to not add all of the comments content to col
-            if !matches!(token.kind, TokenKind::Newline | TokenKind::BlockComment) {
-                self.col += consumed;
+            if !matches!(token.kind, TokenKind::Newline | TokenKind::BlockComment(_)) {
+                self.col += width;
+            }
+
+            if token.kind == TokenKind::Newline {
+                self.report_progress();
             }
 
-            if !matches!(
-                token.kind,
-                TokenKind::Whitespace | TokenKind::BlockComment | TokenKind::LineComment
-            ) {
+            match token.kind {
+                TokenKind::LParen | TokenKind::LBrack | TokenKind::LBrace => {
+                    self.brackets.push(token.kind.clone())
+                }
+                TokenKind::RParen => self.close_bracket(TokenKind::LParen),
+                TokenKind::RBrack => self.close_bracket(TokenKind::LBrack),
+                TokenKind::RBrace => self.close_bracket(TokenKind::LBrace),
+                _ => {}
+            }
+
+            let skip = match token.kind {
+                TokenKind::Whitespace => true,
+                TokenKind::LineComment(_) | TokenKind::BlockComment(_) => !self.keep_comments,
+                TokenKind::Newline => matches!(
+                    self.brackets.last(),
+                    Some(TokenKind::LParen | TokenKind::LBrack)
+                ),
+                _ => false,
+            };
+
+            if !skip {
                 tokens.push(token);
             }
         }
@@ -269,6 +376,15 @@ impl<'a> Scanner<'a> {
         Ok(tokens)
     }
 
+    /// Close the innermost open bracket of the given kind, along with any left
+    /// unclosed inside it. A closing bracket without a matching open one is
+    /// ignored.
+    fn close_bracket(&mut self, open: TokenKind) {
+        if let Some(i) = self.brackets.iter().rposition(|kind| *kind == open) {
+            self.brackets.truncate(i);
+        }
+    }
+
     fn pos(&self) -> Pos {
         Pos {
             source_id: self.source.id,
@@ -313,6 +429,41 @@ impl<'a> Scanner<'a> {
         Report::code_error_len(msg, &self.pos(), length)
     }
 
+    /// Create error starting offset bytes after the current position.
+    fn error_at(&self, offset: usize, msg: &str, length: usize) -> Report {
+        let mut pos = self.pos();
+        pos.col += self.width(self.pos, self.pos + offset);
+        pos.offset += offset;
+        Report::code_error_len(msg, &pos, length)
+    }
+
+    /// Check if the current period starts a float literal like .5. A period
+    /// directly after a name, number, or closing bracket is a member access.
+    fn is_leading_dot_float(&self) -> bool {
+        let before = self.pos.checked_sub(1).map(|i| self.at(i));
+        self.cur() == b'.'
+            && self.peek().is_some_and(Scanner::is_number)
+            && !before.is_some_and(|b| Scanner::is_alphanum(b) || b == b')' || b == b']')
+    }
+
+    /// Reports an error if the number literal of the given length is immediately
+    /// followed by a letter, eg. 123abc. The error points at the letter.
+    fn check_number_end(&self, length: usize) -> Result<(), Report> {
+        let end = self.pos + length;
+        if self.char_at(end).is_some_and(Scanner::is_ident_start) {
+            let mut pos = self.pos();
+            pos.col += length;
+            pos.offset += length;
+            return Err(Report::code_error_len(
+                "invalid digit in number literal",
+                &pos,
+                1,
+            ));
+        }
+
+        Ok(())
+    }
+
     /// Peeks tokens while predicate returns true. Returns number of tokens peeked.
     fn peek_while<P>(&mut self, predicate: P) -> usize
     where
@@ -326,46 +477,232 @@ impl<'a> Scanner<'a> {
         consumed
     }
 
+    /// Scans an integer literal with a base prefix, starting at the current
+    /// position. The value is decoded here, so later stages only see the
+    /// integer while the token still spans the original text.
+    fn scan_prefixed_int(&mut self, radix: u32, name: &str) -> Result<(Token, usize), Report> {
+        let prefix_len = 2; // "0x"
+        self.pos += prefix_len;
+        let digits_len = self.peek_while(|b| Scanner::is_hex_digit(b) || b == b'_');
+        self.pos -= prefix_len;
+
+        if digits_len == 0 {
+            return Err(self.error(&format!("{} literal has no digits", name), prefix_len));
+        }
+
+        let digits_start = self.pos + prefix_len;
+        let digits = self
+            .source
+            .str_range(digits_start, digits_start + digits_len);
+        let digits = &self.strip_separators(prefix_len, digits, Scanner::is_hex_digit)?;
+
+        // Hex digits are consumed for all bases to report digits like the 2
+        // in 0b12 instead of splitting the literal
+        if let Some(i) = digits.chars().position(|c| !c.is_digit(radix)) {
+            let mut pos = self.pos();
+            pos.col += prefix_len + i;
+            pos.offset += prefix_len + i;
+            return Err(Report::code_error_len(
+                &format!("invalid digit '{}' in {} literal", &digits[i..i + 1], name),
+                &pos,
+                1,
+            ));
+        }
+
+        let length = prefix_len + digits_len;
+        let value = i64::from_str_radix(digits, radix)
+            .map_err(|_| self.error(&format!("invalid {} literal", name), length))?;
+        self.check_number_end(length)?;
+
+        Ok((
+            Token::new(TokenKind::IntLit(value), length, self.pos()),
+            length,
+        ))
+    }
+
+    /// Returns the digits with all '_' separators removed. Separators are only
+    /// allowed between two digits, so leading, trailing, and doubled ones are
+    /// reported as errors. Offset is where digits begin relative to the
+    /// current position.
+    fn strip_separators(
+        &self,
+        offset: usize,
+        digits: &str,
+        is_digit: fn(u8) -> bool,
+    ) -> Result<String, Report> {
+        let bytes = digits.as_bytes();
+        for (i, &b) in bytes.iter().enumerate() {
+            if b != b'_' {
+                continue;
+            }
+
+            let after_digit = i > 0 && is_digit(bytes[i - 1]);
+            let before_digit = i + 1 < bytes.len() && is_digit(bytes[i + 1]);
+            if !after_digit || !before_digit {
+                return Err(self.error_at(
+                    offset + i,
+                    "digit separator '_' must be between two digits",
+                    1,
+                ));
+            }
+        }
+
+        Ok(digits.replace('_', ""))
+    }
+
     /// Scans a string literal, starting at the current position.
+    /// The token holds the decoded value with all escape sequences replaced.
     fn scan_string(&mut self, quote: u8) -> Result<(Token, usize), Report> {
-        self.pos += 1;
-        let mut length = self.peek_while(|b| b != quote && b != b'\n');
-        self.pos -= 1;
+        let mut value = Vec::new();
+        let mut length = 1; // Start quote
 
-        // Was string actually closed?
-        let check_pos = self.pos + length + 1; // Of end quote
-        if check_pos >= self.len() || self.at(check_pos) != quote {
-            let mut pos = self.pos();
-            pos.col += check_pos;
-            pos.offset += check_pos;
-            return Err(Report::code_error_len("expected end quote", &pos, 1));
+        loop {
+            let i = self.pos + length;
+
+            // Was string actually closed?
+            if i >= self.len() || self.at(i) == b'\n' {
+                return Err(self.error_at(length, "expected end quote", 1));
+            }
+
+            match self.at(i) {
+                b if b == quote => break,
+                b'\\' if i + 1 < self.len() && self.at(i + 1) == b'x' => {
+                    value.push(self.scan_hex_escape(length)?);
+                    length += 4;
+                }
+                b'\\' if i + 1 < self.len() && self.at(i + 1) != b'\n' => {
+                    let escaped = self.at(i + 1);
+                    let Some(b) = Scanner::unescape(escaped) else {
+                        let msg = format!("unknown escape sequence '\\{}'", escaped as char);
+                        return Err(self.error_at(length, &msg, 2));
+                    };
+
+                    value.push(b);
+                    length += 2;
+                }
+                b => {
+                    value.push(b);
+                    length += 1;
+                }
+            }
         }
 
-        length += 2; // Include start and end quote
-        let lexeme = self.source.str_range(self.pos + 1, self.pos + length - 1);
+        length += 1; // End quote
+
+        // Escapes are ascii and the rest is copied from valid utf-8 source
+        let value = String::from_utf8(value).expect("string literal is valid utf-8");
 
         Ok((
-            Token::new(TokenKind::StringLit(lexeme.to_string()), length, self.pos()),
+            Token::new(TokenKind::StringLit(value), length, self.pos()),
             length,
         ))
     }
 
+    /// Decode a hex byte escape, eg. \x7f, at the given offset from the current
+    /// position. Only ascii bytes are allowed so the string stays valid utf-8.
+    fn scan_hex_escape(&self, offset: usize) -> Result<u8, Report> {
+        let start = self.pos + offset + 2;
+        if start + 2 > self.len() || !(start..start + 2).all(|i| Scanner::is_hex_digit(self.at(i)))
+        {
+            return Err(self.error_at(offset, "expected two hex digits after '\\x'", 2));
+        }
+
+        let digit = |i: usize| (self.at(i) as char).to_digit(16).unwrap() as u8;
+        let b = digit(start) << 4 | digit(start + 1);
+        if b > 0x7f {
+            return Err(self.error_at(offset, "hex escape must be at most '\\x7f'", 4));
+        }
+
+        Ok(b)
+    }
+
+    /// Get the byte an escape sequence stands for, given the character
+    /// following the backslash.
+    fn unescape(b: u8) -> Option<u8> {
+        match b {
+            b'n' => Some(b'\n'),
+            b't' => Some(b'\t'),
+            b'r' => Some(b'\r'),
+            b'0' => Some(0),
+            b'\\' => Some(b'\\'),
+            b'"' => Some(b'"'),
+            b'\'' => Some(b'\''),
+            _ => None,
+        }
+    }
+
     fn is_number(n: u8) -> bool {
         n.is_ascii_digit()
     }
 
     fn is_numeric(n: u8) -> bool {
-        Scanner::is_number(n) || n == b'.'
+        Scanner::is_number(n) || n == b'.' || n == b'_'
     }
 
     fn is_hex_digit(n: u8) -> bool {
         n.is_ascii_hexdigit()
     }
 
+    /// Get the radix and name of an integer literal prefix, the character after the leading 0.
+    fn int_prefix(b: u8) -> Option<(u32, &'static str)> {
+        match b {
+            b'x' | b'X' => Some((16, "hex")),
+            b'o' | b'O' => Some((8, "octal")),
+            b'b' | b'B' => Some((2, "binary")),
+            _ => None,
+        }
+    }
+
     fn is_whitespace(b: u8) -> bool {
         b == b' ' || b == b'\t' || b == b'\r'
     }
 
+    /// Decode the utf-8 character starting at the given byte offset. Returns
+    /// None at eof or if the bytes are not valid utf-8.
+    fn char_at(&self, offset: usize) -> Option<char> {
+        let src = &self.source.src;
+        let end = (offset + 4).min(src.len());
+        if offset >= end {
+            return None;
+        }
+
+        let bytes = &src[offset..end];
+        let len = match str::from_utf8(bytes) {
+            Ok(_) => bytes.len(),
+            Err(e) => e.valid_up_to(),
+        };
+
+        str::from_utf8(&bytes[..len]).ok()?.chars().next()
+    }
+
+    /// Get the length in bytes of the identifier starting at the given offset.
+    fn ident_len(&self, offset: usize) -> usize {
+        let mut end = offset;
+        while let Some(c) = self.char_at(end)
+            && (Scanner::is_ident_start(c) || c.is_numeric())
+        {
+            end += c.len_utf8();
+        }
+
+        end - offset
+    }
+
+    /// Number of characters in the given byte range, used for columns.
+    fn width(&self, from: usize, to: usize) -> usize {
+        Scanner::char_count(&self.source.src[from..to])
+    }
+
+    /// Count the characters in utf-8 bytes by skipping continuation bytes.
+    fn char_count(bytes: &[u8]) -> usize {
+        bytes.iter().filter(|&&b| b & 0xC0 != 0x80).count()
+    }
+
+    /// Identifiers start with a letter or underscore and may contain any
+    /// unicode letter or number.
+    fn is_ident_start(c: char) -> bool {
+        c.is_alphabetic() || c == '_'
+    }
+
     fn is_alpha(b: u8) -> bool {
         b.is_ascii_lowercase() || b.is_ascii_uppercase() || b == b'_'
     }
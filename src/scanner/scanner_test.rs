@@ -2,7 +2,9 @@ use std::vec;
 
 use crate::{
     ast::{Token, TokenKind},
-    common::{must, scan_string},
+    common::{Pos, Span, must, new_source, new_source_map, scan_string},
+    config::Config,
+    scanner::{eof_token, scan, scan_with_comments, scan_with_progress},
 };
 
 fn scan_and_then<P>(src: &str, pred: P)
@@ -16,6 +18,18 @@ fn scan_and_error(src: &str) {
     assert!(scan_string(src).is_err());
 }
 
+/// Assert that the token has the given kind and length, and is neither eof
+/// nor invalid. The position is not compared.
+fn assert_token(tok: &Token, kind: TokenKind, length: usize) {
+    let expected = Token::new(kind, length, Pos::default());
+    assert!(
+        tok.eq_ignore_pos(&expected),
+        "got {:?}, expected {:?}",
+        tok,
+        expected
+    );
+}
+
 #[test]
 fn test_whitespace_only() {
     scan_and_then("  \t \t   \r  ", |toks| assert_eq!(toks.len(), 0));
@@ -27,9 +41,8 @@ fn test_identifier() {
     let _ = scan_and_then(expect.join(" ").as_str(), |toks| {
         assert_eq!(toks.len(), 4);
         for (i, t) in toks.iter().enumerate() {
-            assert!(!t.eof && !t.invalid);
-            assert_eq!(t.length, expect[i].len());
-            assert_eq!(t.kind, TokenKind::IdentLit(expect[i].to_string()));
+            let kind = TokenKind::IdentLit(expect[i].to_string());
+            assert_token(t, kind, expect[i].len());
         }
     });
 }
@@ -45,12 +58,27 @@ fn test_keywords() {
     });
 }
 
+#[test]
+fn test_escaped_identifier() {
+    scan_and_then("#func func #my_var", |toks| {
+        assert_eq!(toks.len(), 3);
+        assert_token(&toks[0], TokenKind::IdentLit("func".to_string()), 5);
+        assert_eq!(toks[1].kind, TokenKind::Func);
+        assert_eq!(toks[2].kind, TokenKind::IdentLit("my_var".to_string()));
+        assert_eq!(toks[2].pos.col, 11);
+    });
+}
+
+#[test]
+fn test_lone_hash_error() {
+    scan_and_error("# func");
+}
+
 #[test]
 fn test_number_integer() {
     scan_and_then("123", |toks| {
         assert_eq!(toks.len(), 1);
-        assert_eq!(toks[0].length, 3);
-        assert_eq!(toks[0].kind, TokenKind::IntLit(123));
+        assert_token(&toks[0], TokenKind::IntLit(123), 3);
     });
 }
 
@@ -58,8 +86,42 @@ fn test_number_integer() {
 fn test_number_float() {
     scan_and_then("1.23", |toks| {
         assert_eq!(toks.len(), 1);
-        assert_eq!(toks[0].length, 4);
-        assert_eq!(toks[0].kind, TokenKind::FloatLit(1.23));
+        assert_token(&toks[0], TokenKind::FloatLit(1.23), 4);
+    });
+}
+
+#[test]
+fn test_number_float_leading_dot() {
+    scan_and_then(".5 .25", |toks| {
+        assert_eq!(toks.len(), 2);
+        assert_token(&toks[0], TokenKind::FloatLit(0.5), 2);
+        assert_eq!(toks[1].kind, TokenKind::FloatLit(0.25));
+    });
+}
+
+#[test]
+fn test_number_float_trailing_dot() {
+    scan_and_then("1.", |toks| {
+        assert_eq!(toks.len(), 1);
+        assert_token(&toks[0], TokenKind::FloatLit(1.0), 2);
+    });
+}
+
+#[test]
+fn test_member_access_is_not_float() {
+    scan_and_then("a.b 1.foo", |toks| {
+        let kinds = toks.into_iter().map(|t| t.kind).collect::<Vec<_>>();
+        assert_eq!(
+            kinds,
+            vec![
+                TokenKind::IdentLit("a".into()),
+                TokenKind::Dot,
+                TokenKind::IdentLit("b".into()),
+                TokenKind::IntLit(1),
+                TokenKind::Dot,
+                TokenKind::IdentLit("foo".into()),
+            ]
+        );
     });
 }
 
@@ -71,6 +133,35 @@ fn test_number_between_symbols() {
     });
 }
 
+#[test]
+fn test_number_followed_by_letter_error() {
+    let err = scan_string("123abc").unwrap_err();
+    assert_eq!(err.len(), 1);
+    assert_eq!(err.get(0).message, "invalid digit in number literal");
+}
+
+#[test]
+fn test_number_followed_by_letter_points_at_letter() {
+    let map = new_source_map("123abc");
+    let diag = scan(map.sources().last().unwrap(), &Config::test()).unwrap_err();
+    let caret = diag.render(&map).lines().nth(4).unwrap().to_owned();
+    assert_eq!(caret, format!("    |    {}^", " ".repeat(3)));
+}
+
+#[test]
+fn test_hex_followed_by_letter_error() {
+    scan_and_error("0x1fg");
+}
+
+#[test]
+fn test_number_and_identifier_separated() {
+    scan_and_then("123 abc", |toks| {
+        assert_eq!(toks.len(), 2);
+        assert_eq!(toks[0].kind, TokenKind::IntLit(123));
+        assert_eq!(toks[1].kind, TokenKind::IdentLit("abc".to_string()));
+    });
+}
+
 #[test]
 fn test_number_double_decimal_error() {
     if scan_string("1.2.3").is_ok() {
@@ -80,35 +171,47 @@ fn test_number_double_decimal_error() {
 
 #[test]
 fn test_pos() {
-    let expect_pos = vec![(0, 0), (0, 4), (0, 7), (1, 0), (1, 6)]; // (row, col)
-    let expect_end = vec![(0, 3), (0, 7), (0, 8), (1, 5), (1, 11)]; // (row, col)
-    let expect_offset = vec![0, 4, 7, 8, 14];
-    let expect_line = vec![0, 0, 0, 8, 8];
-
-    scan_and_then("abc def\nhello world", |toks| {
-        assert_eq!(toks.len(), expect_pos.len());
-        for (i, t) in toks.iter().enumerate() {
-            assert_eq!(t.pos.row, expect_pos[i].0, "case {}", i + 1);
-            assert_eq!(t.pos.col, expect_pos[i].1, "case {}", i + 1);
-
-            assert_eq!(t.end_pos.row, expect_end[i].0, "case {}", i + 1);
-            assert_eq!(t.end_pos.col, expect_end[i].1, "case {}", i + 1);
-
-            assert_eq!(t.pos.offset, expect_offset[i], "case {}", i + 1);
-            assert_eq!(t.pos.line_begin, expect_line[i], "case {}", i + 1);
-        }
-    });
+    let toks = must(scan_string("abc def\nhello world"));
+
+    // (kind, length, row, col, offset, line_begin)
+    let ident = |s: &str| TokenKind::IdentLit(s.to_string());
+    let expect = vec![
+        (ident("abc"), 3, 0, 0, 0, 0),
+        (ident("def"), 3, 0, 4, 4, 0),
+        (TokenKind::Newline, 1, 0, 7, 7, 0),
+        (ident("hello"), 5, 1, 0, 8, 8),
+        (ident("world"), 5, 1, 6, 14, 8),
+    ];
+
+    assert_eq!(toks.len(), expect.len());
+    for (i, (kind, length, row, col, offset, line_begin)) in expect.into_iter().enumerate() {
+        let pos = Pos {
+            source_id: toks[i].pos.source_id,
+            row,
+            col,
+            offset,
+            line_begin,
+        };
+        let expected = Token::new(kind, length, pos);
+        assert!(
+            toks[i].eq_with_pos(&expected),
+            "case {}: got {:?}, expected {:?}",
+            i + 1,
+            toks[i],
+            expected
+        );
+    }
 }
 
 #[test]
 fn test_string_basic() {
     scan_and_then(r#""Hello world!""#, |toks| {
         assert_eq!(toks.len(), 1);
-        assert_eq!(
-            toks[0].kind,
-            TokenKind::StringLit("Hello world!".to_string())
+        assert_token(
+            &toks[0],
+            TokenKind::StringLit("Hello world!".to_string()),
+            14,
         );
-        assert_eq!(toks[0].length, 14);
     });
 }
 
@@ -130,12 +233,78 @@ fn test_string_unterminated_with_newline_error() {
     scan_and_error("\"with newline\n123");
 }
 
+#[test]
+fn test_string_escapes_decoded() {
+    scan_and_then(r#""a\nb\tc\r\\\0""#, |toks| {
+        assert_eq!(toks.len(), 1);
+        assert_token(
+            &toks[0],
+            TokenKind::StringLit("a\nb\tc\r\\\0".to_string()),
+            15,
+        );
+    });
+}
+
+#[test]
+fn test_string_escaped_quote_does_not_terminate() {
+    scan_and_then(r#""a\"b" c"#, |toks| {
+        assert_eq!(toks.len(), 2);
+        assert_token(&toks[0], TokenKind::StringLit("a\"b".to_string()), 6);
+        assert_eq!(toks[1].kind, TokenKind::IdentLit("c".to_string()));
+    });
+}
+
+#[test]
+fn test_string_unknown_escape_error() {
+    let err = scan_string(r#""a\qb""#).unwrap_err();
+    assert_eq!(err.len(), 1);
+    assert_eq!(err.get(0).message, "unknown escape sequence '\\q'");
+}
+
+#[test]
+fn test_string_hex_escape() {
+    scan_and_then(r#""\x41\x00b""#, |toks| {
+        assert_eq!(toks.len(), 1);
+        assert_token(&toks[0], TokenKind::StringLit("A\0b".to_string()), 11);
+    });
+}
+
+#[test]
+fn test_string_hex_escape_error() {
+    let err = scan_string(r#""a\x4""#).unwrap_err();
+    assert_eq!(err.get(0).message, "expected two hex digits after '\\x'");
+
+    let err = scan_string(r#""\xff""#).unwrap_err();
+    assert_eq!(err.get(0).message, "hex escape must be at most '\\x7f'");
+}
+
+#[test]
+fn test_string_escaped_backslash_before_end_quote() {
+    scan_and_then(r#""a\\""#, |toks| {
+        assert_eq!(toks[0].kind, TokenKind::StringLit("a\\".to_string()));
+    });
+}
+
+#[test]
+fn test_string_trailing_backslash_unterminated_error() {
+    scan_and_error("\"abc\\");
+    scan_and_error("\"abc\\\n\"");
+}
+
+#[test]
+fn test_byte_string_escape() {
+    scan_and_then(r#"'\n' '\''"#, |toks| {
+        assert_eq!(toks.len(), 2);
+        assert_token(&toks[0], TokenKind::StringLit("\n".to_string()), 4);
+        assert_eq!(toks[1].kind, TokenKind::StringLit("'".to_string()));
+    });
+}
+
 #[test]
 fn test_byte_string_valid() {
     scan_and_then(r#"'A'"#, |toks| {
         assert_eq!(toks.len(), 1);
-        assert_eq!(toks[0].kind, TokenKind::StringLit("A".to_string()));
-        assert_eq!(toks[0].length, 3);
+        assert_token(&toks[0], TokenKind::StringLit("A".to_string()), 3);
     });
 }
 
@@ -165,6 +334,18 @@ fn test_symbols_basic() {
     });
 }
 
+#[test]
+fn test_fat_arrow() {
+    scan_and_then("=> = > ==>", |toks| {
+        assert_eq!(toks.len(), 5);
+        assert_token(&toks[0], TokenKind::FatArrow, 2);
+        assert_eq!(toks[1].kind, TokenKind::Eq);
+        assert_eq!(toks[2].kind, TokenKind::Greater);
+        assert_eq!(toks[3].kind, TokenKind::EqEq);
+        assert_eq!(toks[4].kind, TokenKind::Greater);
+    });
+}
+
 #[test]
 fn test_symbols_compound() {
     scan_and_then("+= /= >= :=", |toks| {
@@ -176,6 +357,39 @@ fn test_symbols_compound() {
     });
 }
 
+#[test]
+fn test_symbols_percent_eq() {
+    scan_and_then("%= % %=%", |toks| {
+        assert_eq!(toks.len(), 4);
+        assert_token(&toks[0], TokenKind::PercentEq, 2);
+        assert_eq!(toks[1].kind, TokenKind::Percent);
+        assert_eq!(toks[2].kind, TokenKind::PercentEq);
+        assert_eq!(toks[3].kind, TokenKind::Percent);
+    });
+}
+
+#[test]
+fn test_symbols_shift() {
+    scan_and_then("a << 2 a >> 2", |toks| {
+        assert_eq!(toks.len(), 6);
+        assert_token(&toks[1], TokenKind::Shl, 2);
+        assert_token(&toks[4], TokenKind::Shr, 2);
+    });
+}
+
+#[test]
+fn test_symbols_shift_does_not_affect_comparison() {
+    scan_and_then("> >= < <= >>=", |toks| {
+        assert_eq!(toks.len(), 6);
+        assert_eq!(toks[0].kind, TokenKind::Greater);
+        assert_eq!(toks[1].kind, TokenKind::GreaterEq);
+        assert_eq!(toks[2].kind, TokenKind::Less);
+        assert_eq!(toks[3].kind, TokenKind::LessEq);
+        assert_eq!(toks[4].kind, TokenKind::Shr);
+        assert_eq!(toks[5].kind, TokenKind::Eq);
+    });
+}
+
 #[test]
 fn test_symbols_adjacent_greedy() {
     scan_and_then("+-=/", |toks| {
@@ -186,6 +400,16 @@ fn test_symbols_adjacent_greedy() {
     });
 }
 
+#[test]
+fn test_symbols_at_before_identifier() {
+    scan_and_then("@inline func foo() {}", |toks| {
+        assert_eq!(toks.len(), 8);
+        assert_token(&toks[0], TokenKind::At, 1);
+        assert_eq!(toks[1].kind, TokenKind::IdentLit("inline".to_string()));
+        assert_eq!(toks[2].kind, TokenKind::Func);
+    });
+}
+
 #[test]
 fn test_line_comment_inline() {
     scan_and_then("hello // comment\nworld", |toks| {
@@ -227,6 +451,16 @@ fn test_block_comment_nested_after_token() {
     scan_and_then("foo /* nested /* comment\n */ */", expect_foo);
 }
 
+#[test]
+fn test_block_comment_multiline_updates_pos() {
+    scan_and_then("/* a\n /* b */\n c */ foo\nbar", |toks| {
+        assert_eq!(toks.len(), 3);
+        assert_eq!((toks[0].pos.row, toks[0].pos.col), (2, 6));
+        assert_eq!(toks[0].pos.line_begin, 14);
+        assert_eq!((toks[2].pos.row, toks[2].pos.col), (3, 0));
+    });
+}
+
 #[test]
 fn test_block_comment_unclosed_error() {
     scan_and_error("/* not closed");
@@ -241,8 +475,7 @@ fn test_block_comment_unclosed_nested_error() {
 fn test_hex_literal_lowercase() {
     scan_and_then("0xff", |toks| {
         assert_eq!(toks.len(), 1);
-        assert_eq!(toks[0].kind, TokenKind::IntLit(255));
-        assert_eq!(toks[0].length, 4);
+        assert_token(&toks[0], TokenKind::IntLit(255), 4);
     });
 }
 
@@ -270,8 +503,7 @@ fn test_hex_literal_zero() {
 #[test]
 fn test_hex_literal_multi_digit() {
     scan_and_then("0x1A2B", |toks| {
-        assert_eq!(toks[0].kind, TokenKind::IntLit(0x1A2B));
-        assert_eq!(toks[0].length, 6);
+        assert_token(&toks[0], TokenKind::IntLit(0x1A2B), 6);
     });
 }
 
@@ -306,3 +538,340 @@ fn test_hex_literal_zero_followed_by_digit_is_int() {
         assert_eq!(toks[0].kind, TokenKind::IntLit(1));
     });
 }
+
+#[test]
+fn test_octal_literal() {
+    scan_and_then("0o755 0O7", |toks| {
+        assert_eq!(toks.len(), 2);
+        assert_token(&toks[0], TokenKind::IntLit(0o755), 5);
+        assert_eq!(toks[1].kind, TokenKind::IntLit(7));
+    });
+}
+
+#[test]
+fn test_binary_literal() {
+    scan_and_then("0b1010 0B1", |toks| {
+        assert_eq!(toks.len(), 2);
+        assert_token(&toks[0], TokenKind::IntLit(0b1010), 6);
+        assert_eq!(toks[1].kind, TokenKind::IntLit(1));
+    });
+}
+
+#[test]
+fn test_prefixed_literal_in_expression() {
+    scan_and_then("a := 0b11 + 0o17", |toks| {
+        assert_eq!(toks.len(), 5);
+        assert_eq!(toks[2].kind, TokenKind::IntLit(3));
+        assert_eq!(toks[4].kind, TokenKind::IntLit(15));
+    });
+}
+
+fn expect_scan_error(src: &str, msg: &str) {
+    let err = scan_string(src).unwrap_err();
+    assert_eq!(err.len(), 1);
+    assert_eq!(err.get(0).message, msg);
+}
+
+#[test]
+fn test_binary_literal_invalid_digit_error() {
+    expect_scan_error("0b123", "invalid digit '2' in binary literal");
+}
+
+#[test]
+fn test_octal_literal_invalid_digit_error() {
+    expect_scan_error("0o78", "invalid digit '8' in octal literal");
+}
+
+#[test]
+fn test_prefixed_literal_no_digits_error() {
+    expect_scan_error("0b", "binary literal has no digits");
+    expect_scan_error("0o ", "octal literal has no digits");
+}
+
+#[test]
+fn test_prefixed_literal_followed_by_letter_error() {
+    expect_scan_error("0b1z", "invalid digit in number literal");
+}
+
+#[test]
+fn test_prefixed_literal_overflow_error() {
+    expect_scan_error("0xFFFFFFFFFFFFFFFFF", "invalid hex literal");
+}
+
+#[test]
+fn test_binary_literal_invalid_digit_points_at_digit() {
+    let map = new_source_map("0b103");
+    let diag = scan(map.sources().last().unwrap(), &Config::test()).unwrap_err();
+    let caret = diag.render(&map).lines().nth(4).unwrap().to_owned();
+    assert_eq!(caret, format!("    |    {}^", " ".repeat(4)));
+}
+
+#[test]
+fn test_newline_suppressed_inside_parens() {
+    scan_and_then("f(a,\n  b\n)\n", |toks| {
+        let kinds = toks.iter().map(|t| t.kind.clone()).collect::<Vec<_>>();
+        assert_eq!(
+            kinds,
+            vec![
+                TokenKind::IdentLit("f".into()),
+                TokenKind::LParen,
+                TokenKind::IdentLit("a".into()),
+                TokenKind::Comma,
+                TokenKind::IdentLit("b".into()),
+                TokenKind::RParen,
+                TokenKind::Newline,
+            ]
+        );
+    });
+}
+
+#[test]
+fn test_newline_suppressed_inside_nested_brackets() {
+    scan_and_then("([\n(\n)\n]\n)\na", |toks| {
+        let newlines = toks.iter().filter(|t| t.kind == TokenKind::Newline).count();
+        assert_eq!(newlines, 1);
+    });
+}
+
+#[test]
+fn test_newline_kept_outside_brackets() {
+    scan_and_then("a\n\nb\n", |toks| {
+        let newlines = toks.iter().filter(|t| t.kind == TokenKind::Newline).count();
+        assert_eq!(newlines, 3);
+    });
+}
+
+fn assert_eof_at(src: &str, row: usize, col: usize) {
+    let eof = eof_token(&new_source(src));
+    assert!(eof.eof);
+    assert_eq!(eof.pos.offset, src.len());
+    assert_eq!((eof.pos.row, eof.pos.col), (row, col));
+    assert_eq!(eof.pos.line_begin, src.len() - col);
+}
+
+#[test]
+fn test_eof_position_no_trailing_whitespace() {
+    assert_eof_at("a := 1", 0, 6);
+
+    // Directly after the last token
+    let toks = must(scan_string("a := 1"));
+    let eof = eof_token(&new_source("a := 1"));
+    assert_eq!(toks.last().unwrap().end().offset, eof.pos().offset);
+}
+
+#[test]
+fn test_eof_position_trailing_whitespace() {
+    assert_eof_at("a := 1  \t ", 0, 10);
+}
+
+#[test]
+fn test_eof_position_trailing_newline() {
+    assert_eof_at("a := 1\n", 1, 0);
+    assert_eof_at("a\n\n  ", 2, 2);
+}
+
+#[test]
+fn test_eof_position_empty() {
+    assert_eof_at("", 0, 0);
+}
+
+#[test]
+fn test_digit_separators() {
+    scan_and_then("1_000_000 0xDE_AD_BE_EF 0b1010_0101 12.345_6", |toks| {
+        assert_eq!(toks.len(), 4);
+        assert_token(&toks[0], TokenKind::IntLit(1_000_000), 9);
+        assert_token(&toks[1], TokenKind::IntLit(0xDEADBEEF), 13);
+        assert_eq!(toks[2].kind, TokenKind::IntLit(0b1010_0101));
+        assert_eq!(toks[3].kind, TokenKind::FloatLit(12.3456));
+    });
+}
+
+#[test]
+fn test_misplaced_digit_separators() {
+    let msg = "digit separator '_' must be between two digits";
+    expect_scan_error("1__2", msg);
+    expect_scan_error("1_", msg);
+    expect_scan_error("1_.5", msg);
+    expect_scan_error("0x_ff", msg);
+    expect_scan_error("0b1_", msg);
+}
+
+#[test]
+fn test_leading_underscore_is_identifier() {
+    scan_and_then("_1", |toks| {
+        assert_eq!(toks[0].kind, TokenKind::IdentLit("_1".to_string()));
+    });
+}
+
+#[test]
+fn test_digit_separator_error_points_at_separator() {
+    let map = new_source_map("12__3");
+    let diag = scan(map.sources().last().unwrap(), &Config::test()).unwrap_err();
+    let caret = diag.render(&map).lines().nth(4).unwrap().to_owned();
+    assert_eq!(caret, format!("    |    {}^", " ".repeat(2)));
+}
+
+#[test]
+fn test_unicode_identifier() {
+    scan_and_then("café := straße_2", |toks| {
+        assert_eq!(toks.len(), 3);
+        assert_token(&toks[0], TokenKind::IdentLit("café".to_string()), 5);
+        assert_eq!(toks[0].end_pos.col, 4);
+        assert_eq!((toks[1].pos.col, toks[1].pos.offset), (5, 6));
+        assert_eq!(toks[2].kind, TokenKind::IdentLit("straße_2".to_string()));
+    });
+}
+
+#[test]
+fn test_unicode_string_literal() {
+    scan_and_then("\"hi 🐟\" x", |toks| {
+        assert_eq!(toks.len(), 2);
+        assert_token(&toks[0], TokenKind::StringLit("hi 🐟".to_string()), 9);
+        assert_eq!(toks[0].end_pos.col, 6);
+        assert_eq!((toks[1].pos.col, toks[1].pos.offset), (7, 10));
+    });
+}
+
+#[test]
+fn test_non_letter_unicode_outside_string_error() {
+    expect_scan_error("a := 🐟", "illegal token");
+}
+
+#[test]
+fn test_unicode_error_points_at_character_column() {
+    let map = new_source_map("\"ü\\q\"");
+    let diag = scan(map.sources().last().unwrap(), &Config::test()).unwrap_err();
+    let caret = diag.render(&map).lines().nth(4).unwrap().to_owned();
+    assert_eq!(caret, format!("    |    {}^^", " ".repeat(2)));
+}
+
+fn scan_comments(src: &str) -> Vec<Token> {
+    let map = new_source_map(src);
+    let source = map.sources().last().unwrap();
+    match scan_with_comments(source, &Config::test()) {
+        Ok(toks) => toks,
+        Err(_) => panic!("expected scan to succeed"),
+    }
+}
+
+#[test]
+fn test_comments_skipped_by_default() {
+    scan_and_then("a // line\n/* block */ b", |toks| {
+        assert_eq!(toks.len(), 3);
+        assert_eq!(toks[1].kind, TokenKind::Newline);
+    });
+}
+
+#[test]
+fn test_keep_line_comment() {
+    let toks = scan_comments("a // line\nb");
+    assert_eq!(toks.len(), 4);
+    assert_token(&toks[1], TokenKind::LineComment("// line".to_string()), 7);
+    assert_eq!((toks[1].pos.row, toks[1].pos.col), (0, 2));
+    assert_eq!((toks[1].end_pos.row, toks[1].end_pos.col), (0, 9));
+    assert_eq!(toks[2].kind, TokenKind::Newline);
+}
+
+#[test]
+fn test_keep_block_comment_single_line() {
+    let toks = scan_comments("a /* b */ c");
+    assert_eq!(toks.len(), 3);
+    assert_eq!(toks[1].kind, TokenKind::BlockComment("/* b */".to_string()));
+    assert_eq!((toks[1].pos.col, toks[1].pos.offset), (2, 2));
+    assert_eq!((toks[1].end_pos.col, toks[1].end_pos.offset), (9, 9));
+    assert_eq!(toks[2].pos.col, 10);
+}
+
+#[test]
+fn test_keep_block_comment_multiline() {
+    let toks = scan_comments("a /* b\n c */ d");
+    assert_eq!(toks.len(), 3);
+    assert_eq!(
+        toks[1].kind,
+        TokenKind::BlockComment("/* b\n c */".to_string())
+    );
+    assert_eq!((toks[1].pos.row, toks[1].pos.col), (0, 2));
+    assert_eq!((toks[1].end_pos.row, toks[1].end_pos.col), (1, 5));
+    assert_eq!(toks[1].end_pos.offset, 12);
+    assert_eq!((toks[2].pos.row, toks[2].pos.col), (1, 6));
+}
+
+#[test]
+fn test_symbols_longest_match() {
+    scan_and_then(":= == != : :: = :", |toks| {
+        assert_eq!(toks.len(), 7);
+        assert_eq!(toks[0].kind, TokenKind::ColonEq);
+        assert_eq!(toks[1].kind, TokenKind::EqEq);
+        assert_eq!(toks[2].kind, TokenKind::NotEq);
+        assert_token(&toks[3], TokenKind::Colon, 1);
+        assert_eq!(toks[4].kind, TokenKind::ColonColon);
+        assert_eq!(toks[5].kind, TokenKind::Eq);
+        assert_eq!(toks[6].kind, TokenKind::Colon);
+    });
+}
+
+#[test]
+fn test_symbols_split_by_space() {
+    scan_and_then(": = ! =", |toks| {
+        assert_eq!(toks.len(), 4);
+        assert_eq!(toks[0].kind, TokenKind::Colon);
+        assert_eq!(toks[1].kind, TokenKind::Eq);
+        assert_eq!(toks[2].kind, TokenKind::Not);
+        assert_eq!(toks[3].kind, TokenKind::Eq);
+    });
+}
+
+#[test]
+fn test_symbol_before_unicode_identifier() {
+    scan_and_then("(é)", |toks| {
+        assert_eq!(toks.len(), 3);
+        assert_eq!(toks[0].kind, TokenKind::LParen);
+        assert_eq!(toks[1].kind, TokenKind::IdentLit("é".to_string()));
+        assert_eq!(toks[2].kind, TokenKind::RParen);
+    });
+}
+
+fn scan_progress(src: &str) -> Vec<(usize, usize)> {
+    let map = new_source_map(src);
+    let source = map.sources().last().unwrap();
+    let mut calls = Vec::new();
+    let _ = scan_with_progress(source, &Config::test(), &mut |consumed, total| {
+        calls.push((consumed, total))
+    });
+    calls
+}
+
+#[test]
+fn test_progress_reported_per_line() {
+    let src = "a := 1\nb := 2\nc := 3";
+    let calls = scan_progress(src);
+
+    // Once after each of the two newlines and once at eof
+    assert_eq!(
+        calls,
+        vec![(7, src.len()), (14, src.len()), (src.len(), src.len())]
+    );
+}
+
+#[test]
+fn test_progress_increases_and_reaches_total() {
+    let src = "func f() {\n    /* multi\n line */\n    x := \"a\\nb\"\n}\n";
+    let calls = scan_progress(src);
+
+    assert!(calls.len() > 1);
+    assert!(calls.windows(2).all(|w| w[0].0 <= w[1].0));
+    assert!(calls.iter().all(|&(_, total)| total == src.len()));
+    assert_eq!(calls.last(), Some(&(src.len(), src.len())));
+}
+
+#[test]
+fn test_progress_reaches_total_on_error() {
+    let src = "a\n$\nb";
+    let calls = scan_progress(src);
+    assert_eq!(calls.last(), Some(&(src.len(), src.len())));
+}
+
+#[test]
+fn test_progress_empty_source() {
+    assert_eq!(scan_progress(""), vec![(0, 0)]);
+}
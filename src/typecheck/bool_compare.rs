@@ -0,0 +1,69 @@
+use crate::{
+    context::Context,
+    error::{Diagnostics, error_span},
+    module::{ModuleId, ModuleKind},
+    types::{BinaryOp, Expr, LiteralKind, Walker, walk_decl},
+};
+
+/// Report a warning for each '==' or '!=' comparison in the module where one
+/// side is a boolean literal, eg. 'x == true', suggesting the simpler form.
+pub fn check_bool_comparisons(ctx: &Context, id: ModuleId) -> Diagnostics {
+    let mut checker = BoolCompareChecker {
+        diag: Diagnostics::new(),
+    };
+
+    if let ModuleKind::Source { files, .. } = &ctx.modules.get(id).kind {
+        for decl in files.iter().flat_map(|file| &file.ast.decls) {
+            walk_decl(&mut checker, decl);
+        }
+    }
+
+    checker.diag
+}
+
+struct BoolCompareChecker {
+    diag: Diagnostics,
+}
+
+impl Walker for BoolCompareChecker {
+    fn expr(&mut self, expr: &Expr) {
+        let Expr::Binary(node) = expr else {
+            return;
+        };
+
+        let is_equal = match node.op {
+            BinaryOp::Equal => true,
+            BinaryOp::NotEqual => false,
+            _ => return,
+        };
+
+        // Only warn when exactly one side is a boolean literal. Comparing two
+        // literals has no simpler form with a name in it, so it is skipped.
+        let (operand, value) = match (bool_literal(&node.lhs), bool_literal(&node.rhs)) {
+            (Some(b), None) => (&node.rhs, b),
+            (None, Some(b)) => (&node.lhs, b),
+            _ => return,
+        };
+
+        let keep = value == is_equal;
+        let hint = match (operand.try_identifier(), keep) {
+            (Some(name), true) => format!("simplify to '{}'", name),
+            (Some(name), false) => format!("simplify to '!{}'", name),
+            (None, true) => "remove the comparison".to_owned(),
+            (None, false) => "negate the operand with '!' instead".to_owned(),
+        };
+
+        let msg = format!("redundant comparison with '{}', {}", value, hint);
+        self.diag.add(error_span(&msg, expr).as_warning());
+    }
+}
+
+fn bool_literal(expr: &Expr) -> Option<bool> {
+    match expr {
+        Expr::Literal(lit) => match lit.kind {
+            LiteralKind::Bool(b) => Some(b),
+            _ => None,
+        },
+        _ => None,
+    }
+}
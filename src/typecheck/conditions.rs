@@ -0,0 +1,126 @@
+use crate::{
+    context::Context,
+    error::{Diagnostics, error_span},
+    module::{ModuleId, ModuleKind},
+    types::{BinaryOp, ElseBlock, Expr, LiteralKind, Stmt, UnaryOp, Walker, walk_decl},
+};
+
+/// Report a warning for each if, while, for, and ternary condition in the
+/// module which is always true or always false. Loops with an always true condition are
+/// assumed to be intentional infinite loops and are not reported.
+pub fn check_const_conditions(ctx: &Context, id: ModuleId) -> Diagnostics {
+    let mut checker = ConditionChecker {
+        diag: Diagnostics::new(),
+    };
+
+    if let ModuleKind::Source { files, .. } = &ctx.modules.get(id).kind {
+        for decl in files.iter().flat_map(|file| &file.ast.decls) {
+            walk_decl(&mut checker, decl);
+        }
+    }
+
+    checker.diag
+}
+
+struct ConditionChecker {
+    diag: Diagnostics,
+}
+
+impl ConditionChecker {
+    fn check(&mut self, cond: &Expr, is_loop: bool) {
+        match const_bool(cond) {
+            Some(true) if !is_loop => self.warn(cond, "condition is always true"),
+            Some(false) => self.warn(cond, "condition is always false"),
+            _ => {}
+        }
+    }
+
+    fn warn(&mut self, cond: &Expr, msg: &str) {
+        self.diag.add(error_span(msg, cond).as_warning());
+    }
+}
+
+impl Walker for ConditionChecker {
+    fn stmt(&mut self, stmt: &Stmt) {
+        match stmt {
+            Stmt::If(node) => {
+                self.check(&node.expr, false);
+
+                let mut elseif = &*node.elseif;
+                while let ElseBlock::ElseIf(node) = elseif {
+                    self.check(&node.expr, false);
+                    elseif = &node.elseif;
+                }
+            }
+            Stmt::While(node) => self.check(&node.expr, true),
+            Stmt::For(node) => self.check(&node.condition, true),
+            _ => {}
+        }
+    }
+
+    fn expr(&mut self, expr: &Expr) {
+        if let Expr::Ternary(node) = expr {
+            self.check(&node.cond, false);
+        }
+    }
+}
+
+/// Fold an expression to a constant boolean if it only consists of boolean
+/// and integer literals.
+fn const_bool(expr: &Expr) -> Option<bool> {
+    match expr {
+        Expr::Literal(lit) => match lit.kind {
+            LiteralKind::Bool(b) => Some(b),
+            _ => None,
+        },
+        Expr::Unary(node) if matches!(node.op, UnaryOp::LogicNot) => {
+            const_bool(&node.rhs).map(|b| !b)
+        }
+        Expr::Binary(node) => match node.op {
+            BinaryOp::LogicAnd => match (const_bool(&node.lhs), const_bool(&node.rhs)) {
+                (Some(false), _) | (_, Some(false)) => Some(false),
+                (Some(true), Some(true)) => Some(true),
+                _ => None,
+            },
+            BinaryOp::LogicOr => match (const_bool(&node.lhs), const_bool(&node.rhs)) {
+                (Some(true), _) | (_, Some(true)) => Some(true),
+                (Some(false), Some(false)) => Some(false),
+                _ => None,
+            },
+            _ => {
+                if let (Some(a), Some(b)) = (const_bool(&node.lhs), const_bool(&node.rhs)) {
+                    return match node.op {
+                        BinaryOp::Equal => Some(a == b),
+                        BinaryOp::NotEqual => Some(a != b),
+                        _ => None,
+                    };
+                }
+
+                let (a, b) = (const_int(&node.lhs)?, const_int(&node.rhs)?);
+                match node.op {
+                    BinaryOp::Equal => Some(a == b),
+                    BinaryOp::NotEqual => Some(a != b),
+                    BinaryOp::Greater => Some(a > b),
+                    BinaryOp::GreaterEq => Some(a >= b),
+                    BinaryOp::Less => Some(a < b),
+                    BinaryOp::LessEq => Some(a <= b),
+                    _ => None,
+                }
+            }
+        },
+        _ => None,
+    }
+}
+
+fn const_int(expr: &Expr) -> Option<i64> {
+    match expr {
+        Expr::Literal(lit) => match lit.kind {
+            LiteralKind::Int(n) => Some(n),
+            _ => None,
+        },
+        Expr::Unary(node) if matches!(node.op, UnaryOp::Minus) => {
+            const_int(&node.rhs).map(|n| n.wrapping_neg())
+        }
+        _ => None,
+    }
+}
@@ -0,0 +1,93 @@
+use crate::{
+    ast::{Expr, Token, TokenKind},
+    error::{Report, error_span},
+    types::LiteralKind,
+};
+
+/// Resolves a constant referred to by name to its value.
+pub(crate) type ConstResolver<'a> = dyn FnMut(&Token) -> Result<LiteralKind, Report> + 'a;
+
+/// Evaluate the value of a top-level constant. Strings, booleans, and floats
+/// must be literals or other constants, integers may be any constant integer
+/// expression. Names are looked up with the resolver.
+pub(crate) fn eval_const_value(
+    expr: &Expr,
+    resolve: &mut ConstResolver,
+) -> Result<LiteralKind, Report> {
+    match expr {
+        Expr::Literal(tok) => match &tok.kind {
+            TokenKind::IdentLit(_) => return resolve(tok),
+            TokenKind::FloatLit(n) => return Ok(LiteralKind::Float(*n)),
+            TokenKind::StringLit(s) => return Ok(LiteralKind::String(s.clone())),
+            TokenKind::True => return Ok(LiteralKind::Bool(true)),
+            TokenKind::False => return Ok(LiteralKind::Bool(false)),
+            _ => {}
+        },
+        Expr::Unary(node) => {
+            if let Expr::Literal(tok) = &*node.rhs
+                && let TokenKind::FloatLit(n) = tok.kind
+                && node.op.kind == TokenKind::Minus
+            {
+                return Ok(LiteralKind::Float(-n));
+            }
+        }
+        _ => {}
+    }
+
+    eval_int(expr, resolve).map(LiteralKind::Int)
+}
+
+/// Evaluate an expression to a constant integer at compile time. Only integer
+/// literals, grouping, and arithmetic operators are allowed.
+pub(crate) fn eval_const_int(expr: &Expr) -> Result<i64, Report> {
+    eval_int(expr, &mut |_| Err(not_constant(expr)))
+}
+
+/// Evaluate a constant integer expression, looking up names with the resolver.
+fn eval_int(expr: &Expr, resolve: &mut ConstResolver) -> Result<i64, Report> {
+    let overflow = || error_span("constant expression overflows", expr);
+
+    match expr {
+        Expr::Literal(tok) => match tok.kind {
+            TokenKind::IntLit(n) => Ok(n),
+            TokenKind::IdentLit(_) => match resolve(tok)? {
+                LiteralKind::Int(n) => Ok(n),
+                _ => Err(not_constant(expr)),
+            },
+            _ => Err(not_constant(expr)),
+        },
+        Expr::Group(node) => eval_int(&node.inner, resolve),
+        Expr::Unary(node) => {
+            let rhs = eval_int(&node.rhs, resolve)?;
+            match node.op.kind {
+                TokenKind::Minus => rhs.checked_neg().ok_or_else(overflow),
+                TokenKind::Plus => Ok(rhs),
+                _ => Err(not_constant(expr)),
+            }
+        }
+        Expr::Binary(node) => {
+            let lhs = eval_int(&node.lhs, resolve)?;
+            let rhs = eval_int(&node.rhs, resolve)?;
+
+            if rhs == 0 && matches!(node.op.kind, TokenKind::Slash | TokenKind::Percent) {
+                return Err(error_span("division by zero in constant expression", expr));
+            }
+
+            let result = match node.op.kind {
+                TokenKind::Plus => lhs.checked_add(rhs),
+                TokenKind::Minus => lhs.checked_sub(rhs),
+                TokenKind::Star => lhs.checked_mul(rhs),
+                TokenKind::Slash => lhs.checked_div(rhs),
+                TokenKind::Percent => lhs.checked_rem(rhs),
+                _ => return Err(not_constant(expr)),
+            };
+
+            result.ok_or_else(overflow)
+        }
+        _ => Err(not_constant(expr)),
+    }
+}
+
+fn not_constant(expr: &Expr) -> Report {
+    error_span("expected constant integer expression", expr)
+}
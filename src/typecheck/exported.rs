@@ -0,0 +1,89 @@
+use std::collections::HashMap;
+
+use crate::{
+    context::Context,
+    error::{Diagnostics, error_span},
+    module::{ModuleId, ModuleKind, ModuleSymbolKind, SymbolKind, SymbolOrigin},
+    types::{Decl, TypeId, TypeKind},
+};
+
+/// Report a warning for each exported function whose parameters or return
+/// type use a type which is private to the module, as other modules cannot
+/// name that type.
+pub fn check_exported_signatures(ctx: &Context, id: ModuleId) -> Diagnostics {
+    let module = ctx.modules.get(id);
+    let mut diag = Diagnostics::new();
+
+    let ModuleKind::Source { files, .. } = &module.kind else {
+        return diag;
+    };
+
+    // Private named types declared in this module, by type id. Imported
+    // symbols are not exported either, but are public in their own module.
+    // Aliases are not named types, so they are never reported.
+    let private = module
+        .symbols
+        .symbols()
+        .iter()
+        .filter(|(_, sym)| !sym.exported && matches!(sym.kind, ModuleSymbolKind::Module))
+        .map(|(name, sym)| (name, ctx.symbols.get(sym.id)))
+        .filter(|(_, sym)| {
+            matches!(sym.kind, SymbolKind::Type)
+                && matches!(sym.origin, SymbolOrigin::Module { .. })
+        })
+        .map(|(name, sym)| (sym.ty, name.as_str()))
+        .collect::<HashMap<TypeId, &str>>();
+
+    for decl in files.iter().flat_map(|file| &file.ast.decls) {
+        let Decl::Func(func) = decl else {
+            continue;
+        };
+
+        if !func.public {
+            continue;
+        }
+
+        let mut named = Vec::new();
+        named_types(ctx, func.ty, &mut named);
+
+        for ty in named {
+            if let Some(name) = private.get(&ty) {
+                let msg = format!(
+                    "exported function '{}' uses private type '{}'",
+                    func.name, name
+                );
+                diag.add(error_span(&msg, &func.meta).as_warning());
+            }
+        }
+    }
+
+    diag
+}
+
+/// Collect the named types, structs and unique types, appearing in a type.
+/// Fields of structs are not included as they are part of the struct.
+fn named_types(ctx: &Context, ty: TypeId, out: &mut Vec<TypeId>) {
+    match &ctx.types.lookup(ty).kind {
+        TypeKind::Struct(_) | TypeKind::Unique(..) => {
+            if !out.contains(&ty) {
+                out.push(ty);
+            }
+        }
+        TypeKind::Array(inner, _) | TypeKind::Slice(inner) | TypeKind::Pointer(inner) => {
+            named_types(ctx, *inner, out)
+        }
+        TypeKind::Alias(target) => named_types(ctx, *target, out),
+        TypeKind::Tuple(types) => {
+            for ty in types {
+                named_types(ctx, *ty, out);
+            }
+        }
+        TypeKind::Function(f) => {
+            for param in &f.params {
+                named_types(ctx, *param, out);
+            }
+            named_types(ctx, f.ret, out);
+        }
+        TypeKind::Primitive(_) => {}
+    }
+}
@@ -1,3 +1,5 @@
+use std::collections::HashMap;
+
 use tracing::info;
 
 use crate::{
@@ -5,14 +7,17 @@ use crate::{
     common::{Pos, Span, VarTable},
     context::Context,
     error::{Diagnostics, Report, Res, error_span},
-    module::{NamespaceList, SymbolKind, SymbolList},
-    typecheck::helper::CheckerHelpers,
+    module::{NamespaceList, SymbolKind, SymbolList, SymbolOrigin},
+    typecheck::{consteval::eval_const_int, helper::CheckerHelpers},
     types::{
         self, BinaryOp, CastKind, FunctionType, LiteralKind, NO_TYPE, NodeMeta, PrimitiveType,
         Type, TypeId, TypeKind, TypedNode, UnaryOp, ast_node_to_meta,
     },
 };
 
+/// Maximum number of nested blocks in a function body, including the body.
+pub(crate) const MAX_BLOCK_DEPTH: usize = 32;
+
 /// A Binding is either a declared variable or function parameter. Bindings
 /// shadow global symbols like functions and types.
 struct Binding {
@@ -28,6 +33,8 @@ pub(crate) struct FileChecker<'a> {
     ctx: &'a mut Context,
     /// Module-level symbols (read-only during file checking).
     symbols: &'a SymbolList,
+    /// Values of constants in this module, evaluated in the global pass.
+    consts: &'a HashMap<String, LiteralKind>,
     /// Per-file namespaces from import resolution.
     nsl: NamespaceList,
     /// Locally declared variables.
@@ -36,6 +43,8 @@ pub(crate) struct FileChecker<'a> {
     rtype: TypeId,
     /// Whether the current function has returned.
     has_returned: bool,
+    /// Currently checking a function body? Used for return checks.
+    in_func: bool,
     /// Whether we are in the main module.
     is_main: bool,
     /// Currently checking a loop body? Used for break/continue checks.
@@ -47,6 +56,10 @@ impl<'a> CheckerHelpers<'a> for FileChecker<'a> {
         self.ctx
     }
 
+    fn ctx_mut(&mut self) -> &mut Context {
+        self.ctx
+    }
+
     fn symbols(&self) -> &SymbolList {
         self.symbols
     }
@@ -60,16 +73,19 @@ impl<'a> FileChecker<'a> {
     pub(crate) fn new(
         ctx: &'a mut Context,
         symbols: &'a SymbolList,
+        consts: &'a HashMap<String, LiteralKind>,
         nsl: NamespaceList,
         is_main: bool,
     ) -> Self {
         Self {
             ctx,
             symbols,
+            consts,
             nsl,
             vars: VarTable::new(),
             rtype: NO_TYPE,
             has_returned: false,
+            in_func: false,
             is_main,
             in_loop: false,
         }
@@ -104,17 +120,19 @@ impl<'a> FileChecker<'a> {
             match d {
                 ast::Decl::Func(node) => decls.push(self.emit_func(*node)),
                 ast::Decl::Extern(node) => decls.push(self.emit_extern(*node)),
-                ast::Decl::Type(..) => {} // Declared in global pass
+                ast::Decl::Const(node) => decls.push(self.emit_const(*node)),
+                ast::Decl::Type(..) | ast::Decl::Struct(..) => {} // Declared in global pass
             };
         }
         decls
     }
 
-    fn emit_stmt(&mut self, stmt: ast::Stmt) -> Result<types::Stmt, Report> {
+    pub(crate) fn emit_stmt(&mut self, stmt: ast::Stmt) -> Result<types::Stmt, Report> {
         match stmt {
             ast::Stmt::ExprStmt(node) => Ok(types::Stmt::ExprStmt(self.emit_expr(node)?)),
             ast::Stmt::Return(node) => self.emit_return(node),
             ast::Stmt::VarDecl(node) => self.emit_var_decl(node),
+            ast::Stmt::Var(node) => self.emit_var(node),
             ast::Stmt::VarAssign(node) => self.emit_var_assign(node),
             ast::Stmt::While(node) => self.emit_while(node),
             ast::Stmt::For(node) => self.emit_for(node),
@@ -144,6 +162,11 @@ impl<'a> FileChecker<'a> {
             ast::Expr::Group(node) => self.emit_expr(*node.inner),
             ast::Expr::Call(node) => self.emit_call(node),
             ast::Expr::Member(node) => self.emit_member(node),
+            ast::Expr::Index(node) => self.emit_index(node),
+            ast::Expr::StructLit(node) => self.emit_struct_lit(node),
+            ast::Expr::Tuple(node) => self.emit_tuple(node),
+            ast::Expr::Ternary(node) => self.emit_ternary(node),
+            ast::Expr::Block(node) => self.emit_block_expr(node),
             ast::Expr::Binary(node) => self.emit_binary(node),
             ast::Expr::Unary(node) => self.emit_unary(node),
             ast::Expr::Cast(node) => self.emit_cast(node),
@@ -165,25 +188,19 @@ impl<'a> FileChecker<'a> {
             self.check_main_function(f, &node)?;
         }
 
-        // Set up function body
-        self.vars.push_scope();
-        self.rtype = f.ret;
-        self.has_returned = false;
-
-        // Declare params in function body
-        for (i, ty) in f.params.iter().enumerate() {
-            let name = &node.params[i].name;
-            self.bind(name, *ty, false)?;
-        }
-
-        let body = self.emit_block(node.body)?;
+        // The body shares the scope of the parameters, so a parameter cannot
+        // be declared again at the top level of the body
+        self.enter_function(f, &node.params)?;
+        let body = self.emit_stmts(node.body.stmts)?;
         self.vars.pop_scope();
+        self.in_func = false;
 
-        // There was no return when there should have been
+        // There was no return when there should have been. Reported at the
+        // closing brace as that is where the function falls through.
         if !self.has_returned && f.ret != self.ctx.types.void() {
             return Err(error_span(
                 &format!("missing return in function '{}'", node.name.kind),
-                &node.name,
+                &node.body.rbrace,
             ));
         }
 
@@ -192,11 +209,51 @@ impl<'a> FileChecker<'a> {
             name: node.name.to_string(),
             public: node.public,
             ty: func_type.id,
-            params: node.params.iter().map(|p| p.name.to_string()).collect(),
+            params: node
+                .params
+                .iter()
+                .map(|p| types::ParamNode {
+                    name: p.name.to_string(),
+                    pos: p.name.pos.clone(),
+                    end: p.name.end_pos.clone(),
+                })
+                .collect(),
             body,
         }))
     }
 
+    /// Set up the scope of a function body and declare its parameters.
+    pub(crate) fn enter_function(
+        &mut self,
+        f: &FunctionType,
+        params: &[ast::Field],
+    ) -> Result<(), Report> {
+        self.vars.push_scope();
+        self.rtype = f.ret;
+        self.has_returned = false;
+        self.in_func = true;
+
+        for (param, ty) in params.iter().zip(&f.params) {
+            self.bind(&param.name, *ty, false)?;
+        }
+
+        Ok(())
+    }
+
+    /// Get the declaration position of a variable or module symbol. The flag
+    /// is true if the name is declared in the current scope. Symbols not
+    /// declared in source, like externs, have no position.
+    pub(crate) fn resolve(&self, name: &str) -> Option<(&Pos, bool)> {
+        if let Some((var, current)) = self.vars.resolve(name) {
+            return Some((&var.pos, current));
+        }
+
+        match &self.get_symbol(name).ok()?.origin {
+            SymbolOrigin::Module { pos, .. } => Some((pos, false)),
+            _ => None,
+        }
+    }
+
     fn check_main_function(&self, f: &FunctionType, node: &ast::FuncNode) -> Result<(), Report> {
         // Must be main module
         if !self.is_main {
@@ -256,27 +313,112 @@ impl<'a> FileChecker<'a> {
         }))
     }
 
+    fn emit_const(&mut self, node: ast::ConstDeclNode) -> Result<types::Decl, Report> {
+        let name = node.name.to_string();
+        let ty = self
+            .get_symbol(&name)
+            .expect("should have been declared in global pass")
+            .ty;
+
+        let kind = self
+            .consts
+            .get(&name)
+            .expect("should have been evaluated in global pass")
+            .clone();
+
+        Ok(types::Decl::Const(types::ConstNode {
+            ty,
+            meta: ast_node_to_meta(&node),
+            name,
+            public: node.public,
+            value: types::LiteralNode {
+                ty,
+                meta: ast_node_to_meta(&node.expr),
+                kind,
+            },
+        }))
+    }
+
     fn emit_block(&mut self, node: ast::BlockNode) -> Result<types::BlockNode, Report> {
-        self.vars.push_scope();
+        self.push_block_scope(&node.lbrace)?;
+        let block = self.emit_stmts(node.stmts)?;
+        self.vars.pop_scope();
+        Ok(block)
+    }
+
+    /// Emit a list of statements in the current scope.
+    fn emit_stmts(&mut self, stmts: Vec<ast::Stmt>) -> Result<types::BlockNode, Report> {
+        let stmts = stmts
+            .into_iter()
+            .map(|s| self.emit_stmt(s))
+            .collect::<Result<Vec<types::Stmt>, Report>>()?;
+
+        Ok(types::BlockNode { stmts })
+    }
+
+    fn emit_block_expr(&mut self, mut node: ast::BlockNode) -> Result<types::Expr, Report> {
+        let meta = ast_node_to_meta(&node);
+
+        let last = match node.stmts.pop() {
+            Some(ast::Stmt::ExprStmt(expr)) => expr,
+            Some(stmt) => {
+                return Err(error_span(
+                    "block expression must end with an expression",
+                    &stmt,
+                ));
+            }
+            None => {
+                return Err(error_span(
+                    "block expression must end with an expression",
+                    &node.lbrace,
+                ));
+            }
+        };
+
+        // The final expression is in the same scope as the statements
+        self.push_block_scope(&node.lbrace)?;
         let stmts = node
             .stmts
             .into_iter()
             .map(|s| self.emit_stmt(s))
             .collect::<Result<Vec<types::Stmt>, Report>>()?;
-
+        let expr = self.emit_expr(last)?;
         self.vars.pop_scope();
-        Ok(types::BlockNode { stmts })
+
+        Ok(types::Expr::Block(types::BlockExprNode {
+            ty: expr.type_id(),
+            meta,
+            block: types::BlockNode { stmts },
+            expr: Box::new(expr),
+        }))
+    }
+
+    /// Push a new variable scope for a block starting at lbrace.
+    fn push_block_scope(&mut self, lbrace: &Token) -> Result<(), Report> {
+        self.vars.push_scope();
+
+        // The function body shares its scope with the params
+        if self.vars.depth() > MAX_BLOCK_DEPTH {
+            return Err(error_span(
+                &format!("blocks nested too deeply, max depth is {MAX_BLOCK_DEPTH}"),
+                lbrace,
+            ));
+        }
+        Ok(())
     }
 
     fn emit_for(&mut self, node: ast::ForNode) -> Result<types::Stmt, Report> {
         let meta = ast_node_to_meta(&node);
 
+        // The initializer is scoped to the loop
+        self.vars.push_scope();
         let initializer = Box::new(self.emit_stmt(*node.initializer)?);
         let condition = Box::new(self.emit_expr(*node.condition)?);
         self.assert_expr_is_type(PrimitiveType::Bool, &condition)?;
 
         let increment = Box::new(self.emit_stmt(*node.increment)?);
         let block = self.emit_loop_block(node.block)?;
+        self.vars.pop_scope();
 
         Ok(types::Stmt::For(types::ForNode {
             meta,
@@ -317,6 +459,8 @@ impl<'a> FileChecker<'a> {
             ));
         }
 
+        let zero_divisor = node.op.kind == TokenKind::SlashEq && is_const_zero(&node.rval);
+
         let lval = self.emit_expr(node.lval)?;
         let rval = self.emit_expr(node.rval)?;
 
@@ -349,6 +493,10 @@ impl<'a> FileChecker<'a> {
             ));
         }
 
+        if zero_divisor {
+            return Err(error_span("division by zero", &node.op));
+        }
+
         let op = match node.op.kind {
             TokenKind::PlusEq => types::AssignOp::Plus,
             TokenKind::MinusEq => types::AssignOp::Minus,
@@ -369,6 +517,17 @@ impl<'a> FileChecker<'a> {
     fn emit_if(&mut self, node: ast::IfNode) -> Result<types::IfNode, Report> {
         let meta = ast_node_to_meta(&node);
 
+        // Variables declared in the init statement are only visible within
+        // the if statement, including any else-if and else blocks.
+        let has_init = node.init.is_some();
+        let init = match node.init {
+            Some(stmt) => {
+                self.vars.push_scope();
+                Some(Box::new(self.emit_stmt(*stmt)?))
+            }
+            None => None,
+        };
+
         let expr = self.emit_expr(node.expr)?;
         self.assert_expr_is_type(PrimitiveType::Bool, &expr)?;
 
@@ -398,9 +557,13 @@ impl<'a> FileChecker<'a> {
         // If this if-block and all subsequent else-if and else blocks return,
         // then we can mark the function as having returned.
         self.has_returned = this_returned && exhaustive_return;
+        if has_init {
+            self.vars.pop_scope();
+        }
 
         Ok(types::IfNode {
             meta,
+            init,
             expr,
             block,
             elseif,
@@ -451,32 +614,115 @@ impl<'a> FileChecker<'a> {
             ));
         }
 
-        if let Ok(sym) = self.get_symbol(name.as_str()) {
-            match sym.kind {
-                SymbolKind::Function { .. } => {} // shadowing a function is ok
-                SymbolKind::Type => {
-                    return Err(error_span("shadowing a type is not allowed", &node.name));
-                }
+        // Use the explicit type if given, the value must match it
+        let ty = match &node.ty {
+            Some(ty) => {
+                let declared = self.eval_type(ty)?;
+                self.check_declared_type(declared, &typed_expr)?;
+                declared
             }
+            None => typed_expr.type_id(),
+        };
+
+        let ty = self.bind_var(&node.name, ty, node.constant)?;
+        Ok(types::Stmt::VarDecl(types::VarDeclNode {
+            meta,
+            ty,
+            name,
+            value: Some(typed_expr),
+        }))
+    }
+
+    fn emit_var(&mut self, node: ast::VarNode) -> Result<types::Stmt, Report> {
+        let meta = ast_node_to_meta(&node);
+        let name = node.name.to_string();
+
+        let value = match node.expr {
+            Some(expr) => Some(self.emit_expr(expr)?),
+            None => None,
+        };
+
+        if let Some(value) = &value
+            && value.type_id() == self.ctx.types.void()
+        {
+            return Err(error_span("cannot assign void type to variable", value));
         }
 
-        if self.nsl.get(&name).is_some() {
+        let ty = match (&node.ty, &value) {
+            (Some(ty), _) => {
+                let declared = self.eval_type(ty)?;
+                if let Some(value) = &value {
+                    self.check_declared_type(declared, value)?;
+                }
+                declared
+            }
+            (None, Some(value)) => value.type_id(),
+            (None, None) => {
+                return Err(error_span(
+                    "variable declared with var must have a type or a value",
+                    &node.name,
+                ));
+            }
+        };
+
+        // Variables without a value are zero initialized
+        if value.is_none() && self.ctx.types.zero_value(ty).is_none() {
             return Err(error_span(
-                "shadowing a namespace is not allowed",
+                &format!(
+                    "cannot declare variable of type '{}' without a value",
+                    self.ctx.types.type_to_string(ty)
+                ),
                 &node.name,
             ));
         }
 
-        let ty = self.bind(&node.name, typed_expr.type_id(), node.constant)?;
+        let ty = self.bind_var(&node.name, ty, false)?;
         Ok(types::Stmt::VarDecl(types::VarDeclNode {
             meta,
             ty,
             name,
-            value: typed_expr,
+            value,
         }))
     }
 
+    /// Check that the value given in a declaration matches the declared type.
+    fn check_declared_type(&self, declared: TypeId, value: &types::Expr) -> Result<(), Report> {
+        if !self.ctx.types.equivalent(declared, value.type_id()) {
+            return Err(self.error_expected_got(
+                "mismatched types in declaration",
+                declared,
+                value.type_id(),
+                value,
+            ));
+        }
+        Ok(())
+    }
+
+    /// Bind a newly declared local variable, checking that it does not shadow
+    /// a type or namespace.
+    fn bind_var(&mut self, name: &Token, ty: TypeId, constant: bool) -> Result<TypeId, Report> {
+        if let Ok(sym) = self.get_symbol(&name.to_string()) {
+            match sym.kind {
+                // shadowing a function or global constant is ok
+                SymbolKind::Function { .. } | SymbolKind::Constant => {}
+                SymbolKind::Type => {
+                    return Err(error_span("shadowing a type is not allowed", name));
+                }
+            }
+        }
+
+        if self.nsl.get(&name.to_string()).is_some() {
+            return Err(error_span("shadowing a namespace is not allowed", name));
+        }
+
+        self.bind(name, ty, constant)
+    }
+
     fn emit_return(&mut self, node: ast::ReturnNode) -> Result<types::Stmt, Report> {
+        if !self.in_func {
+            return Err(error_span("return outside function", &node));
+        }
+
         self.has_returned = true;
         let meta = ast_node_to_meta(&node);
 
@@ -485,6 +731,17 @@ impl<'a> FileChecker<'a> {
         if let Some(expr) = node.expr {
             let typed_expr = self.emit_expr(expr)?;
 
+            // Report a wrong number of values before comparing the types
+            let expected = self.num_values(self.rtype);
+            let got = self.num_values(typed_expr.type_id());
+            if (expected > 1 || got > 1) && expected != got {
+                let values = if expected == 1 { "value" } else { "values" };
+                return Err(error_span(
+                    &format!("expected {} return {}, got {}", expected, values, got),
+                    &typed_expr,
+                ));
+            }
+
             return if typed_expr.type_id() != self.rtype {
                 Err(self.error_expected_got(
                     "incorrect return type",
@@ -514,6 +771,36 @@ impl<'a> FileChecker<'a> {
         }
     }
 
+    /// Number of values of the given type. Tuples hold several values, void
+    /// holds none.
+    fn num_values(&self, id: TypeId) -> usize {
+        match &self.ctx.types.lookup(id).kind {
+            TypeKind::Tuple(types) => types.len(),
+            _ if id == self.ctx.types.void() => 0,
+            _ => 1,
+        }
+    }
+
+    fn emit_tuple(&mut self, node: ast::TupleExpr) -> Result<types::Expr, Report> {
+        let meta = ast_node_to_meta(&node);
+
+        let mut exprs = Vec::new();
+        for expr in node.exprs {
+            let typed_expr = self.emit_expr(expr)?;
+            if self.num_values(typed_expr.type_id()) != 1 {
+                return Err(error_span(
+                    "expected a single value in list of values",
+                    &typed_expr,
+                ));
+            }
+            exprs.push(typed_expr);
+        }
+
+        let types = exprs.iter().map(|e| e.type_id()).collect();
+        let ty = self.ctx.types.get_or_intern(TypeKind::Tuple(types));
+        Ok(types::Expr::Tuple(types::TupleNode { ty, meta, exprs }))
+    }
+
     fn emit_cast(&mut self, node: ast::CastExpr) -> Result<types::Expr, Report> {
         let meta = ast_node_to_meta(&node);
         let expr = self.emit_expr(*node.expr)?;
@@ -592,6 +879,12 @@ impl<'a> FileChecker<'a> {
                 };
                 self.ctx.types.lookup(ty_id)
             }
+            TokenKind::Null => {
+                return Err(error_span(
+                    "null can only be compared against nilable types",
+                    &tok,
+                ));
+            }
             _ => todo!(),
         };
 
@@ -610,6 +903,20 @@ impl<'a> FileChecker<'a> {
         let meta = ast_node_to_meta(&node);
         let rhs = self.emit_expr(*node.rhs)?;
 
+        // Unary plus is a no-op, so the operand is returned as is
+        if node.op.kind == TokenKind::Plus {
+            if !self.ctx.types.is_number(rhs.type_id()) {
+                return Err(error_span(
+                    &format!(
+                        "'+' operator can only be used on number types, got '{}'",
+                        self.type_to_string(&rhs)
+                    ),
+                    &rhs,
+                ));
+            }
+            return Ok(rhs);
+        }
+
         let op = match node.op.kind {
             TokenKind::Not => UnaryOp::LogicNot,
             TokenKind::Minus => UnaryOp::Minus,
@@ -658,6 +965,14 @@ impl<'a> FileChecker<'a> {
     fn emit_binary(&mut self, node: ast::BinaryExpr) -> Result<types::Expr, Report> {
         let meta = ast_node_to_meta(&node);
 
+        if is_null_literal(&node.lhs) || is_null_literal(&node.rhs) {
+            return self.emit_null_comparison(node);
+        }
+
+        // Only constant divisors are known here, others are left to runtime
+        let zero_divisor = matches!(node.op.kind, TokenKind::Slash | TokenKind::Percent)
+            && is_const_zero(&node.rhs);
+
         let lhs = self.emit_expr(*node.lhs)?;
         let rhs = self.emit_expr(*node.rhs)?;
 
@@ -672,10 +987,14 @@ impl<'a> FileChecker<'a> {
             ));
         }
 
-        let op: BinaryOp = node.op.kind.into();
+        let op: BinaryOp = node.op.kind.clone().into();
 
         self.check_binary_op_type(&op, lhs.type_id(), &meta)?;
 
+        if zero_divisor {
+            return Err(error_span("division by zero", &node.op));
+        }
+
         let ty = match op {
             BinaryOp::Plus | BinaryOp::Minus | BinaryOp::Mult | BinaryOp::Divide => lhs.type_id(),
             BinaryOp::Modulo => self.ctx.types.primitive(PrimitiveType::U32),
@@ -698,13 +1017,85 @@ impl<'a> FileChecker<'a> {
         }))
     }
 
+    /// Check a comparison where one side is null. The other side must be of a
+    /// nilable type, which the null literal then takes on.
+    fn emit_null_comparison(&mut self, node: ast::BinaryExpr) -> Result<types::Expr, Report> {
+        let meta = ast_node_to_meta(&node);
+        let op: BinaryOp = node.op.kind.into();
+
+        if !matches!(op, BinaryOp::Equal | BinaryOp::NotEqual) {
+            return Err(error_span(
+                "null can only be compared using '==' or '!='",
+                &meta,
+            ));
+        }
+
+        let null_on_left = is_null_literal(&node.lhs);
+        let (null, other) = if null_on_left {
+            (*node.lhs, *node.rhs)
+        } else {
+            (*node.rhs, *node.lhs)
+        };
+
+        let ast::Expr::Literal(tok) = null else {
+            unreachable!("checked to be null literal");
+        };
+
+        let value = self.emit_expr(other)?;
+        if !self.ctx.types.is_nilable(value.type_id()) {
+            return Err(error_span(
+                &format!(
+                    "cannot compare type '{}' with null",
+                    self.type_to_string(&value)
+                ),
+                &meta,
+            )
+            .with_info("only pointer types can be null"));
+        }
+
+        let null = types::Expr::Literal(types::LiteralNode {
+            meta: NodeMeta {
+                id: tok.id,
+                pos: tok.pos,
+                end: tok.end_pos,
+            },
+            ty: value.type_id(),
+            kind: LiteralKind::Null,
+        });
+
+        let (lhs, rhs) = if null_on_left {
+            (null, value)
+        } else {
+            (value, null)
+        };
+
+        Ok(types::Expr::Binary(types::BinaryNode {
+            ty: self.ctx.types.primitive(PrimitiveType::Bool),
+            meta,
+            op,
+            lhs: Box::new(lhs),
+            rhs: Box::new(rhs),
+        }))
+    }
+
     fn emit_call(&mut self, node: ast::CallExpr) -> Result<types::Expr, Report> {
         let meta = ast_node_to_meta(&node);
         let callee = self.emit_expr(*node.callee)?;
 
-        let (params, ret) = match self.ctx.types.try_function(callee.type_id()) {
+        let callee_ty = self.ctx.types.resolve(callee.type_id());
+        let (params, ret) = match self.ctx.types.try_function(callee_ty) {
             Some(f) => (f.params.clone(), f.ret), // Copy to not use mut ref later
-            None => return Err(error_span("not a function", &callee)),
+            None => {
+                let name = callee
+                    .try_identifier()
+                    .map_or("expression".to_string(), |name| format!("'{name}'"));
+                let msg = format!(
+                    "{} is not a function (it is '{}')",
+                    name,
+                    self.ctx.types.type_to_string(callee.type_id())
+                );
+                return Err(error_span(&msg, &callee));
+            }
         };
 
         // Check if number of arguments matches
@@ -781,18 +1172,179 @@ impl<'a> FileChecker<'a> {
         // Otherwise this is a normal member getter and we treat lval as
         // a normal expression.
         let expr = self.emit_expr(*node.expr)?;
+        let resolved = self.ctx.types.resolve(expr.type_id());
 
-        Err(error_span(
-            &format!(
-                "type '{}' has no fields",
-                self.ctx.types.type_to_string(expr.type_id())
-            ),
-            &expr,
-        ))
+        let TypeKind::Struct(s) = &self.ctx.types.lookup(resolved).kind else {
+            return Err(error_span(
+                &format!(
+                    "type '{}' has no fields",
+                    self.ctx.types.type_to_string(expr.type_id())
+                ),
+                &expr,
+            ));
+        };
+
+        let Some((_, ty)) = s.fields.iter().find(|(name, _)| *name == field) else {
+            return Err(error_span(
+                &format!("type '{}' has no field '{}'", s.name, field),
+                &node.field,
+            ));
+        };
+
+        Ok(types::Expr::Member(types::MemberNode {
+            ty: *ty,
+            meta,
+            expr: Box::new(expr),
+            field,
+        }))
+    }
+
+    fn emit_index(&mut self, node: ast::IndexExpr) -> Result<types::Expr, Report> {
+        let meta = ast_node_to_meta(&node);
+
+        // Constant indices are checked against the bounds here, others are
+        // left for runtime checks
+        let const_index = eval_const_int(&node.index).ok();
+
+        let expr = self.emit_expr(*node.expr)?;
+        let index = self.emit_expr(*node.index)?;
+
+        // Slices have no known length so only arrays are bounds checked
+        let resolved = self.ctx.types.resolve(expr.type_id());
+        let (elem, len) = match self.ctx.types.lookup(resolved).kind {
+            TypeKind::Array(elem, len) => (elem, Some(len)),
+            TypeKind::Slice(elem) => (elem, None),
+            _ => {
+                return Err(error_span(
+                    &format!(
+                        "cannot index type '{}'",
+                        self.ctx.types.type_to_string(expr.type_id())
+                    ),
+                    &expr,
+                ));
+            }
+        };
+
+        if !self.is_integer(index.type_id()) {
+            return Err(error_span(
+                &format!(
+                    "array index must be an integer, got '{}'",
+                    self.ctx.types.type_to_string(index.type_id())
+                ),
+                &index,
+            ));
+        }
+
+        if let Some(i) = const_index
+            && (i < 0 || len.is_some_and(|len| i as usize >= len))
+        {
+            return Err(error_span(&format!("index out of range: {}", i), &index));
+        }
+
+        Ok(types::Expr::Index(types::IndexNode {
+            ty: elem,
+            meta,
+            expr: Box::new(expr),
+            index: Box::new(index),
+        }))
+    }
+
+    fn emit_struct_lit(&mut self, node: ast::StructLitExpr) -> Result<types::Expr, Report> {
+        let meta = ast_node_to_meta(&node);
+
+        let ty = match self.get_symbol(&node.name.to_string()) {
+            Ok(sym) if matches!(sym.kind, SymbolKind::Type) => sym.ty,
+            _ => return Err(error_span("not a type", &node.name)),
+        };
+
+        let resolved = self.ctx.types.resolve(ty);
+        let TypeKind::Struct(s) = self.ctx.types.lookup(resolved).kind.clone() else {
+            return Err(error_span(
+                &format!("type '{}' is not a struct", node.name),
+                &node.name,
+            ));
+        };
+
+        let mut fields: Vec<(String, types::Expr)> = Vec::new();
+        for field in node.fields {
+            let name = field.name.to_string();
+
+            let Some((_, field_ty)) = s.fields.iter().find(|(n, _)| *n == name) else {
+                return Err(error_span(
+                    &format!("type '{}' has no field '{}'", s.name, name),
+                    &field.name,
+                ));
+            };
+
+            if fields.iter().any(|(n, _)| *n == name) {
+                return Err(error_span(
+                    &format!("field '{}' is already given", name),
+                    &field.name,
+                ));
+            }
+
+            let value = self.emit_expr(field.value)?;
+            if !self.ctx.types.equivalent(*field_ty, value.type_id()) {
+                return Err(self.error_expected_got(
+                    &format!("mismatched types in field '{}'", name),
+                    *field_ty,
+                    value.type_id(),
+                    &value,
+                ));
+            }
+
+            fields.push((name, value));
+        }
+
+        Ok(types::Expr::StructLit(types::StructLitNode {
+            ty,
+            meta,
+            fields,
+        }))
+    }
+
+    fn emit_ternary(&mut self, node: ast::TernaryExpr) -> Result<types::Expr, Report> {
+        let meta = ast_node_to_meta(&node);
+
+        let cond = self.emit_expr(*node.cond)?;
+        self.assert_expr_is_type(PrimitiveType::Bool, &cond)?;
+
+        let then = self.emit_expr(*node.then)?;
+        let otherwise = self.emit_expr(*node.otherwise)?;
+
+        // Neither branch is preferred, so a mismatch is reported on the whole
+        // expression instead of as a wrong type in one of them
+        if then.type_id() != otherwise.type_id() {
+            return Err(error_span(
+                &format!(
+                    "ternary branches have different types ('{}' vs '{}')",
+                    self.type_to_string(&then),
+                    self.type_to_string(&otherwise),
+                ),
+                &meta,
+            ));
+        }
+
+        Ok(types::Expr::Ternary(types::TernaryNode {
+            ty: then.type_id(),
+            meta,
+            cond: Box::new(cond),
+            then: Box::new(then),
+            otherwise: Box::new(otherwise),
+        }))
     }
 
     // ----------------------- Utility methods ----------------------- //
 
+    /// Reports whether the type is a signed or unsigned integer.
+    fn is_integer(&self, ty: TypeId) -> bool {
+        let resolved = self.ctx.types.inner_kind(ty);
+        matches!(
+            self.ctx.types.lookup(resolved).kind,
+            TypeKind::Primitive(ref p) if p.is_int() || p.is_uint()
+        )
+    }
+
     fn check_binary_op_type(
         &self,
         op: &BinaryOp,
@@ -808,14 +1360,7 @@ impl<'a> FileChecker<'a> {
             BinaryOp::Minus => (is_num, "-"),
             BinaryOp::Mult => (is_num, "*"),
             BinaryOp::Divide => (is_num, "/"),
-            BinaryOp::Modulo => {
-                let resolved = self.ctx.types.inner_kind(ty);
-                let is_int = matches!(
-                    self.ctx.types.lookup(resolved).kind,
-                    TypeKind::Primitive(ref p) if p.is_int() || p.is_uint()
-                );
-                (is_int, "%")
-            }
+            BinaryOp::Modulo => (self.is_integer(ty), "%"),
             BinaryOp::Equal => (is_num || is_bool, "=="),
             BinaryOp::NotEqual => (is_num || is_bool, "!="),
             BinaryOp::Greater => (is_num, ">"),
@@ -901,7 +1446,7 @@ impl<'a> FileChecker<'a> {
         ) {
             Err(error_span("already declared", name).with_info(&format!(
                 "previously declared on line {}", // always local to this file
-                self.vars.get(&name.to_string()).unwrap().pos.row + 1
+                self.resolve(&name.to_string()).unwrap().0.row + 1
             )))
         } else {
             Ok(id)
@@ -916,7 +1461,7 @@ impl<'a> FileChecker<'a> {
         }
         if let Ok(sym) = self.get_symbol(&name_str) {
             return match sym.kind {
-                SymbolKind::Function { .. } => Ok(sym.ty),
+                SymbolKind::Function { .. } | SymbolKind::Constant => Ok(sym.ty),
                 SymbolKind::Type => Err(error_span("a type cannot be used as a value", name)),
             };
         }
@@ -933,13 +1478,23 @@ impl<'a> FileChecker<'a> {
     fn is_constant(&self, lval: &ast::Expr) -> bool {
         match lval {
             ast::Expr::Literal(token) => match &token.kind {
-                TokenKind::IdentLit(name) => self.vars.get(name).is_some_and(|sym| sym.is_const),
+                TokenKind::IdentLit(name) => match self.vars.get(name) {
+                    Some(var) => var.is_const,
+                    None => self
+                        .get_symbol(name)
+                        .is_ok_and(|sym| matches!(sym.kind, SymbolKind::Constant)),
+                },
                 _ => false,
             },
             ast::Expr::Member(node) => self.is_constant(&node.expr),
+            ast::Expr::Index(node) => self.is_constant(&node.expr),
             ast::Expr::Group(_)
+            | ast::Expr::StructLit(_)
+            | ast::Expr::Tuple(_)
             | ast::Expr::Call(_)
             | ast::Expr::Binary(_)
+            | ast::Expr::Ternary(_)
+            | ast::Expr::Block(_)
             | ast::Expr::Unary(_)
             | ast::Expr::Cast(_) => true,
         }
@@ -985,6 +1540,16 @@ impl<'a> FileChecker<'a> {
     }
 }
 
+fn is_null_literal(expr: &ast::Expr) -> bool {
+    matches!(expr, ast::Expr::Literal(tok) if tok.kind == TokenKind::Null)
+}
+
+/// Reports whether the expression is a constant integer expression
+/// evaluating to zero.
+fn is_const_zero(expr: &ast::Expr) -> bool {
+    eval_const_int(expr).is_ok_and(|n| n == 0)
+}
+
 enum ConstVal {
     Int(i64),
     Uint(u64),
@@ -3,11 +3,13 @@ use crate::{
     context::Context,
     error::{Report, error_span},
     module::{Namespace, Symbol, SymbolList},
-    types::TypeId,
+    typecheck::consteval::eval_const_int,
+    types::{TypeId, TypeKind},
 };
 
 pub(crate) trait CheckerHelpers<'a> {
     fn ctx(&self) -> &Context;
+    fn ctx_mut(&mut self) -> &mut Context;
     fn symbols(&self) -> &SymbolList;
     fn get_namespace(&self, name: &str) -> Option<&Namespace>;
 
@@ -26,7 +28,7 @@ pub(crate) trait CheckerHelpers<'a> {
     }
 
     /// Evaluate an AST type node to its semantic type id.
-    fn eval_type(&self, node: &ast::TypeNode) -> Result<TypeId, Report> {
+    fn eval_type(&mut self, node: &ast::TypeNode) -> Result<TypeId, Report> {
         match node {
             ast::TypeNode::Ident(token) => self
                 .get_symbol_type_id(&token.to_string())
@@ -42,6 +44,29 @@ pub(crate) trait CheckerHelpers<'a> {
 
                 Ok(self.ctx().symbols.get(sym_id).ty)
             }
+            ast::TypeNode::Array { size, elem, .. } => {
+                let len = eval_const_int(size)?;
+                if len < 0 {
+                    return Err(error_span("array size cannot be negative", size.as_ref()));
+                }
+
+                let elem = self.eval_type(elem)?;
+                Ok(self
+                    .ctx_mut()
+                    .types
+                    .get_or_intern(TypeKind::Array(elem, len as usize)))
+            }
+            ast::TypeNode::Slice { elem, .. } => {
+                let elem = self.eval_type(elem)?;
+                Ok(self.ctx_mut().types.get_or_intern(TypeKind::Slice(elem)))
+            }
+            ast::TypeNode::Tuple { types, .. } => {
+                let types = types
+                    .iter()
+                    .map(|ty| self.eval_type(ty))
+                    .collect::<Result<Vec<_>, _>>()?;
+                Ok(self.ctx_mut().types.get_or_intern(TypeKind::Tuple(types)))
+            }
         }
     }
 }
@@ -0,0 +1,60 @@
+use crate::{
+    context::Context,
+    error::{Diagnostics, error_span},
+    module::{ModuleId, ModuleKind},
+    types::{Expr, TypeKind, Walker, walk_decl},
+};
+
+/// Report a warning for each struct literal in the module which leaves out
+/// one or more fields of its struct. Left out fields hold their zero value.
+pub fn check_missing_fields(ctx: &Context, id: ModuleId) -> Diagnostics {
+    let mut checker = MissingFieldsChecker {
+        ctx,
+        diag: Diagnostics::new(),
+    };
+
+    if let ModuleKind::Source { files, .. } = &ctx.modules.get(id).kind {
+        for decl in files.iter().flat_map(|file| &file.ast.decls) {
+            walk_decl(&mut checker, decl);
+        }
+    }
+
+    checker.diag
+}
+
+struct MissingFieldsChecker<'a> {
+    ctx: &'a Context,
+    diag: Diagnostics,
+}
+
+impl Walker for MissingFieldsChecker<'_> {
+    fn expr(&mut self, expr: &Expr) {
+        let Expr::StructLit(node) = expr else {
+            return;
+        };
+
+        let resolved = self.ctx.types.resolve(node.ty);
+        let TypeKind::Struct(s) = &self.ctx.types.lookup(resolved).kind else {
+            return;
+        };
+
+        let missing = s
+            .fields
+            .iter()
+            .filter(|(name, _)| !node.fields.iter().any(|(given, _)| given == name))
+            .map(|(name, _)| format!("'{}'", name))
+            .collect::<Vec<_>>();
+
+        let msg = match missing.len() {
+            0 => return,
+            1 => format!("missing field {} in literal of '{}'", missing[0], s.name),
+            _ => format!(
+                "missing fields {} in literal of '{}'",
+                missing.join(", "),
+                s.name
+            ),
+        };
+
+        self.diag.add(error_span(&msg, expr).as_warning());
+    }
+}
@@ -1,11 +1,28 @@
+mod bool_compare;
+mod conditions;
+mod consteval;
+mod exported;
 mod file_check;
 mod helper;
+mod missing_fields;
 mod module_check;
+mod reachable;
+mod rules;
+mod shadowing;
+mod unreachable;
 
 #[cfg(test)]
 mod tests;
 
+pub use bool_compare::check_bool_comparisons;
+pub use conditions::check_const_conditions;
+pub use exported::check_exported_signatures;
+pub use missing_fields::check_missing_fields;
 use module_check::ModuleChecker;
+pub use reachable::{call_graph, check_unused_funcs, reachable_funcs};
+pub use rules::{ForbidCall, Rule};
+pub use shadowing::check_builtin_shadowing;
+pub use unreachable::check_unreachable_code;
 
 use crate::{
     ast::FileSet,
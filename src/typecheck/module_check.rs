@@ -1,3 +1,5 @@
+use std::collections::HashMap;
+
 use tracing::{debug, info};
 
 use crate::{
@@ -9,9 +11,11 @@ use crate::{
         ImportPath, ModuleKind, ModulePath, ModuleSourceFile, ModuleSymbol, ModuleSymbolKind,
         Namespace, NamespaceList, Symbol, SymbolId, SymbolKind, SymbolList, SymbolOrigin,
     },
+    typecheck::consteval::eval_const_value,
     typecheck::file_check::FileChecker,
     typecheck::helper::CheckerHelpers,
-    types::{FunctionType, PrimitiveType, TypeId, TypeKind, TypedAst},
+    typecheck::rules::check_rules,
+    types::{FunctionType, LiteralKind, PrimitiveType, StructType, TypeId, TypeKind, TypedAst},
 };
 
 /// Performs module-level checks: import resolution, global symbol declaration,
@@ -27,6 +31,12 @@ pub(crate) struct ModuleChecker<'a> {
     file_namespaces: Vec<NamespaceList>,
     /// Index of current file being checked.
     current_file: usize,
+    /// Constant declarations in this module by name.
+    const_decls: HashMap<String, ast::ConstDeclNode>,
+    /// Evaluated values of constants in this module by name.
+    const_values: HashMap<String, LiteralKind>,
+    /// Constants currently being evaluated, used to detect cycles.
+    evaluating: Vec<String>,
 }
 
 impl<'a> CheckerHelpers<'a> for ModuleChecker<'a> {
@@ -34,6 +44,10 @@ impl<'a> CheckerHelpers<'a> for ModuleChecker<'a> {
         self.ctx
     }
 
+    fn ctx_mut(&mut self) -> &mut Context {
+        self.ctx
+    }
+
     fn symbols(&self) -> &SymbolList {
         &self.symbols
     }
@@ -51,6 +65,9 @@ impl<'a> ModuleChecker<'a> {
             is_main: false,
             file_namespaces: Vec::new(),
             current_file: 0,
+            const_decls: HashMap::new(),
+            const_values: HashMap::new(),
+            evaluating: Vec::new(),
         };
 
         s.initialize_symbol_list();
@@ -217,13 +234,53 @@ impl<'a> ModuleChecker<'a> {
     fn global_pass(&mut self, fs: &ast::FileSet) -> Res<()> {
         let mut diag = Diagnostics::new();
 
-        for (i, file) in fs.files.iter().enumerate() {
-            self.current_file = i;
-            for decl in &file.ast.decls {
+        // Constants may refer to each other in any order, so their values are
+        // evaluated when first needed
+        self.const_decls = fs
+            .files
+            .iter()
+            .flat_map(|file| &file.ast.decls)
+            .filter_map(|decl| match decl {
+                ast::Decl::Const(node) => Some((node.name.to_string(), (**node).clone())),
+                _ => None,
+            })
+            .collect();
+
+        let decls = fs
+            .files
+            .iter()
+            .enumerate()
+            .flat_map(|(i, file)| file.ast.decls.iter().map(move |decl| (i, file, decl)));
+
+        let (mut types, others): (Vec<_>, Vec<_>) = decls
+            .partition(|(_, _, decl)| matches!(decl, ast::Decl::Type(_) | ast::Decl::Struct(_)));
+
+        // Types are declared first so that function signatures and constants
+        // can use types declared later in the module. Types may also use each
+        // other in any order, so the ones that fail are retried as long as
+        // each round declares at least one more type.
+        loop {
+            let mut failed = Vec::new();
+            for (i, file, decl) in types.iter().copied() {
+                self.current_file = i;
                 if let Err(err) = self.check_global_decl(&fs.modpath, &file.filename, decl) {
-                    diag.add(err);
+                    failed.push(((i, file, decl), err));
                 }
             }
+
+            if failed.len() == types.len() {
+                failed.into_iter().for_each(|(_, err)| diag.add(err));
+                break;
+            }
+
+            types = failed.into_iter().map(|(decl, _)| decl).collect();
+        }
+
+        for (i, file, decl) in others {
+            self.current_file = i;
+            if let Err(err) = self.check_global_decl(&fs.modpath, &file.filename, decl) {
+                diag.add(err);
+            }
         }
 
         if !diag.is_empty() {
@@ -260,9 +317,131 @@ impl<'a> ModuleChecker<'a> {
                 };
                 self.declare_type(node, origin)
             }
+            ast::Decl::Const(node) => {
+                let origin = SymbolOrigin::Module {
+                    modpath: modpath.clone(),
+                    pos: node.pos().clone(),
+                    filename: filename.into(),
+                };
+                self.declare_const(node, origin)
+            }
+            ast::Decl::Struct(node) => {
+                let origin = SymbolOrigin::Module {
+                    modpath: modpath.clone(),
+                    pos: node.pos().clone(),
+                    filename: filename.into(),
+                };
+                self.declare_struct(node, origin)
+            }
         }
     }
 
+    fn declare_const(
+        &mut self,
+        node: &ast::ConstDeclNode,
+        origin: SymbolOrigin,
+    ) -> Result<(), Report> {
+        let value = self.const_value(&node.name)?;
+        let primitive = match value {
+            LiteralKind::Float(_) => PrimitiveType::F32,
+            LiteralKind::String(_) => PrimitiveType::String,
+            LiteralKind::Bool(_) => PrimitiveType::Bool,
+            _ => PrimitiveType::I32,
+        };
+        let inferred = self.ctx.types.primitive_type(primitive).id;
+
+        // Integer constants may be given any integer type they fit in, and
+        // float constants any float type
+        let ty = match &node.ty {
+            None => inferred,
+            Some(ty) => {
+                let declared = self.eval_type(ty)?;
+                let primitive = match &self
+                    .ctx
+                    .types
+                    .lookup(self.ctx.types.deep_resolve(declared))
+                    .kind
+                {
+                    TypeKind::Primitive(p) => Some(p.clone()),
+                    _ => None,
+                };
+
+                match (&value, primitive) {
+                    (LiteralKind::Float(_), Some(p)) if p.is_float() => {}
+                    (LiteralKind::Int(n), Some(p)) if p.is_int() || p.is_uint() => {
+                        if !int_fits(*n, &p) {
+                            return Err(error_span(
+                                &format!(
+                                    "constant value {} does not fit in type '{}'",
+                                    n,
+                                    self.ctx.types.type_to_string(declared)
+                                ),
+                                &node.expr,
+                            ));
+                        }
+                    }
+                    _ if !self.ctx.types.equivalent(declared, inferred) => {
+                        return Err(error_span(
+                            &format!(
+                                "mismatched types in constant declaration: expected '{}', got '{}'",
+                                self.ctx.types.type_to_string(declared),
+                                self.ctx.types.type_to_string(inferred)
+                            ),
+                            &node.expr,
+                        ));
+                    }
+                    _ => {}
+                }
+
+                declared
+            }
+        };
+
+        let symbol = CreateSymbol {
+            name: node.name.to_string(),
+            alias: None,
+            kind: SymbolKind::Constant,
+            ty,
+            origin,
+            is_exported: node.public,
+            no_mangle: false,
+        };
+
+        self.check_symbol_already_declared(&symbol.name, node)?;
+        let _ = self.create_symbol(symbol);
+        Ok(())
+    }
+
+    /// Get the value of a constant declared in this module, evaluating it on
+    /// first use. Errors if the constant is defined in terms of itself.
+    fn const_value(&mut self, name: &ast::Token) -> Result<LiteralKind, Report> {
+        let key = name.to_string();
+        if let Some(value) = self.const_values.get(&key) {
+            return Ok(value.clone());
+        }
+
+        let Some(node) = self.const_decls.get(&key).cloned() else {
+            return Err(error_span(&format!("'{}' is not a constant", key), name));
+        };
+
+        if let Some(start) = self.evaluating.iter().position(|n| *n == key) {
+            let mut cycle = self.evaluating[start..].to_vec();
+            cycle.push(key);
+            return Err(error_span(
+                &format!("constant cycle: {}", cycle.join(" -> ")),
+                name,
+            ));
+        }
+
+        self.evaluating.push(key.clone());
+        let value = eval_const_value(&node.expr, &mut |tok| self.const_value(tok));
+        self.evaluating.pop();
+
+        let value = value?;
+        self.const_values.insert(key, value.clone());
+        Ok(value)
+    }
+
     fn declare_type(
         &mut self,
         node: &ast::TypeDeclNode,
@@ -296,6 +475,44 @@ impl<'a> ModuleChecker<'a> {
         Ok(())
     }
 
+    fn declare_struct(
+        &mut self,
+        node: &ast::StructDeclNode,
+        origin: SymbolOrigin,
+    ) -> Result<(), Report> {
+        let name = node.name.to_string();
+        let module = match &origin {
+            SymbolOrigin::Module { modpath, .. } => modpath.to_header_format(),
+            _ => String::new(),
+        };
+
+        let fields = node
+            .fields
+            .iter()
+            .map(|f| self.eval_type(&f.typ).map(|id| (f.name.to_string(), id)))
+            .collect::<Result<Vec<_>, _>>()?;
+
+        let ty = self.ctx.types.get_or_intern(TypeKind::Struct(StructType {
+            name: name.clone(),
+            module,
+            fields,
+        }));
+
+        let symbol = CreateSymbol {
+            name,
+            alias: None,
+            kind: SymbolKind::Type,
+            ty,
+            origin,
+            is_exported: node.public,
+            no_mangle: false,
+        };
+
+        self.check_symbol_already_declared(&symbol.name, node)?;
+        let _ = self.create_symbol(symbol);
+        Ok(())
+    }
+
     fn declare_function_definition(
         &mut self,
         node: &ast::FuncDeclNode,
@@ -413,6 +630,14 @@ impl<'a> ModuleChecker<'a> {
 
     fn check_symbol_already_declared(&self, name: &str, node: &dyn Span) -> Result<(), Report> {
         if let Ok(sym) = self.get_symbol(name) {
+            // Builtin types live in the same symbol list, so they cannot be shadowed
+            if let SymbolOrigin::Intrinsic = sym.origin {
+                return Err(error_span(
+                    &format!("'{}' is a builtin type and cannot be redeclared", name),
+                    node,
+                ));
+            }
+
             let mut report = error_span("already declared", node);
 
             if let SymbolOrigin::Module { pos, filename, .. } = &sym.origin {
@@ -442,12 +667,21 @@ impl<'a> ModuleChecker<'a> {
         for (file, nsl) in ast_files.into_iter().zip(all_nsl) {
             info!("Type check: {}", file.filepath);
 
-            let mut file_checker = FileChecker::new(self.ctx, &self.symbols, nsl, self.is_main);
+            let mut file_checker = FileChecker::new(
+                self.ctx,
+                &self.symbols,
+                &self.const_values,
+                nsl,
+                self.is_main,
+            );
 
             let decls = file_checker.emit_ast(file.ast)?;
             let nsl = file_checker.into_namespaces();
             let ast = TypedAst { decls };
 
+            // Run custom rules on the finished typed AST
+            check_rules(self.ctx, &ast).err_or(())?;
+
             files.push(ModuleSourceFile {
                 filename: file.filename,
                 namespaces: nsl,
@@ -497,3 +731,13 @@ impl<'a> ModuleChecker<'a> {
         Ok(id)
     }
 }
+
+/// Reports whether the integer value can be represented by the integer type.
+fn int_fits(n: i64, p: &PrimitiveType) -> bool {
+    let bits = (p.bytes() * 8) as u32;
+    if p.is_uint() {
+        n >= 0 && (bits >= 64 || n < 1 << bits)
+    } else {
+        bits >= 64 || (-(1 << (bits - 1))..1 << (bits - 1)).contains(&n)
+    }
+}
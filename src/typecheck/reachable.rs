@@ -0,0 +1,142 @@
+use std::collections::{HashMap, HashSet};
+
+use crate::{
+    context::Context,
+    error::{Diagnostics, error_span},
+    module::{ModuleId, ModuleKind, SymbolKind},
+    types::{Decl, Expr, FuncNode, Walker, walk_block},
+};
+
+/// Compute the set of functions in the module which are reachable from the
+/// main function or any exported function by following calls and other
+/// references to functions by name.
+pub fn reachable_funcs(ctx: &Context, id: ModuleId) -> HashSet<String> {
+    let funcs = module_funcs(ctx, id);
+    let mut reachable = HashSet::new();
+
+    let mut stack = funcs
+        .values()
+        .filter(|f| f.public || f.name == "main")
+        .map(|f| f.name.clone())
+        .collect::<Vec<_>>();
+
+    while let Some(name) = stack.pop() {
+        // Externs, imported functions, and variables are not in the map
+        let Some(func) = funcs.get(name.as_str()) else {
+            continue;
+        };
+
+        if reachable.insert(name) {
+            walk_block(&mut RefCollector { refs: &mut stack }, &func.body);
+        }
+    }
+
+    reachable
+}
+
+/// Report a warning for each private function in the module which is never
+/// reached from the main function or any exported function.
+pub fn check_unused_funcs(ctx: &Context, id: ModuleId) -> Diagnostics {
+    let reachable = reachable_funcs(ctx, id);
+
+    let mut unused = module_funcs(ctx, id)
+        .into_values()
+        .filter(|f| !reachable.contains(&f.name))
+        .collect::<Vec<_>>();
+
+    // Report in source order
+    unused.sort_by_key(|f| (f.meta.pos.source_id, f.meta.pos.offset));
+
+    let mut diag = Diagnostics::new();
+    for func in unused {
+        let msg = format!("function '{}' is never used", func.name);
+        diag.add(error_span(&msg, &func.meta).as_warning());
+    }
+
+    diag
+}
+
+/// Build the call graph of a module, mapping each function to the functions
+/// it calls directly by name. Callees are listed in order of their first call
+/// in the source and without duplicates. Functions in other modules are named
+/// by their namespace, eg. 'io.println'. Callees are resolved against the
+/// functions and externs declared in the module, so calls through local
+/// variables are not edges.
+pub fn call_graph(ctx: &Context, id: ModuleId) -> HashMap<String, Vec<String>> {
+    let module = ctx.modules.get(id);
+    let symbols = module
+        .symbols
+        .symbols()
+        .iter()
+        .filter(|(_, sym)| matches!(ctx.symbols.get(sym.id).kind, SymbolKind::Function { .. }))
+        .map(|(name, _)| name.as_str())
+        .collect::<HashSet<_>>();
+
+    module_funcs(ctx, id)
+        .into_iter()
+        .map(|(name, func)| {
+            let mut collector = CallCollector {
+                funcs: &symbols,
+                calls: Vec::new(),
+            };
+            walk_block(&mut collector, &func.body);
+            (name.to_owned(), collector.calls)
+        })
+        .collect()
+}
+
+/// Collect all functions declared in a source module by name.
+fn module_funcs(ctx: &Context, id: ModuleId) -> HashMap<&str, &FuncNode> {
+    let ModuleKind::Source { files, .. } = &ctx.modules.get(id).kind else {
+        return HashMap::new();
+    };
+
+    files
+        .iter()
+        .flat_map(|file| &file.ast.decls)
+        .filter_map(|decl| match decl {
+            Decl::Func(func) => Some((func.name.as_str(), func)),
+            Decl::Extern(_) | Decl::Const(_) => None,
+        })
+        .collect()
+}
+
+/// Collects all identifiers referenced in a function body.
+struct RefCollector<'a> {
+    refs: &'a mut Vec<String>,
+}
+
+impl Walker for RefCollector<'_> {
+    fn expr(&mut self, expr: &Expr) {
+        if let Some(name) = expr.try_identifier() {
+            self.refs.push(name.to_owned());
+        }
+    }
+}
+
+/// Collects the names of all functions called in a function body.
+struct CallCollector<'a> {
+    /// Functions and externs declared in the module.
+    funcs: &'a HashSet<&'a str>,
+    calls: Vec<String>,
+}
+
+impl Walker for CallCollector<'_> {
+    fn expr(&mut self, expr: &Expr) {
+        let Expr::Call(node) = expr else {
+            return;
+        };
+
+        let name = match &*node.callee {
+            Expr::NamespaceMember(member) => format!("{}.{}", member.name, member.field),
+            callee => match callee.try_identifier() {
+                Some(name) if self.funcs.contains(name) => name.to_owned(),
+                _ => return,
+            },
+        };
+
+        if !self.calls.contains(&name) {
+            self.calls.push(name);
+        }
+    }
+}
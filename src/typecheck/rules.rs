@@ -0,0 +1,79 @@
+use crate::{
+    context::Context,
+    error::{Diagnostics, error_span},
+    types::{Decl, Expr, LiteralKind, Stmt, TypedAst, Walker, walk_decl},
+};
+
+/// A Rule is a custom check which is run on every node of a file after it has
+/// been type checked. Rules are registered with Context::add_rule and any
+/// reports they add are treated as errors.
+pub trait Rule {
+    fn check_decl(&self, _ctx: &Context, _decl: &Decl, _diag: &mut Diagnostics) {}
+    fn check_stmt(&self, _ctx: &Context, _stmt: &Stmt, _diag: &mut Diagnostics) {}
+    fn check_expr(&self, _ctx: &Context, _expr: &Expr, _diag: &mut Diagnostics) {}
+}
+
+/// Forbids calling the function with the given name.
+pub struct ForbidCall {
+    name: String,
+}
+
+impl ForbidCall {
+    pub fn new(name: &str) -> Self {
+        Self {
+            name: name.to_owned(),
+        }
+    }
+}
+
+impl Rule for ForbidCall {
+    fn check_expr(&self, _ctx: &Context, expr: &Expr, diag: &mut Diagnostics) {
+        if let Expr::Call(call) = expr
+            && let Expr::Literal(callee) = &*call.callee
+            && let LiteralKind::Ident(name) = &callee.kind
+            && *name == self.name
+        {
+            let msg = format!("call to '{}' is forbidden", self.name);
+            diag.add(error_span(&msg, &callee.meta));
+        }
+    }
+}
+
+/// Run all rules registered in the context on every node in the AST.
+pub(crate) fn check_rules(ctx: &Context, ast: &TypedAst) -> Diagnostics {
+    let mut runner = RuleRunner {
+        ctx,
+        diag: Diagnostics::new(),
+    };
+
+    for decl in &ast.decls {
+        walk_decl(&mut runner, decl);
+    }
+
+    runner.diag
+}
+
+struct RuleRunner<'a> {
+    ctx: &'a Context,
+    diag: Diagnostics,
+}
+
+impl Walker for RuleRunner<'_> {
+    fn decl(&mut self, decl: &Decl) {
+        for rule in &self.ctx.rules {
+            rule.check_decl(self.ctx, decl, &mut self.diag);
+        }
+    }
+
+    fn stmt(&mut self, stmt: &Stmt) {
+        for rule in &self.ctx.rules {
+            rule.check_stmt(self.ctx, stmt, &mut self.diag);
+        }
+    }
+
+    fn expr(&mut self, expr: &Expr) {
+        for rule in &self.ctx.rules {
+            rule.check_expr(self.ctx, expr, &mut self.diag);
+        }
+    }
+}
@@ -0,0 +1,48 @@
+use std::collections::HashSet;
+
+use crate::{
+    context::Context,
+    error::{Diagnostics, error_span},
+    module::{ModuleId, ModuleKind, SymbolOrigin},
+    types::Decl,
+};
+
+/// Report a warning for each function parameter named after a builtin, eg.
+/// 'string'. The parameter hides the builtin in expressions, while the same
+/// name still refers to the builtin where a type is expected. Declarations
+/// and local variables cannot take a builtin name, so only parameters are
+/// checked.
+pub fn check_builtin_shadowing(ctx: &Context, id: ModuleId) -> Diagnostics {
+    let module = ctx.modules.get(id);
+    let mut diag = Diagnostics::new();
+
+    let ModuleKind::Source { files, .. } = &module.kind else {
+        return diag;
+    };
+
+    let builtins = module
+        .symbols
+        .symbols()
+        .iter()
+        .filter(|(_, sym)| matches!(ctx.symbols.get(sym.id).origin, SymbolOrigin::Intrinsic))
+        .map(|(name, _)| name.as_str())
+        .collect::<HashSet<_>>();
+
+    for decl in files.iter().flat_map(|file| &file.ast.decls) {
+        let Decl::Func(func) = decl else {
+            continue;
+        };
+
+        for param in &func.params {
+            if builtins.contains(param.name.as_str()) {
+                let msg = format!(
+                    "parameter '{}' in function '{}' shadows a builtin type",
+                    param.name, func.name
+                );
+                diag.add(error_span(&msg, param).as_warning());
+            }
+        }
+    }
+
+    diag
+}
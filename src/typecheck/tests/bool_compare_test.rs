@@ -0,0 +1,78 @@
+use crate::{common::check_warnings, typecheck::check_bool_comparisons};
+
+#[test]
+fn test_equal_true_warns() {
+    let src = r#"
+        func f(x bool) bool {
+            return x == true
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_bool_comparisons),
+        vec!["redundant comparison with 'true', simplify to 'x'"]
+    );
+}
+
+#[test]
+fn test_not_equal_true_suggests_negation() {
+    let src = r#"
+        func f(x bool) bool {
+            return true != x
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_bool_comparisons),
+        vec!["redundant comparison with 'true', simplify to '!x'"]
+    );
+}
+
+#[test]
+fn test_equal_false_in_condition_warns() {
+    let src = r#"
+        func f(x bool) {
+            if x == false {
+            }
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_bool_comparisons),
+        vec!["redundant comparison with 'false', simplify to '!x'"]
+    );
+}
+
+#[test]
+fn test_compare_bool_variables_no_warning() {
+    let src = r#"
+        func f(x bool, y bool) bool {
+            return x == y
+        }
+    "#;
+    assert!(check_warnings(src, check_bool_comparisons).is_empty());
+}
+
+#[test]
+fn test_compare_two_literals_no_warning() {
+    let src = r#"
+        func f() bool {
+            return true == false
+        }
+    "#;
+    assert!(check_warnings(src, check_bool_comparisons).is_empty());
+}
+
+#[test]
+fn test_compare_call_with_literal_warns() {
+    let src = r#"
+        func g() bool {
+            return true
+        }
+
+        func f() bool {
+            return g() == false
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_bool_comparisons),
+        vec!["redundant comparison with 'false', negate the operand with '!' instead"]
+    );
+}
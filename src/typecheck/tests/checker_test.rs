@@ -1,12 +1,17 @@
+use std::collections::HashMap;
+
 use crate::{
-    common::{check_string, must},
+    ast,
+    common::{check_string, must, must_check, new_modpath, parse_string},
     config::Config,
-    context::Context,
+    context::{Context, CreateSymbol},
+    module::{ModuleSymbol, ModuleSymbolKind, NamespaceList, SymbolKind, SymbolList, SymbolOrigin},
+    typecheck::file_check::{FileChecker, MAX_BLOCK_DEPTH},
+    types::{FunctionType, PrimitiveType, TypeKind},
 };
 
 fn assert_pass(src: &str) {
-    let mut ctx = Context::new(Config::test());
-    must(check_string(&mut ctx, src));
+    must_check(src);
 }
 
 fn assert_error(src: &str, msg: &str) {
@@ -73,6 +78,53 @@ fn test_return_type_error_int_in_bool_func() {
     );
 }
 
+#[test]
+fn test_return_multiple_values_pass() {
+    assert_pass(
+        r#"
+        func foo(a int) (int, string, bool) {
+            return a, "foo", a > 0
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_return_multiple_values_too_few() {
+    assert_error(
+        r#"
+        func foo() (int, string) {
+            return 1
+        }
+    "#,
+        "expected 2 return values, got 1",
+    );
+}
+
+#[test]
+fn test_return_multiple_values_too_many() {
+    assert_error(
+        r#"
+        func foo() int {
+            return 1, 2
+        }
+    "#,
+        "expected 1 return value, got 2",
+    );
+}
+
+#[test]
+fn test_return_multiple_values_wrong_type() {
+    assert_error(
+        r#"
+        func foo() (int, string) {
+            return 1, 2
+        }
+    "#,
+        "incorrect return type: expected '(i32, string)', got '(i32, i32)'",
+    );
+}
+
 #[test]
 fn test_return_type_error_unknown_type() {
     assert_error(
@@ -190,7 +242,7 @@ fn test_function_call_fail_call_literal() {
             (1)()
         }
     "#,
-        "not a function",
+        "expression is not a function (it is 'i32')",
     );
 }
 
@@ -202,7 +254,7 @@ fn test_function_call_fail_call_void_result() {
             f()()
         }
     "#,
-        "not a function",
+        "expression is not a function (it is 'void')",
     );
 }
 
@@ -218,6 +270,46 @@ fn test_function_call_fail_too_few_args() {
     );
 }
 
+#[test]
+fn test_function_call_fail_too_many_args() {
+    assert_error(
+        r#"
+        func f(a int) {
+            f(1, 2)
+        }
+    "#,
+        "function takes 1 arguments, got 2",
+    );
+}
+
+#[test]
+fn test_function_call_fail_type_mismatch_later_arg() {
+    assert_error(
+        r#"
+        func f(a int, b string, c bool) {
+            f(1, "koi", 3)
+        }
+    "#,
+        "mismatched types in function call. expected 'bool', got 'i32'",
+    );
+}
+
+#[test]
+fn test_function_call_result_has_return_type() {
+    assert_error(
+        r#"
+        func g() string {
+            return "koi"
+        }
+
+        func f() {
+            a int = g()
+        }
+    "#,
+        "mismatched types in declaration: expected 'i32', got 'string'",
+    );
+}
+
 #[test]
 fn test_function_call_fail_type_mismatch() {
     assert_error(
@@ -341,6 +433,42 @@ fn test_variable_decl_error_redeclared() {
     );
 }
 
+#[test]
+fn test_variable_decl_error_redeclared_param() {
+    assert_error(
+        r#"
+        func f(a int) {
+            a := true
+        }
+    "#,
+        "already declared",
+    );
+}
+
+#[test]
+fn test_variable_decl_shadow_param_in_nested_block() {
+    assert_pass(
+        r#"
+        func f(a int) {
+            if a > 0 {
+                a := true
+            }
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_param_named_as_function() {
+    assert_pass(
+        r#"
+        func f(f int) int {
+            return f
+        }
+    "#,
+    );
+}
+
 #[test]
 fn test_variable_decl_error_assign_void() {
     assert_error(
@@ -394,287 +522,556 @@ fn test_variable_assignment_pass_const_then_mut() {
 }
 
 #[test]
-fn test_variable_assignment_fail_type_mismatch() {
-    assert_error(
+fn test_var_decl_pass() {
+    assert_pass(
         r#"
-        func f() {
-            a := 0
-            a = true
+        func f() int {
+            var a int
+            var b = 5
+            var c string = "koi"
+            var d [4]bool
+            a = b
+            return a
         }
     "#,
-        "mismatched types in assignment. expected 'i32', got 'bool'",
     );
 }
 
 #[test]
-fn test_variable_assignment_fail_not_declared() {
+fn test_var_decl_error_no_type_or_value() {
     assert_error(
         r#"
         func f() {
-            a = 1
+            var z
         }
     "#,
-        "not declared",
+        "variable declared with var must have a type or a value",
     );
 }
 
 #[test]
-fn test_variable_assignment_fail_reassign_const() {
+fn test_var_decl_error_type_mismatch() {
     assert_error(
         r#"
         func f() {
-            a :: 0
-            a = 1
+            var a int = true
         }
     "#,
-        "cannot assign new value to a constant",
+        "mismatched types in declaration: expected 'i32', got 'bool'",
     );
 }
 
 #[test]
-fn test_main_function_must_return_i32() {
+fn test_var_decl_error_already_declared() {
     assert_error(
         r#"
-        func main() {
-            return
+        func f() {
+            a := 0
+            var a int
         }
     "#,
-        "main function must return 'i32', got 'void'",
+        "already declared",
     );
 }
 
 #[test]
-fn test_main_function_must_not_take_args() {
+fn test_variable_assignment_fail_type_mismatch() {
     assert_error(
         r#"
-        func main(a int) int {
-            return 0
+        func f() {
+            a := 0
+            a = true
         }
     "#,
-        "main function must not take any arguments",
+        "mismatched types in assignment. expected 'i32', got 'bool'",
     );
 }
 
 #[test]
-fn test_member_error_on_int_literal() {
+fn test_variable_assignment_fail_not_declared() {
     assert_error(
         r#"
-        func main() int {
-            123.foo
-            return 0
+        func f() {
+            a = 1
         }
     "#,
-        "type 'i32' has no fields",
+        "not declared",
     );
 }
 
 #[test]
-fn test_member_error_on_string() {
+fn test_op_assign_fail_not_declared() {
     assert_error(
         r#"
-        func f() string {
-            return "foo"
-        }
-        func main() int {
-            f().bar
-            return 0
+        func f() {
+            a += 1
         }
     "#,
-        "type 'string' has no fields",
+        "not declared",
     );
 }
 
 #[test]
-fn test_duplicate_symbol() {
+fn test_op_assign_fail_const() {
     assert_error(
         r#"
-        func f() {}
-        func f() {}
+        func f() {
+            a :: 1
+            a -= 1
+        }
     "#,
-        "already declared",
+        "cannot assign new value to a constant",
     );
 }
 
 #[test]
-fn test_extern_then_func_conflict() {
-    // extern declaration followed by a concrete function with same name should be an error
+fn test_op_assign_fail_type_mismatch() {
     assert_error(
         r#"
-        extern func foo()
-        func foo() {
+        func f(a int, b f32) {
+            a *= b
         }
     "#,
-        "already declared",
+        "mismatched types in assignment. expected 'i32', got 'f32'",
     );
 }
 
 #[test]
-fn test_duplicate_extern_declaration() {
-    // two extern declarations with same name should be an error
+fn test_variable_assignment_fail_reassign_const() {
     assert_error(
         r#"
-        extern func foo()
-        extern func foo()
+        func f() {
+            a :: 0
+            a = 1
+        }
     "#,
-        "already declared",
+        "cannot assign new value to a constant",
     );
 }
 
 #[test]
-fn test_return_from_call_mismatch() {
-    // returning result of a function with wrong return type should surface proper error
+fn test_main_function_must_return_i32() {
     assert_error(
         r#"
-        func g() bool {
-            return true
-        }
-        func f() int {
-            return g()
+        func main() {
+            return
         }
     "#,
-        "incorrect return type: expected 'i32', got 'bool'",
+        "main function must return 'i32', got 'void'",
     );
 }
 
 #[test]
-fn test_binary_add_pass() {
-    assert_pass(
+fn test_main_function_must_not_take_args() {
+    assert_error(
         r#"
-        func f(a int, b int) int {
-            return a + b
+        func main(a int) int {
+            return 0
         }
     "#,
+        "main function must not take any arguments",
     );
 }
 
 #[test]
-fn test_binary_sub_pass() {
-    assert_pass(
+fn test_member_error_on_int_literal() {
+    assert_error(
         r#"
-        func f(a int, b int) int {
-            return a - b
+        func main() int {
+            123.foo
+            return 0
         }
     "#,
+        "type 'i32' has no fields",
     );
 }
 
 #[test]
-fn test_binary_mul_pass() {
+fn test_member_struct_field_pass() {
     assert_pass(
         r#"
-        func f(a int, b int) int {
-            return a * b
+        struct Point { x i32, y i32 }
+        struct Line { from Point, to Point }
+
+        func f(p Point, l Line) i32 {
+            return p.x + l.to.y
         }
     "#,
     );
 }
 
 #[test]
-fn test_binary_div_pass() {
+fn test_struct_field_declared_later_pass() {
     assert_pass(
         r#"
-        func f(a int, b int) int {
-            return a / b
+        struct Line { from Point, to Point }
+        type Path [2]Line
+        struct Point { x int, y int }
+
+        func f(p Path) int {
+            return p[0].to.y
         }
     "#,
     );
 }
 
 #[test]
-fn test_binary_add_chained_pass() {
-    assert_pass(
+fn test_struct_field_cycle_error() {
+    assert_error(
         r#"
-        func f(a int, b int) int {
-            return a + b + a
-        }
+        struct A { b B }
+        struct B { a A }
     "#,
+        "not a type",
     );
 }
 
 #[test]
-fn test_binary_eq_yields_bool() {
-    assert_pass(
+fn test_member_struct_field_type() {
+    assert_error(
         r#"
-        func f(a int, b int) bool {
-            return a == b
+        struct Person { name string }
+
+        func f(p Person) i32 {
+            return p.name
         }
     "#,
+        "incorrect return type: expected 'i32', got 'string'",
     );
 }
 
 #[test]
-fn test_binary_ne_yields_bool() {
-    assert_pass(
+fn test_member_struct_unknown_field_error() {
+    assert_error(
         r#"
-        func f(a int, b int) bool {
-            return a != b
+        struct Point { x i32, y i32 }
+
+        func f(p Point) i32 {
+            return p.z
         }
     "#,
+        "type 'Point' has no field 'z'",
     );
 }
 
 #[test]
-fn test_binary_lt_yields_bool() {
-    assert_pass(
+fn test_member_error_on_array() {
+    assert_error(
         r#"
-        func f(a int, b int) bool {
-            return a < b
+        func f(a [2]i32) i32 {
+            return a.x
         }
     "#,
+        "type '[2]i32' has no fields",
     );
 }
 
 #[test]
-fn test_binary_gt_yields_bool() {
+fn test_struct_literal_pass() {
     assert_pass(
         r#"
-        func f(a int, b int) bool {
-            return a > b
+        struct Point { x i32, y i32 }
+        struct Line { from Point, to Point }
+
+        func f() i32 {
+            p := Point{x: 1, y: 2}
+            l := Line{from: p, to: Point{y: 3}}
+            return l.to.y + Point{x: 4}.x
         }
     "#,
     );
 }
 
 #[test]
-fn test_binary_le_yields_bool() {
-    assert_pass(
+fn test_struct_literal_type() {
+    assert_error(
         r#"
-        func f(a int, b int) bool {
-            return a <= b
+        struct Point { x i32, y i32 }
+
+        func f() i32 {
+            return Point{x: 1}
         }
     "#,
+        "incorrect return type: expected 'i32', got 'Point'",
     );
 }
 
 #[test]
-fn test_binary_ge_yields_bool() {
-    assert_pass(
+fn test_struct_literal_unknown_field_error() {
+    assert_error(
         r#"
-        func f(a int, b int) bool {
-            return a >= b
+        struct Point { x i32, y i32 }
+
+        func f() {
+            p := Point{x: 1, z: 2}
         }
     "#,
+        "type 'Point' has no field 'z'",
     );
 }
 
 #[test]
-fn test_binary_eq_not_int() {
+fn test_struct_literal_mismatched_field_type_error() {
     assert_error(
         r#"
-        func f(a int, b int) int {
-            return a == b
+        struct Person { name string, age i32 }
+
+        func f() {
+            p := Person{name: 32}
         }
     "#,
-        "incorrect return type: expected 'i32', got 'bool'",
+        "mismatched types in field 'name': expected 'string', got 'i32'",
     );
 }
 
 #[test]
-fn test_binary_lt_not_int() {
+fn test_struct_literal_duplicate_field_error() {
     assert_error(
         r#"
-        func f(a int, b int) int {
-            return a < b
+        struct Point { x i32, y i32 }
+
+        func f() {
+            p := Point{x: 1, x: 2}
+        }
+    "#,
+        "field 'x' is already given",
+    );
+}
+
+#[test]
+fn test_struct_literal_not_a_struct_error() {
+    assert_error(
+        r#"
+        type Number i32
+
+        func f() {
+            n := Number{}
+        }
+    "#,
+        "type 'Number' is not a struct",
+    );
+}
+
+#[test]
+fn test_struct_literal_not_a_type_error() {
+    assert_error(
+        r#"
+        func f() {
+            a := 1
+            p := a{}
+        }
+    "#,
+        "not a type",
+    );
+}
+
+#[test]
+fn test_member_error_on_string() {
+    assert_error(
+        r#"
+        func f() string {
+            return "foo"
+        }
+        func main() int {
+            f().bar
+            return 0
+        }
+    "#,
+        "type 'string' has no fields",
+    );
+}
+
+#[test]
+fn test_duplicate_symbol() {
+    assert_error(
+        r#"
+        func f() {}
+        func f() {}
+    "#,
+        "already declared",
+    );
+}
+
+#[test]
+fn test_extern_then_func_conflict() {
+    // extern declaration followed by a concrete function with same name should be an error
+    assert_error(
+        r#"
+        extern func foo()
+        func foo() {
+        }
+    "#,
+        "already declared",
+    );
+}
+
+#[test]
+fn test_duplicate_extern_declaration() {
+    // two extern declarations with same name should be an error
+    assert_error(
+        r#"
+        extern func foo()
+        extern func foo()
+    "#,
+        "already declared",
+    );
+}
+
+#[test]
+fn test_return_from_call_mismatch() {
+    // returning result of a function with wrong return type should surface proper error
+    assert_error(
+        r#"
+        func g() bool {
+            return true
+        }
+        func f() int {
+            return g()
+        }
+    "#,
+        "incorrect return type: expected 'i32', got 'bool'",
+    );
+}
+
+#[test]
+fn test_binary_add_pass() {
+    assert_pass(
+        r#"
+        func f(a int, b int) int {
+            return a + b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_sub_pass() {
+    assert_pass(
+        r#"
+        func f(a int, b int) int {
+            return a - b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_mul_pass() {
+    assert_pass(
+        r#"
+        func f(a int, b int) int {
+            return a * b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_div_pass() {
+    assert_pass(
+        r#"
+        func f(a int, b int) int {
+            return a / b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_add_chained_pass() {
+    assert_pass(
+        r#"
+        func f(a int, b int) int {
+            return a + b + a
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_eq_yields_bool() {
+    assert_pass(
+        r#"
+        func f(a int, b int) bool {
+            return a == b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_ne_yields_bool() {
+    assert_pass(
+        r#"
+        func f(a int, b int) bool {
+            return a != b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_lt_yields_bool() {
+    assert_pass(
+        r#"
+        func f(a int, b int) bool {
+            return a < b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_gt_yields_bool() {
+    assert_pass(
+        r#"
+        func f(a int, b int) bool {
+            return a > b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_le_yields_bool() {
+    assert_pass(
+        r#"
+        func f(a int, b int) bool {
+            return a <= b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_ge_yields_bool() {
+    assert_pass(
+        r#"
+        func f(a int, b int) bool {
+            return a >= b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_binary_eq_not_int() {
+    assert_error(
+        r#"
+        func f(a int, b int) int {
+            return a == b
+        }
+    "#,
+        "incorrect return type: expected 'i32', got 'bool'",
+    );
+}
+
+#[test]
+fn test_binary_lt_not_int() {
+    assert_error(
+        r#"
+        func f(a int, b int) int {
+            return a < b
         }
     "#,
         "incorrect return type: expected 'i32', got 'bool'",
@@ -786,6 +1183,29 @@ fn test_binary_comparison_result_in_variable() {
     );
 }
 
+#[test]
+fn test_binary_ordering_result_is_bool() {
+    assert_error(
+        r#"
+        func f(a int, b int) int {
+            return a <= b
+        }
+    "#,
+        "incorrect return type: expected 'i32', got 'bool'",
+    );
+}
+
+#[test]
+fn test_binary_comparison_chain_pass() {
+    assert_pass(
+        r#"
+        func f(a int, b int, c bool) bool {
+            return a < b == c
+        }
+    "#,
+    );
+}
+
 #[test]
 fn test_binary_arithmetic_result_in_variable() {
     assert_pass(
@@ -1000,11 +1420,117 @@ fn test_unary_minus_error_on_string() {
 }
 
 #[test]
-fn test_if_with_bool_param_pass() {
+fn test_unary_plus_int_pass() {
     assert_pass(
         r#"
-        func f(a bool) {
-            if a {
+        func f() int {
+            return +1
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_unary_plus_float_pass() {
+    assert_pass(
+        r#"
+        func f() float {
+            return +1.0
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_unary_plus_keeps_operand_type() {
+    assert_error(
+        r#"
+        func f() bool {
+            return +1
+        }
+    "#,
+        "incorrect return type: expected 'bool', got 'i32'",
+    );
+}
+
+#[test]
+fn test_unary_plus_error_on_bool() {
+    assert_error(
+        r#"
+        func f() bool {
+            return +true
+        }
+    "#,
+        "'+' operator can only be used on number types, got 'bool'",
+    );
+}
+
+#[test]
+fn test_compare_int_with_null_error() {
+    assert_error(
+        r#"
+        func f() bool {
+            return 1 == null
+        }
+    "#,
+        "cannot compare type 'i32' with null",
+    );
+}
+
+#[test]
+fn test_compare_null_with_param_error() {
+    assert_error(
+        r#"
+        func f(a bool) bool {
+            return null != a
+        }
+    "#,
+        "cannot compare type 'bool' with null",
+    );
+}
+
+#[test]
+fn test_null_ordering_comparison_error() {
+    assert_error(
+        r#"
+        func f(a int) bool {
+            return a < null
+        }
+    "#,
+        "null can only be compared using '==' or '!='",
+    );
+}
+
+#[test]
+fn test_null_outside_comparison_error() {
+    assert_error(
+        r#"
+        func f() {
+            a := null
+        }
+    "#,
+        "null can only be compared against nilable types",
+    );
+}
+
+#[test]
+fn test_pointer_types_are_nilable() {
+    let mut ctx = Context::new(Config::test());
+    let int = ctx.types.primitive(PrimitiveType::I32);
+    let ptr = ctx.types.get_or_intern(TypeKind::Pointer(int));
+    let alias = ctx.types.get_or_intern(TypeKind::Alias(ptr));
+
+    assert!(ctx.types.is_nilable(ptr));
+    assert!(ctx.types.is_nilable(alias));
+    assert!(!ctx.types.is_nilable(int));
+}
+
+#[test]
+fn test_if_with_bool_param_pass() {
+    assert_pass(
+        r#"
+        func f(a bool) {
+            if a {
             }
         }
     "#,
@@ -1145,6 +1671,40 @@ fn test_if_block_variable_not_visible_outside() {
     );
 }
 
+#[test]
+fn test_if_branch_variable_not_visible_in_else() {
+    assert_error(
+        r#"
+        func f(a bool) int {
+            if a {
+                x := 1
+            } else {
+                return x
+            }
+            return 0
+        }
+    "#,
+        "not declared",
+    );
+}
+
+#[test]
+fn test_if_branches_declare_same_name_pass() {
+    assert_pass(
+        r#"
+        func f(a bool, b bool) {
+            if a {
+                x := 1
+            } else if b {
+                x := true
+            } else {
+                x := 2.0
+            }
+        }
+    "#,
+    );
+}
+
 #[test]
 fn test_if_block_outer_variable_accessible() {
     assert_pass(
@@ -1909,6 +2469,46 @@ fn test_for_loop_expression() {
     );
 }
 
+#[test]
+fn test_for_loop_condition_only_pass() {
+    assert_pass(
+        r#"
+        func f(n int) {
+            i := 0
+            for i < n {
+                i += 1
+            }
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_for_loop_condition_only_error() {
+    assert_error(
+        r#"
+        func f(n int) {
+            for n {
+            }
+        }
+    "#,
+        "expression must be of type 'bool', got 'i32'",
+    );
+}
+
+#[test]
+fn test_for_loop_infinite_pass() {
+    assert_pass(
+        r#"
+        func f() {
+            for {
+                break
+            }
+        }
+    "#,
+    );
+}
+
 #[test]
 fn test_shadowing_type() {
     assert_error(
@@ -1936,6 +2536,23 @@ fn test_type_decl_alias_pass() {
     );
 }
 
+#[test]
+fn test_type_decl_alias_with_eq_compatible_as_param() {
+    assert_pass(
+        r#"
+        type Celsius = f32
+
+        func toKelvin(c f32) f32 {
+            return c + 273.15
+        }
+
+        func f(c Celsius) f32 {
+            return toKelvin(c)
+        }
+    "#,
+    );
+}
+
 #[test]
 fn test_type_decl_alias_compatible_with_base_in_return() {
     // Alias and base share the same TypeId, so returning one where the other
@@ -1983,6 +2600,74 @@ fn test_type_decl_alias_compatible_as_param() {
     );
 }
 
+#[test]
+fn test_struct_decl_as_param_and_return_pass() {
+    assert_pass(
+        r#"
+        struct Point {
+            x i32
+            y i32
+        }
+
+        func id(p Point) Point {
+            return p
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_struct_decl_nested_struct_field_pass() {
+    assert_pass(
+        r#"
+        struct Point { x i32, y i32 }
+        struct Line { from Point, to Point }
+
+        func f(l Line) Line {
+            return l
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_struct_decl_unknown_field_type_error() {
+    assert_error(
+        r#"
+        struct Point {
+            x Number
+        }
+    "#,
+        "not a type",
+    );
+}
+
+#[test]
+fn test_struct_decl_already_declared_error() {
+    assert_error(
+        r#"
+        struct Point { x i32 }
+        type Point i32
+    "#,
+        "already declared",
+    );
+}
+
+#[test]
+fn test_struct_decl_mismatched_struct_types_error() {
+    assert_error(
+        r#"
+        struct A { x i32 }
+        struct B { x i32 }
+
+        func f(a A) B {
+            return a
+        }
+    "#,
+        "incorrect return type: expected 'B', got 'A'",
+    );
+}
+
 #[test]
 fn test_type_decl_unique_not_compatible_with_base_in_return() {
     assert_error(
@@ -2101,6 +2786,35 @@ fn test_type_decl_unique_then_alias_not_compatible_with_primitive() {
     );
 }
 
+#[test]
+fn test_func_named_builtin_type_error() {
+    assert_error(
+        r#"
+        pub func int() {}
+    "#,
+        "'int' is a builtin type and cannot be redeclared",
+    );
+}
+
+#[test]
+fn test_type_decl_named_builtin_type_error() {
+    assert_error(
+        r#"
+        type string bool
+    "#,
+        "'string' is a builtin type and cannot be redeclared",
+    );
+}
+
+#[test]
+fn test_func_not_named_builtin_pass() {
+    assert_pass(
+        r#"
+        pub func len() {}
+    "#,
+    );
+}
+
 #[test]
 fn test_type_decl_duplicate_name_error() {
     assert_error(
@@ -2314,3 +3028,1021 @@ fn test_op_assign_numeric_pass() {
         "#,
     );
 }
+
+#[test]
+fn test_array_type_size() {
+    assert_error(
+        r#"
+        func f(a [4]int) int {
+            return a
+        }
+    "#,
+        "incorrect return type: expected 'i32', got '[4]i32'",
+    );
+}
+
+#[test]
+fn test_array_type_const_expr_size() {
+    assert_error(
+        r#"
+        type Buf [(2 + 2) * 2 - 1]int
+
+        func f(a Buf) int {
+            return a
+        }
+    "#,
+        "incorrect return type: expected 'i32', got '[7]i32'",
+    );
+}
+
+#[test]
+fn test_array_type_same_size_equivalent() {
+    assert_pass(
+        r#"
+        func f(a [2 + 2]int) [4]int {
+            return a
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_array_type_negative_size_error() {
+    assert_error(
+        r#"
+        func f(a [-1]int) {}
+    "#,
+        "array size cannot be negative",
+    );
+}
+
+#[test]
+fn test_array_type_variable_size_error() {
+    assert_error(
+        r#"
+        func f(x int, a [x]int) {}
+    "#,
+        "expected constant integer expression",
+    );
+}
+
+#[test]
+fn test_array_type_division_by_zero_error() {
+    assert_error(
+        r#"
+        type Buf [4 / 0]int
+    "#,
+        "division by zero in constant expression",
+    );
+}
+
+#[test]
+fn test_division_by_constant_zero_error() {
+    assert_error(
+        r#"
+        func f() int {
+            return 1 / 0
+        }
+    "#,
+        "division by zero",
+    );
+}
+
+#[test]
+fn test_modulo_by_constant_zero_error() {
+    assert_error(
+        r#"
+        func f() u32 {
+            return 1 % 0
+        }
+    "#,
+        "division by zero",
+    );
+}
+
+#[test]
+fn test_division_by_folded_zero_error() {
+    assert_error(
+        r#"
+        func f(x int) int {
+            return x / (2 - 2)
+        }
+    "#,
+        "division by zero",
+    );
+}
+
+#[test]
+fn test_div_assign_by_constant_zero_error() {
+    assert_error(
+        r#"
+        func f(x int) {
+            x /= 0
+        }
+    "#,
+        "division by zero",
+    );
+}
+
+#[test]
+fn test_division_by_variable_pass() {
+    assert_pass(
+        r#"
+        func f(x int) int {
+            return 1 / x
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_typed_var_decl_pass() {
+    assert_pass(
+        r#"
+        type Number int
+
+        func f() Number {
+            a int = 1
+            b Number = a
+            return b
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_typed_var_decl_uses_declared_type() {
+    assert_error(
+        r#"
+        unique type ID int
+
+        func f(id ID) bool {
+            a ID = id
+            return a
+        }
+    "#,
+        "incorrect return type: expected 'bool', got 'ID'",
+    );
+}
+
+#[test]
+fn test_typed_var_decl_mismatch_error() {
+    assert_error(
+        r#"
+        func f() {
+            a bool = 1
+        }
+    "#,
+        "mismatched types in declaration: expected 'bool', got 'i32'",
+    );
+}
+
+#[test]
+fn test_typed_var_decl_unknown_type_error() {
+    assert_error(
+        r#"
+        func f() {
+            a foo = 1
+        }
+    "#,
+        "not a type",
+    );
+}
+
+/// Function with the given number of nested blocks, including the body.
+fn nested_blocks(depth: usize) -> String {
+    let inner = depth - 1;
+    format!(
+        "func f() {{\n{}{}}}",
+        "if true {\n".repeat(inner),
+        "}\n".repeat(inner)
+    )
+}
+
+#[test]
+fn test_block_nesting_at_limit_pass() {
+    assert_pass(&nested_blocks(MAX_BLOCK_DEPTH));
+}
+
+#[test]
+fn test_block_nesting_past_limit_error() {
+    assert_error(
+        &nested_blocks(MAX_BLOCK_DEPTH + 1),
+        &format!("blocks nested too deeply, max depth is {MAX_BLOCK_DEPTH}"),
+    );
+}
+
+#[test]
+fn test_if_init_pass() {
+    assert_pass(
+        r#"
+        func g() int {
+            return 1
+        }
+
+        func f() int {
+            if x := g(); x > 0 {
+                return x
+            } else if y := x + 1; y > 0 {
+                return y
+            } else {
+                return x
+            }
+            return 0
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_if_init_scoped_to_if() {
+    assert_error(
+        r#"
+        func f() int {
+            if x := 1; x > 0 {
+            }
+            return x
+        }
+    "#,
+        "not declared",
+    );
+}
+
+#[test]
+fn test_if_init_redeclared_after_if() {
+    assert_pass(
+        r#"
+        func f() bool {
+            if x := 1; x > 0 {
+            }
+            x := true
+            return x
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_var_decl_scoped_to_block() {
+    assert_error(
+        r#"
+        func f(c bool) int {
+            if c {
+                x := 1
+            }
+            return x
+        }
+    "#,
+        "not declared",
+    );
+}
+
+#[test]
+fn test_var_decl_shadows_outer_scope() {
+    assert_pass(
+        r#"
+        func f(c bool) int {
+            x := 1
+            if c {
+                x := true
+            }
+            return x
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_for_initializer_scoped_to_loop() {
+    assert_error(
+        r#"
+        func f() int {
+            for i := 0; i < 3; i += 1 {
+            }
+            return i
+        }
+    "#,
+        "not declared",
+    );
+}
+
+#[test]
+fn test_for_initializer_redeclared_after_loop() {
+    assert_pass(
+        r#"
+        func f() int {
+            for i := 0; i < 3; i += 1 {
+            }
+            for i := 0; i < 3; i += 1 {
+            }
+            i := true
+            return 0
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_for_body_variable_scoped_to_iteration() {
+    assert_error(
+        r#"
+        func f() int {
+            for i := 0; i < 3; i += 1 {
+                n := i
+            }
+            return n
+        }
+    "#,
+        "not declared",
+    );
+}
+
+#[test]
+fn test_return_outside_function() {
+    // The parser only produces statements inside function bodies, so take
+    // the return out of one and check it on its own.
+    let ast = must(parse_string("func f() {\n return\n}"));
+    let ast::Decl::Func(func) = ast.decls.into_iter().next().unwrap() else {
+        panic!("expected function");
+    };
+    let stmt = func.body.stmts.into_iter().next().unwrap();
+
+    let mut ctx = Context::new(Config::test());
+    let symbols = SymbolList::new();
+    let consts = HashMap::new();
+    let mut checker = FileChecker::new(&mut ctx, &symbols, &consts, NamespaceList::new(), true);
+
+    match checker.emit_stmt(stmt) {
+        Ok(_) => panic!("expected error"),
+        Err(err) => assert_eq!(err.message, "return outside function"),
+    }
+}
+
+#[test]
+fn test_resolve_param_and_function_declaration() {
+    let ast = must(parse_string("func f(a i32) {\n}"));
+    let ast::Decl::Func(func) = ast.decls.into_iter().next().unwrap() else {
+        panic!("expected function");
+    };
+
+    let mut ctx = Context::new(Config::test());
+    let f = FunctionType {
+        params: vec![ctx.types.primitive(PrimitiveType::I32)],
+        ret: ctx.types.void(),
+    };
+
+    let id = ctx.symbols.add(CreateSymbol {
+        name: "f".into(),
+        alias: None,
+        kind: SymbolKind::Function {
+            is_inline: false,
+            is_naked: false,
+        },
+        ty: ctx.types.get_or_intern(TypeKind::Function(f.clone())),
+        origin: SymbolOrigin::Module {
+            modpath: new_modpath("main"),
+            pos: func.name.pos.clone(),
+            filename: "main.koi".into(),
+        },
+        is_exported: false,
+        no_mangle: false,
+    });
+
+    let mut symbols = SymbolList::new();
+    let sym = ModuleSymbol {
+        id,
+        kind: ModuleSymbolKind::Module,
+        exported: false,
+    };
+    assert!(symbols.add("f".into(), sym).is_ok());
+
+    let consts = HashMap::new();
+    let mut checker = FileChecker::new(&mut ctx, &symbols, &consts, NamespaceList::new(), true);
+    assert!(checker.enter_function(&f, &func.params).is_ok());
+
+    assert_eq!(checker.resolve("a"), Some((&func.params[0].name.pos, true)));
+    assert_eq!(checker.resolve("f"), Some((&func.name.pos, false)));
+    assert_eq!(checker.resolve("b"), None);
+}
+
+#[test]
+fn test_return_inside_nested_blocks() {
+    assert_pass(
+        r#"
+        func f(a bool) int {
+            while a {
+                if a {
+                    return 1
+                }
+            }
+            return 0
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_function_call_fail_call_int_variable() {
+    assert_error(
+        r#"
+        func f() {
+            x := 1
+            x()
+        }
+    "#,
+        "'x' is not a function (it is 'i32')",
+    );
+}
+
+#[test]
+fn test_function_call_fail_call_param() {
+    assert_error(
+        r#"
+        func f(s string) {
+            s(1)
+        }
+    "#,
+        "'s' is not a function (it is 'string')",
+    );
+}
+
+#[test]
+fn test_arrow_function_pass() {
+    assert_pass(
+        r#"
+        func sq(x int) int => x * x
+        func is_zero(x int) bool => x == 0
+    "#,
+    );
+}
+
+#[test]
+fn test_arrow_function_return_type_mismatch() {
+    assert_error(
+        r#"
+        func f(x int) bool => x + 1
+    "#,
+        "incorrect return type: expected 'bool', got 'i32'",
+    );
+}
+
+#[test]
+fn test_void_param_list_is_empty() {
+    assert_pass(
+        r#"
+        func f(void) int {
+            return 0
+        }
+
+        func g() int {
+            return f()
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_void_mixed_with_params_error() {
+    assert_error(
+        r#"
+        func f(a int, void) {}
+    "#,
+        "void must be the only parameter",
+    );
+}
+
+#[test]
+fn test_index_variable_pass() {
+    assert_pass(
+        r#"
+        func f(arr [3]int, i int) int {
+            n := arr[2]
+            return arr[i]
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_index_negative_constant_error() {
+    assert_error(
+        r#"
+        func f(arr [3]int) int {
+            return arr[-1]
+        }
+    "#,
+        "index out of range: -1",
+    );
+}
+
+#[test]
+fn test_index_past_end_constant_error() {
+    assert_error(
+        r#"
+        func f(arr [3]int) int {
+            return arr[1 + 2]
+        }
+    "#,
+        "index out of range: 3",
+    );
+}
+
+#[test]
+fn test_index_non_array_error() {
+    assert_error(
+        r#"
+        func f(n int) int {
+            return n[0]
+        }
+    "#,
+        "cannot index type 'i32'",
+    );
+}
+
+#[test]
+fn test_index_call_result_non_array_error() {
+    assert_error(
+        r#"
+        func g() bool {
+            return true
+        }
+
+        func f() bool {
+            return g()[0]
+        }
+    "#,
+        "cannot index type 'bool'",
+    );
+}
+
+#[test]
+fn test_index_chained_pass() {
+    assert_pass(
+        r#"
+        func f(grid [2][3]i32, i i32) i32 {
+            row [3]i32 = grid[i]
+            return grid[1][i] + row[0]
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_index_non_integer_error() {
+    assert_error(
+        r#"
+        func f(arr [3]int) int {
+            return arr[true]
+        }
+    "#,
+        "array index must be an integer, got 'bool'",
+    );
+}
+
+#[test]
+fn test_index_element_type() {
+    assert_error(
+        r#"
+        func f(arr [3][2]int) int {
+            return arr[0]
+        }
+    "#,
+        "incorrect return type: expected 'i32', got '[2]i32'",
+    );
+}
+
+#[test]
+fn test_index_slice_pass() {
+    assert_pass(
+        r#"
+        func f(s [][]int, i int) int {
+            row []int = s[i]
+            return row[100]
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_index_slice_negative_constant_error() {
+    assert_error(
+        r#"
+        func f(s []int) int {
+            return s[-1]
+        }
+    "#,
+        "index out of range: -1",
+    );
+}
+
+#[test]
+fn test_slice_not_equal_to_array() {
+    assert_error(
+        r#"
+        func f(a [3]int) {
+            s []int = a
+        }
+    "#,
+        "mismatched types in declaration: expected '[]i32', got '[3]i32'",
+    );
+}
+
+#[test]
+fn test_ternary_return_pass() {
+    assert_pass(
+        r#"
+        func f(cond bool, a int) int {
+            return cond ? a : 0
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_ternary_mismatched_branches_error() {
+    assert_error(
+        r#"
+        func f(cond bool) int {
+            return cond ? 1 : "x"
+        }
+    "#,
+        "ternary branches have different types ('i32' vs 'string')",
+    );
+}
+
+#[test]
+fn test_ternary_non_bool_condition_error() {
+    assert_error(
+        r#"
+        func f(n int) int {
+            return n ? 1 : 2
+        }
+    "#,
+        "expression must be of type 'bool', got 'i32'",
+    );
+}
+
+#[test]
+fn test_block_expr_pass() {
+    assert_pass(
+        r#"
+        func f(n int) int {
+            x := {
+                a := n * 2
+                a + 1
+            }
+            return x
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_block_expr_takes_type_of_last_expr() {
+    assert_error(
+        r#"
+        func f(n int) int {
+            return {
+                a := n
+                a == 1
+            }
+        }
+    "#,
+        "incorrect return type: expected 'i32', got 'bool'",
+    );
+}
+
+#[test]
+fn test_block_expr_must_end_with_expr_error() {
+    assert_error(
+        r#"
+        func f() {
+            x := {
+                a := 1
+            }
+        }
+    "#,
+        "block expression must end with an expression",
+    );
+}
+
+#[test]
+fn test_block_expr_empty_error() {
+    assert_error(
+        r#"
+        func f() {
+            x := {}
+        }
+    "#,
+        "block expression must end with an expression",
+    );
+}
+
+#[test]
+fn test_block_expr_scoped_variables() {
+    assert_error(
+        r#"
+        func f() int {
+            x := {
+                a := 1
+                a
+            }
+            return a
+        }
+    "#,
+        "not declared",
+    );
+}
+
+#[test]
+fn test_global_constant_pass() {
+    assert_pass(
+        r#"
+        max :: 10
+        name :: "koi"
+        enabled :: true
+        ratio :: -0.5
+
+        func f() int {
+            s string = name
+            if enabled && ratio < 1.0 {
+                return max
+            }
+            return 0
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_global_constant_declared_after_use() {
+    assert_pass(
+        r#"
+        func f() int {
+            return max
+        }
+
+        max :: 10
+    "#,
+    );
+}
+
+#[test]
+fn test_call_function_declared_after_use() {
+    assert_pass(
+        r#"
+        func f() int {
+            return g(1)
+        }
+
+        func g(n int) int {
+            return n
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_struct_declared_after_use() {
+    assert_pass(
+        r#"
+        func f(p Point) int {
+            return p.x
+        }
+
+        struct Point {
+            x int
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_mutually_recursive_functions() {
+    assert_pass(
+        r#"
+        func even(n int) bool {
+            if n == 0 {
+                return true
+            }
+            return odd(n - 1)
+        }
+
+        func odd(n int) bool {
+            if n == 0 {
+                return false
+            }
+            return even(n - 1)
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_global_constant_type_mismatch() {
+    assert_error(
+        r#"
+        name :: "koi"
+
+        func f() int {
+            return name
+        }
+    "#,
+        "incorrect return type: expected 'i32', got 'string'",
+    );
+}
+
+#[test]
+fn test_global_constant_assign_error() {
+    assert_error(
+        r#"
+        max :: 10
+
+        func f() {
+            max = 11
+        }
+    "#,
+        "cannot assign new value to a constant",
+    );
+}
+
+#[test]
+fn test_global_constant_non_constant_value_error() {
+    assert_error(
+        r#"
+        func g() int {
+            return 1
+        }
+
+        max :: g()
+    "#,
+        "expected constant integer expression",
+    );
+}
+
+#[test]
+fn test_global_constant_referencing_constant_pass() {
+    assert_pass(
+        r#"
+        name :: other
+        other :: "koi"
+        size :: max * 2
+        max :: 10
+
+        func f() string {
+            return name
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_global_constant_cycle_error() {
+    assert_error(
+        r#"
+        a :: b
+        b :: a
+    "#,
+        "constant cycle: a -> b -> a",
+    );
+}
+
+#[test]
+fn test_global_constant_self_reference_error() {
+    assert_error(
+        r#"
+        a :: a + 1
+    "#,
+        "constant cycle: a -> a",
+    );
+}
+
+#[test]
+fn test_global_constant_referencing_function_error() {
+    assert_error(
+        r#"
+        func g() int {
+            return 1
+        }
+
+        max :: g
+    "#,
+        "'g' is not a constant",
+    );
+}
+
+#[test]
+fn test_global_constant_with_type_pass() {
+    assert_pass(
+        r#"
+        type Name string
+
+        small u8 :: 255
+        big i64 :: -9223372036854775807
+        name Name :: "koi"
+        ratio f32 :: 0.5
+
+        func f() u8 {
+            n Name = name
+            return small
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_global_constant_with_type_mismatch() {
+    assert_error(
+        r#"
+        max string :: 10
+    "#,
+        "mismatched types in constant declaration: expected 'string', got 'i32'",
+    );
+}
+
+#[test]
+fn test_global_constant_with_float_type() {
+    assert_pass(
+        r#"
+        ratio f64 :: 0.5
+        scale f32 :: 2.0
+
+        func f() f64 {
+            return ratio
+        }
+
+        func g() f32 {
+            return scale
+        }
+    "#,
+    );
+}
+
+#[test]
+fn test_global_constant_float_with_int_type() {
+    assert_error(
+        r#"
+        ratio i64 :: 0.5
+    "#,
+        "mismatched types in constant declaration: expected 'i64', got 'f32'",
+    );
+}
+
+#[test]
+fn test_global_constant_with_type_overflow() {
+    assert_error(
+        r#"
+        max u8 :: 256
+    "#,
+        "constant value 256 does not fit in type 'u8'",
+    );
+}
+
+#[test]
+fn test_global_constant_with_type_negative_unsigned() {
+    assert_error(
+        r#"
+        max u32 :: -1
+    "#,
+        "constant value -1 does not fit in type 'u32'",
+    );
+}
+
+#[test]
+fn test_global_constant_with_type_assign_error() {
+    assert_error(
+        r#"
+        max i64 :: 10
+
+        func f() {
+            max = 11
+        }
+    "#,
+        "cannot assign new value to a constant",
+    );
+}
+
+#[test]
+fn test_global_constant_with_type_non_constant_value_error() {
+    assert_error(
+        r#"
+        func g() int {
+            return 1
+        }
+
+        max int :: g()
+    "#,
+        "expected constant integer expression",
+    );
+}
+
+#[test]
+fn test_global_constant_already_declared() {
+    assert_error(
+        r#"
+        max :: 10
+        max :: 11
+    "#,
+        "already declared",
+    );
+}
@@ -0,0 +1,112 @@
+use crate::{common::check_warnings, typecheck::check_const_conditions};
+
+#[test]
+fn test_if_true_warns() {
+    let src = r#"
+        func f() {
+            if true {
+            }
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_const_conditions),
+        vec!["condition is always true"]
+    );
+}
+
+#[test]
+fn test_for_false_warns() {
+    let src = r#"
+        func f() {
+            for i := 0; false; i += 1 {
+            }
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_const_conditions),
+        vec!["condition is always false"]
+    );
+}
+
+#[test]
+fn test_while_false_warns() {
+    let src = r#"
+        func f() {
+            while false {
+            }
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_const_conditions),
+        vec!["condition is always false"]
+    );
+}
+
+#[test]
+fn test_infinite_loop_no_warning() {
+    let src = r#"
+        func f() {
+            while true {
+                break
+            }
+        }
+    "#;
+    assert!(check_warnings(src, check_const_conditions).is_empty());
+}
+
+#[test]
+fn test_folded_conditions_warn() {
+    let src = r#"
+        func f(a bool) {
+            if !false && true {
+            } else if 1 > 2 {
+            } else if a || true {
+            } else if a {
+            }
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_const_conditions),
+        vec![
+            "condition is always true",
+            "condition is always false",
+            "condition is always true",
+        ]
+    );
+}
+
+#[test]
+fn test_non_constant_condition_no_warning() {
+    let src = r#"
+        func f(a int, b bool) {
+            if a > 1 {
+            }
+            while b && true {
+            }
+        }
+    "#;
+    assert!(check_warnings(src, check_const_conditions).is_empty());
+}
+
+#[test]
+fn test_ternary_condition_always_true() {
+    let src = r#"
+        func f() int {
+            return 1 < 2 ? 1 : 2
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_const_conditions),
+        vec!["condition is always true"]
+    );
+}
+
+#[test]
+fn test_ternary_condition_not_constant() {
+    let src = r#"
+        func f(a int) int {
+            return a < 2 ? 1 : 2
+        }
+    "#;
+    assert!(check_warnings(src, check_const_conditions).is_empty());
+}
@@ -0,0 +1,90 @@
+use crate::{
+    common::{ErrorStream, check_warnings, must, new_modpath, new_source_map},
+    config::Config,
+    context::Context,
+    parser::parse_source_map,
+    typecheck::{check_exported_signatures, check_fileset},
+};
+
+#[test]
+fn test_exported_function_private_param_warns() {
+    let src = r#"
+        struct Point { x int, y int }
+
+        pub func f(p Point) {}
+    "#;
+    assert_eq!(
+        check_warnings(src, check_exported_signatures),
+        vec!["exported function 'f' uses private type 'Point'"]
+    );
+}
+
+#[test]
+fn test_exported_function_private_return_warns() {
+    let src = r#"
+        unique type Id int
+
+        pub func f(ids []Id) (Id, bool) {
+            return ids[0], true
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_exported_signatures),
+        vec!["exported function 'f' uses private type 'Id'"]
+    );
+}
+
+#[test]
+fn test_exported_function_primitive_types_pass() {
+    let src = r#"
+        type Number int
+
+        pub func f(a int, b string, n Number) bool {
+            return true
+        }
+    "#;
+    assert!(check_warnings(src, check_exported_signatures).is_empty());
+}
+
+#[test]
+fn test_exported_function_exported_type_pass() {
+    let src = r#"
+        pub struct Point { x int, y int }
+
+        pub func f(p Point) {}
+    "#;
+    assert!(check_warnings(src, check_exported_signatures).is_empty());
+}
+
+#[test]
+fn test_private_function_private_type_pass() {
+    let src = r#"
+        struct Point { x int, y int }
+
+        func f(p Point) {}
+    "#;
+    assert!(check_warnings(src, check_exported_signatures).is_empty());
+}
+
+#[test]
+fn test_exported_function_imported_type_passes() {
+    let files = [
+        ("foo", "pub struct Point { x int, y int }"),
+        (
+            "main",
+            "import foo { Point }\n\npub func f(p Point) int {\n    return p.x\n}",
+        ),
+    ];
+
+    let mut ctx = Context::new(Config::test());
+    let mut ids = Vec::new();
+    for (name, src) in files {
+        let map = new_source_map(src);
+        let fs =
+            must(parse_source_map(new_modpath(name), &map, &ctx.config).map_err(ErrorStream::from));
+        let create = must(check_fileset(&mut ctx, fs).map_err(ErrorStream::from));
+        ids.push(ctx.modules.add(create));
+    }
+
+    assert!(check_exported_signatures(&ctx, ids[1]).is_empty());
+}
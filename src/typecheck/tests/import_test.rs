@@ -436,6 +436,87 @@ fn test_duplicate_alias() {
     );
 }
 
+#[test]
+fn test_import_alias_nested_module() {
+    assert_pass(&vec![
+        file(
+            "util.io",
+            r#"
+                pub func write() {}
+            "#,
+        ),
+        file(
+            "main",
+            r#"
+                import util.io as out
+
+                func main() int {
+                    out.write()
+                    return 0
+                }
+            "#,
+        ),
+    ]);
+}
+
+#[test]
+fn test_import_alias_hides_module_name() {
+    assert_error(
+        &vec![
+            file(
+                "foo",
+                r#"
+                pub func f() {}
+            "#,
+            ),
+            file(
+                "main",
+                r#"
+                import foo as bar
+
+                func main() int {
+                    foo.f()
+                    return 0
+                }
+            "#,
+            ),
+        ],
+        "not declared",
+    );
+}
+
+#[test]
+fn test_duplicate_alias_and_module_name() {
+    assert_error(
+        &vec![
+            file(
+                "foo",
+                r#"
+                pub func f() {}
+            "#,
+            ),
+            file(
+                "bar",
+                r#"
+                pub func f() {}
+            "#,
+            ),
+            file(
+                "main",
+                r#"
+                import foo as bar
+                import bar
+
+                func main() int {
+                    return 0
+                }
+            "#,
+            ),
+        ],
+        "duplicate namespace 'bar'",
+    );
+}
+
 #[test]
 fn test_duplicate_explicit_imports() {
     assert_errors(
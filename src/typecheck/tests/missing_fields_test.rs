@@ -0,0 +1,59 @@
+use crate::{common::check_warnings, typecheck::check_missing_fields};
+
+#[test]
+fn test_all_fields_given() {
+    let src = r#"
+        struct Point { x i32, y i32 }
+
+        func f() Point {
+            return Point{y: 2, x: 1}
+        }
+    "#;
+    assert!(check_warnings(src, check_missing_fields).is_empty());
+}
+
+#[test]
+fn test_missing_field_warns() {
+    let src = r#"
+        struct Point { x i32, y i32 }
+
+        func f() Point {
+            return Point{x: 1}
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_missing_fields),
+        vec!["missing field 'y' in literal of 'Point'"]
+    );
+}
+
+#[test]
+fn test_missing_fields_listed_in_order() {
+    let src = r#"
+        struct Color { r u8, g u8, b u8 }
+
+        func f() Color {
+            return Color{g: 1 as u8}
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_missing_fields),
+        vec!["missing fields 'r', 'b' in literal of 'Color'"]
+    );
+}
+
+#[test]
+fn test_missing_field_in_nested_literal() {
+    let src = r#"
+        struct Point { x i32, y i32 }
+        struct Line { from Point, to Point }
+
+        func f() Line {
+            return Line{from: Point{x: 1, y: 2}, to: Point{}}
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_missing_fields),
+        vec!["missing fields 'x', 'y' in literal of 'Point'"]
+    );
+}
@@ -1,5 +1,29 @@
+#[cfg(test)]
+mod bool_compare_test;
+
 #[cfg(test)]
 mod checker_test;
 
+#[cfg(test)]
+mod conditions_test;
+
+#[cfg(test)]
+mod exported_test;
+
 #[cfg(test)]
 mod import_test;
+
+#[cfg(test)]
+mod missing_fields_test;
+
+#[cfg(test)]
+mod reachable_test;
+
+#[cfg(test)]
+mod rules_test;
+
+#[cfg(test)]
+mod shadowing_test;
+
+#[cfg(test)]
+mod unreachable_test;
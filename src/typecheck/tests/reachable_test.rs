@@ -0,0 +1,148 @@
+use std::collections::HashSet;
+
+use crate::{
+    common::must_check,
+    error::Severity,
+    typecheck::{call_graph, check_unused_funcs, reachable_funcs},
+};
+
+fn reachable(src: &str) -> HashSet<String> {
+    let (ctx, id) = must_check(src);
+    reachable_funcs(&ctx, id)
+}
+
+fn set(names: &[&str]) -> HashSet<String> {
+    names.iter().map(|s| s.to_string()).collect()
+}
+
+#[test]
+fn test_reachable_called_and_uncalled() {
+    let src = r#"
+        func used() int {
+            return 1
+        }
+
+        func unused() int {
+            return 2
+        }
+
+        func main() int {
+            return used()
+        }
+    "#;
+
+    assert_eq!(reachable(src), set(&["main", "used"]));
+
+    let (ctx, id) = must_check(src);
+    let diag = check_unused_funcs(&ctx, id);
+
+    assert_eq!(diag.num_errors(), 1);
+    assert_eq!(diag.get(0).message, "function 'unused' is never used");
+    assert_eq!(diag.get(0).severity(), Severity::Warning);
+}
+
+#[test]
+fn test_reachable_transitive() {
+    let src = r#"
+        func c() int {
+            return 0
+        }
+
+        func b() int {
+            if true {
+                return c()
+            }
+            return 1
+        }
+
+        func a() int {
+            return b()
+        }
+
+        func main() int {
+            return a()
+        }
+    "#;
+
+    assert_eq!(reachable(src), set(&["main", "a", "b", "c"]));
+}
+
+#[test]
+fn test_reachable_exported_is_root() {
+    let src = r#"
+        func helper() int {
+            return 0
+        }
+
+        pub func api() int {
+            return helper()
+        }
+    "#;
+
+    assert_eq!(reachable(src), set(&["api", "helper"]));
+}
+
+#[test]
+fn test_reachable_recursion_not_root() {
+    let src = r#"
+        func loop(n int) int {
+            return loop(n)
+        }
+
+        func main() int {
+            return 0
+        }
+    "#;
+
+    assert_eq!(reachable(src), set(&["main"]));
+}
+
+#[test]
+fn test_call_graph() {
+    let src = r#"
+        func b() int {
+            return 1
+        }
+
+        func c(n int) int {
+            return n
+        }
+
+        func a() int {
+            if b() == 1 {
+                return c(b())
+            }
+            return c(2)
+        }
+    "#;
+
+    let (ctx, id) = must_check(src);
+    let graph = call_graph(&ctx, id);
+
+    assert_eq!(graph["a"], vec!["b", "c"]);
+    assert!(graph["b"].is_empty());
+    assert!(graph["c"].is_empty());
+    assert_eq!(graph.len(), 3);
+}
+
+#[test]
+fn test_call_graph_skips_local_callee() {
+    let src = r#"
+        extern func ext() int
+
+        func b() int {
+            return 1
+        }
+
+        func a() int {
+            f := b
+            ext()
+            return f()
+        }
+    "#;
+
+    let (ctx, id) = must_check(src);
+    let graph = call_graph(&ctx, id);
+
+    assert_eq!(graph["a"], vec!["ext"]);
+}
@@ -0,0 +1,87 @@
+use crate::{
+    common::check_string,
+    config::Config,
+    context::Context,
+    error::{Diagnostics, error_span},
+    typecheck::{ForbidCall, Rule},
+    types::Decl,
+};
+
+#[test]
+fn test_forbid_call_rule_fires() {
+    let mut ctx = Context::new(Config::test());
+    ctx.add_rule(ForbidCall::new("danger"));
+
+    let res = check_string(
+        &mut ctx,
+        r#"
+        func danger() {
+        }
+
+        func main() int {
+            danger()
+            return 0
+        }
+    "#,
+    );
+
+    match res {
+        Ok(_) => panic!("expected error"),
+        Err(errs) => {
+            assert_eq!(errs.len(), 1);
+            assert_eq!(errs.get(0).message, "call to 'danger' is forbidden");
+        }
+    }
+}
+
+#[test]
+fn test_forbid_call_rule_ignores_other_calls() {
+    let mut ctx = Context::new(Config::test());
+    ctx.add_rule(ForbidCall::new("danger"));
+
+    let res = check_string(
+        &mut ctx,
+        r#"
+        func safe() {
+        }
+
+        func main() int {
+            safe()
+            return 0
+        }
+    "#,
+    );
+
+    assert!(res.is_ok());
+}
+
+struct NoFuncNamedFoo;
+
+impl Rule for NoFuncNamedFoo {
+    fn check_decl(&self, _ctx: &Context, decl: &Decl, diag: &mut Diagnostics) {
+        if let Decl::Func(func) = decl
+            && func.name == "foo"
+        {
+            diag.add(error_span("bad name", &func.meta));
+        }
+    }
+}
+
+#[test]
+fn test_custom_rule_on_decl() {
+    let mut ctx = Context::new(Config::test());
+    ctx.add_rule(NoFuncNamedFoo);
+
+    let res = check_string(
+        &mut ctx,
+        r#"
+        func foo() {
+        }
+    "#,
+    );
+
+    match res {
+        Ok(_) => panic!("expected error"),
+        Err(errs) => assert_eq!(errs.get(0).message, "bad name"),
+    }
+}
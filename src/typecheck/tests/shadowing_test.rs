@@ -0,0 +1,47 @@
+use crate::{
+    common::{check_warnings, new_modpath, new_source_map},
+    config::Config,
+    context::Context,
+    parser::parse_source_map,
+    typecheck::{check_builtin_shadowing, check_fileset},
+};
+
+#[test]
+fn test_param_named_builtin_type_warns() {
+    let src = r#"
+        func f(string int, n int) int {
+            return string + n
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_builtin_shadowing),
+        vec!["parameter 'string' in function 'f' shadows a builtin type"]
+    );
+}
+
+#[test]
+fn test_param_named_builtin_type_reported_at_param() {
+    let map = new_source_map("func f(n int, string int) int {\n    return string + n\n}");
+    let mut ctx = Context::new(Config::test());
+    let Ok(fs) = parse_source_map(new_modpath("main"), &map, &ctx.config) else {
+        panic!("expected source to parse");
+    };
+    let Ok(create) = check_fileset(&mut ctx, fs) else {
+        panic!("expected source to check");
+    };
+    let id = ctx.modules.add(create);
+
+    let diag = check_builtin_shadowing(&ctx, id);
+    let caret = diag.render(&map).lines().nth(4).unwrap().to_owned();
+    assert_eq!(caret, format!("    |    {}^^^^^^", " ".repeat(14)));
+}
+
+#[test]
+fn test_normally_named_params_pass() {
+    let src = r#"
+        pub func len(s string) int {
+            return 0
+        }
+    "#;
+    assert!(check_warnings(src, check_builtin_shadowing).is_empty());
+}
@@ -0,0 +1,150 @@
+use crate::{common::check_warnings, typecheck::check_unreachable_code};
+
+#[test]
+fn test_statement_after_break_warns() {
+    let src = r#"
+        func f() {
+            while true {
+                break
+                x := 1
+            }
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_unreachable_code),
+        vec!["unreachable code after 'break'"]
+    );
+}
+
+#[test]
+fn test_statement_after_continue_warns() {
+    let src = r#"
+        func f() {
+            for i := 0; i < 10; i += 1 {
+                continue
+                i += 2
+            }
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_unreachable_code),
+        vec!["unreachable code after 'continue'"]
+    );
+}
+
+#[test]
+fn test_statement_after_return_warns() {
+    let src = r#"
+        func f() int {
+            return 1
+            x := 2
+            return x
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_unreachable_code),
+        vec!["unreachable code after 'return'"]
+    );
+}
+
+#[test]
+fn test_break_as_last_statement_no_warning() {
+    let src = r#"
+        func f(n int) {
+            while true {
+                n -= 1
+                if n == 0 {
+                    break
+                }
+                continue
+            }
+        }
+    "#;
+    assert!(check_warnings(src, check_unreachable_code).is_empty());
+}
+
+#[test]
+fn test_break_in_nested_block_does_not_end_outer() {
+    let src = r#"
+        func f(n int) int {
+            while true {
+                if n > 0 {
+                    break
+                }
+                n += 1
+            }
+            return n
+        }
+    "#;
+    assert!(check_warnings(src, check_unreachable_code).is_empty());
+}
+
+#[test]
+fn test_unreachable_reported_once_per_block() {
+    let src = r#"
+        func f() {
+            while true {
+                break
+                x := 1
+                y := 2
+            }
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_unreachable_code),
+        vec!["unreachable code after 'break'"]
+    );
+}
+
+#[test]
+fn test_unreachable_in_else_branch() {
+    let src = r#"
+        func f(a bool) int {
+            if a {
+                return 1
+            } else {
+                return 2
+                a = true
+            }
+            return 0
+        }
+    "#;
+    assert_eq!(
+        check_warnings(src, check_unreachable_code),
+        vec!["unreachable code after 'return'"]
+    );
+}
+
+#[test]
+fn test_return_in_nested_if_does_not_end_outer() {
+    let src = r#"
+        func f(c bool) int {
+            if c {
+                return 1
+            }
+            return 0
+        }
+    "#;
+
+    assert!(check_warnings(src, check_unreachable_code).is_empty());
+}
+
+#[test]
+fn test_statement_after_exhaustive_if_warns() {
+    let src = r#"
+        func f(c bool) int {
+            a := 0
+            if c {
+                return 1
+            } else {
+                return 2
+            }
+            a = 1
+        }
+    "#;
+
+    assert_eq!(
+        check_warnings(src, check_unreachable_code),
+        vec!["unreachable code after 'return'"]
+    );
+}
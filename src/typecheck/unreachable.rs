@@ -0,0 +1,126 @@
+use crate::{
+    context::Context,
+    error::{Diagnostics, error_span},
+    module::{ModuleId, ModuleKind},
+    types::{BlockNode, Decl, ElseBlock, Expr, Stmt, Walker, walk_decl},
+};
+
+/// Report a warning for the first statement following a return, break, or
+/// continue in the same block, as it can never be executed. An if statement
+/// where every branch returns counts as a return.
+pub fn check_unreachable_code(ctx: &Context, id: ModuleId) -> Diagnostics {
+    let mut checker = UnreachableChecker {
+        diag: Diagnostics::new(),
+    };
+
+    if let ModuleKind::Source { files, .. } = &ctx.modules.get(id).kind {
+        for decl in files.iter().flat_map(|file| &file.ast.decls) {
+            walk_decl(&mut checker, decl);
+        }
+    }
+
+    checker.diag
+}
+
+struct UnreachableChecker {
+    diag: Diagnostics,
+}
+
+impl UnreachableChecker {
+    /// Check a single block. Nested blocks are checked when the walker
+    /// reaches the statement or expression owning them.
+    fn check_block(&mut self, block: &BlockNode) {
+        let mut stmts = block.stmts.iter();
+
+        for stmt in stmts.by_ref() {
+            if let Some(kw) = terminator(stmt) {
+                if let Some(next) = stmts.next() {
+                    let msg = format!("unreachable code after '{}'", kw);
+                    self.diag.add(error_span(&msg, next).as_warning());
+                }
+                return;
+            }
+        }
+    }
+}
+
+impl Walker for UnreachableChecker {
+    fn decl(&mut self, decl: &Decl) {
+        if let Decl::Func(node) = decl {
+            self.check_block(&node.body);
+        }
+    }
+
+    fn stmt(&mut self, stmt: &Stmt) {
+        match stmt {
+            Stmt::If(node) => {
+                self.check_block(&node.block);
+
+                let mut elseif = &*node.elseif;
+                loop {
+                    match elseif {
+                        ElseBlock::ElseIf(node) => {
+                            self.check_block(&node.block);
+                            elseif = &node.elseif;
+                        }
+                        ElseBlock::Else(block) => {
+                            self.check_block(block);
+                            break;
+                        }
+                        ElseBlock::None => break,
+                    }
+                }
+            }
+            Stmt::While(node) => self.check_block(&node.block),
+            Stmt::For(node) => self.check_block(&node.block),
+            _ => {}
+        }
+    }
+
+    fn expr(&mut self, expr: &Expr) {
+        if let Expr::Block(node) = expr {
+            self.check_block(&node.block);
+        }
+    }
+}
+
+/// Get the keyword of a statement which ends control flow in its block.
+fn terminator(stmt: &Stmt) -> Option<&'static str> {
+    match stmt {
+        Stmt::Return(_) => Some("return"),
+        Stmt::Break(_) => Some("break"),
+        Stmt::Continue(_) => Some("continue"),
+        _ if always_returns(stmt) => Some("return"),
+        _ => None,
+    }
+}
+
+/// Check if a statement returns on every path. A return nested in an if only
+/// counts when there is an else and all branches return.
+fn always_returns(stmt: &Stmt) -> bool {
+    let block_returns = |block: &BlockNode| block.stmts.last().is_some_and(always_returns);
+
+    match stmt {
+        Stmt::Return(_) => true,
+        Stmt::If(node) => {
+            if !block_returns(&node.block) {
+                return false;
+            }
+
+            let mut elseif = &*node.elseif;
+            loop {
+                match elseif {
+                    ElseBlock::ElseIf(node) => {
+                        if !block_returns(&node.block) {
+                            return false;
+                        }
+                        elseif = &node.elseif;
+                    }
+                    ElseBlock::Else(block) => return block_returns(block),
+                    ElseBlock::None => return false,
+                }
+            }
+        }
+        _ => false,
+    }
+}
@@ -1,6 +1,8 @@
 mod nodes;
+mod walk;
 
 pub use nodes::*;
+pub use walk::*;
 
 use std::{fmt, hash::Hash};
 use strum_macros::EnumIter;
@@ -19,7 +21,9 @@ pub struct Type {
 #[derive(Debug, Clone, PartialEq, Eq, Hash)]
 pub enum TypeKind {
     Primitive(PrimitiveType),
-    Array(TypeId),
+    Array(TypeId, usize), // Item type and fixed length
+    Slice(TypeId),        // Item type, length known at runtime
+    Tuple(Vec<TypeId>),   // Multiple return values
     Pointer(TypeId),
     Alias(TypeId),          // Refers to another type definition
     Unique(String, TypeId), // Distinct nominal type with name
@@ -27,6 +31,9 @@ pub enum TypeKind {
     /// List of parameter types and a return
     /// type (void for no return)
     Function(FunctionType),
+
+    /// Named list of fields
+    Struct(StructType),
 }
 
 pub enum CastKind {
@@ -62,6 +69,10 @@ pub enum PrimitiveType {
     String,
 }
 
+/// Size of a pointer in bytes. All supported targets are 64-bit, so pointers,
+/// function references, and strings are 8 bytes wide and 8 byte aligned.
+pub const PTR_SIZE: usize = 8;
+
 impl PrimitiveType {
     pub fn bytes(&self) -> usize {
         match self {
@@ -70,10 +81,15 @@ impl PrimitiveType {
             PrimitiveType::I16 | PrimitiveType::U16 => 2,
             PrimitiveType::I32 | PrimitiveType::U32 | PrimitiveType::F32 => 4,
             PrimitiveType::I64 | PrimitiveType::U64 | PrimitiveType::F64 => 8,
-            PrimitiveType::String => todo!(),
+            PrimitiveType::String => PTR_SIZE,
         }
     }
 
+    /// Required alignment in bytes. Primitives are aligned to their own size.
+    pub fn align(&self) -> usize {
+        self.bytes().max(1)
+    }
+
     pub fn is_int(&self) -> bool {
         matches!(
             self,
@@ -99,6 +115,49 @@ pub struct FunctionType {
     pub ret: TypeId,
 }
 
+#[derive(Debug, Clone, PartialEq, Eq, Hash)]
+pub struct StructType {
+    pub name: String,
+    /// Path of the declaring module, so structs with the same name and
+    /// fields in different modules are distinct types.
+    pub module: String,
+    /// Field names and types in declaration order
+    pub fields: Vec<(String, TypeId)>,
+}
+
+/// Value of a variable which is not explicitly initialized. Aggregates hold
+/// the zero value of each element or field in order.
+#[derive(Debug, Clone, PartialEq)]
+pub enum ZeroValue {
+    Int,
+    Float,
+    Bool,
+    String,
+    Null,
+    Aggregate(Vec<ZeroValue>),
+}
+
+impl fmt::Display for ZeroValue {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            ZeroValue::Int => write!(f, "0"),
+            ZeroValue::Float => write!(f, "0.0"),
+            ZeroValue::Bool => write!(f, "false"),
+            ZeroValue::String => write!(f, "\"\""),
+            ZeroValue::Null => write!(f, "null"),
+            ZeroValue::Aggregate(values) => write!(
+                f,
+                "{{{}}}",
+                values
+                    .iter()
+                    .map(|v| v.to_string())
+                    .collect::<Vec<_>>()
+                    .join(", ")
+            ),
+        }
+    }
+}
+
 impl fmt::Display for PrimitiveType {
     fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
         write!(f, "{}", format!("{:?}", self).to_lowercase())
@@ -50,6 +50,7 @@ pub fn ast_node_to_meta(node: &dyn Node) -> NodeMeta {
 pub enum Decl {
     Extern(ExternNode),
     Func(FuncNode),
+    Const(ConstNode),
 }
 
 pub enum Stmt {
@@ -70,6 +71,11 @@ pub enum Expr {
     Call(CallNode),
     Member(MemberNode),
     NamespaceMember(NamespaceMemberNode),
+    Index(IndexNode),
+    StructLit(StructLitNode),
+    Tuple(TupleNode),
+    Ternary(TernaryNode),
+    Block(BlockExprNode),
     Binary(BinaryNode),
     Unary(UnaryNode),
     Cast(CastNode),
@@ -126,17 +132,43 @@ pub struct FuncNode {
     pub ty: TypeId,
     pub meta: NodeMeta,
     pub name: String,
-    pub params: Vec<String>,
+    pub params: Vec<ParamNode>,
     pub public: bool,
     pub body: BlockNode,
 }
 
+/// Function parameter. The position is that of its name.
+pub struct ParamNode {
+    pub name: String,
+    pub pos: Pos,
+    pub end: Pos,
+}
+
+impl Span for ParamNode {
+    fn pos(&self) -> &Pos {
+        &self.pos
+    }
+
+    fn end(&self) -> &Pos {
+        &self.end
+    }
+}
+
 pub struct ExternNode {
     pub ty: TypeId,
     pub meta: NodeMeta,
     pub name: String,
 }
 
+pub struct ConstNode {
+    pub ty: TypeId,
+    pub meta: NodeMeta,
+    pub name: String,
+    pub public: bool,
+    /// Value folded to a single literal
+    pub value: LiteralNode,
+}
+
 pub enum ElseBlock {
     ElseIf(Box<IfNode>),
     Else(Box<BlockNode>),
@@ -145,6 +177,7 @@ pub enum ElseBlock {
 
 pub struct IfNode {
     pub meta: NodeMeta,
+    pub init: Option<Box<Stmt>>,
     pub expr: Expr,
     pub block: BlockNode,
     pub elseif: Box<ElseBlock>,
@@ -177,6 +210,45 @@ pub struct MemberNode {
     pub field: String,
 }
 
+pub struct IndexNode {
+    pub ty: TypeId,
+    pub meta: NodeMeta,
+    pub expr: Box<Expr>,
+    pub index: Box<Expr>,
+}
+
+/// Struct literal. Fields are in the order written, fields not given are
+/// left out.
+pub struct StructLitNode {
+    pub ty: TypeId,
+    pub meta: NodeMeta,
+    pub fields: Vec<(String, Expr)>,
+}
+
+/// Multiple return values
+pub struct TupleNode {
+    pub ty: TypeId,
+    pub meta: NodeMeta,
+    pub exprs: Vec<Expr>,
+}
+
+/// Block evaluating to its final expression. The statements before it are
+/// kept in block.
+pub struct BlockExprNode {
+    pub ty: TypeId,
+    pub meta: NodeMeta,
+    pub block: BlockNode,
+    pub expr: Box<Expr>,
+}
+
+pub struct TernaryNode {
+    pub ty: TypeId,
+    pub meta: NodeMeta,
+    pub cond: Box<Expr>,
+    pub then: Box<Expr>,
+    pub otherwise: Box<Expr>,
+}
+
 pub struct NamespaceMemberNode {
     pub ty: TypeId,
     pub meta: NodeMeta,
@@ -190,6 +262,7 @@ pub struct LiteralNode {
     pub kind: LiteralKind,
 }
 
+#[derive(Clone)]
 pub enum LiteralKind {
     Ident(String),
     String(String),
@@ -198,6 +271,7 @@ pub enum LiteralKind {
     Float(f64),
     Bool(bool),
     Char(u8),
+    Null,
 }
 
 impl From<TokenKind> for LiteralKind {
@@ -210,7 +284,7 @@ impl From<TokenKind> for LiteralKind {
             TokenKind::CharLit(c) => LiteralKind::Char(c),
             TokenKind::True => LiteralKind::Bool(true),
             TokenKind::False => LiteralKind::Bool(false),
-            TokenKind::Null => todo!(),
+            TokenKind::Null => LiteralKind::Null,
             _ => panic!("unhandled token kind in conversion, {:?}", kind),
         }
     }
@@ -220,7 +294,8 @@ pub struct VarDeclNode {
     pub ty: TypeId,
     pub meta: NodeMeta,
     pub name: String,
-    pub value: Expr,
+    /// Value is none for zero initialized variables.
+    pub value: Option<Expr>,
 }
 
 pub struct VarAssignNode {
@@ -327,6 +402,7 @@ impl_node_for_enum!(Decl {
     meta => [
         Func,
         Extern,
+        Const,
     ],
     delegate => []
 });
@@ -354,6 +430,11 @@ impl_node_for_enum!(Expr {
         Call,
         Member,
         NamespaceMember,
+        Index,
+        StructLit,
+        Tuple,
+        Ternary,
+        Block,
         Binary,
         Unary,
         Cast,
@@ -374,7 +455,11 @@ macro_rules! impl_typed_node_enum {
     };
 }
 
-impl_typed_node_enum!(Decl { Func, Extern });
+impl_typed_node_enum!(Decl {
+    Func,
+    Extern,
+    Const
+});
 
 impl_typed_node_enum!(Stmt {
     Return,
@@ -394,6 +479,11 @@ impl_typed_node_enum!(Expr {
     Literal,
     Member,
     NamespaceMember,
+    Index,
+    StructLit,
+    Tuple,
+    Ternary,
+    Block,
     Unary,
     Binary,
     Cast,
@@ -430,6 +520,7 @@ macro_rules! impl_typed_node {
 impl_typed_node!(
     ExternNode,
     FuncNode,
+    ConstNode,
     LiteralNode,
     CallNode,
     ReturnNode,
@@ -437,6 +528,11 @@ impl_typed_node!(
     VarAssignNode,
     NamespaceMemberNode,
     MemberNode,
+    IndexNode,
+    StructLitNode,
+    TupleNode,
+    TernaryNode,
+    BlockExprNode,
     UnaryNode,
     BinaryNode,
     CastNode,
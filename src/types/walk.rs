@@ -0,0 +1,128 @@
+use crate::types::{BlockNode, Decl, ElseBlock, Expr, Stmt};
+
+/// Walker is called for each node when walking a typed AST. Nodes are visited
+/// before their children. All methods default to doing nothing.
+pub trait Walker {
+    fn decl(&mut self, _decl: &Decl) {}
+    fn stmt(&mut self, _stmt: &Stmt) {}
+    fn expr(&mut self, _expr: &Expr) {}
+}
+
+pub fn walk_decl(w: &mut dyn Walker, decl: &Decl) {
+    w.decl(decl);
+    match decl {
+        Decl::Func(node) => walk_block(w, &node.body),
+        Decl::Extern(_) | Decl::Const(_) => {}
+    }
+}
+
+pub fn walk_block(w: &mut dyn Walker, block: &BlockNode) {
+    for stmt in &block.stmts {
+        walk_stmt(w, stmt);
+    }
+}
+
+pub fn walk_stmt(w: &mut dyn Walker, stmt: &Stmt) {
+    w.stmt(stmt);
+    match stmt {
+        Stmt::Return(node) => {
+            if let Some(expr) = &node.expr {
+                walk_expr(w, expr);
+            }
+        }
+        Stmt::VarDecl(node) => {
+            if let Some(value) = &node.value {
+                walk_expr(w, value);
+            }
+        }
+        Stmt::VarAssign(node) => {
+            walk_expr(w, &node.lval);
+            walk_expr(w, &node.rval);
+        }
+        Stmt::OpAssign(node) => {
+            walk_expr(w, &node.lval);
+            walk_expr(w, &node.rval);
+        }
+        Stmt::ExprStmt(expr) => walk_expr(w, expr),
+        Stmt::If(node) => {
+            if let Some(init) = &node.init {
+                walk_stmt(w, init);
+            }
+            walk_expr(w, &node.expr);
+            walk_block(w, &node.block);
+
+            let mut elseif = &*node.elseif;
+            loop {
+                match elseif {
+                    ElseBlock::ElseIf(node) => {
+                        if let Some(init) = &node.init {
+                            walk_stmt(w, init);
+                        }
+                        walk_expr(w, &node.expr);
+                        walk_block(w, &node.block);
+                        elseif = &node.elseif;
+                    }
+                    ElseBlock::Else(block) => {
+                        walk_block(w, block);
+                        break;
+                    }
+                    ElseBlock::None => break,
+                }
+            }
+        }
+        Stmt::While(node) => {
+            walk_expr(w, &node.expr);
+            walk_block(w, &node.block);
+        }
+        Stmt::For(node) => {
+            walk_stmt(w, &node.initializer);
+            walk_expr(w, &node.condition);
+            walk_stmt(w, &node.increment);
+            walk_block(w, &node.block);
+        }
+        Stmt::Break(_) | Stmt::Continue(_) => {}
+    }
+}
+
+pub fn walk_expr(w: &mut dyn Walker, expr: &Expr) {
+    w.expr(expr);
+    match expr {
+        Expr::Call(node) => {
+            walk_expr(w, &node.callee);
+            for arg in &node.args {
+                walk_expr(w, arg);
+            }
+        }
+        Expr::Member(node) => walk_expr(w, &node.expr),
+        Expr::Index(node) => {
+            walk_expr(w, &node.expr);
+            walk_expr(w, &node.index);
+        }
+        Expr::StructLit(node) => {
+            for (_, value) in &node.fields {
+                walk_expr(w, value);
+            }
+        }
+        Expr::Tuple(node) => {
+            for expr in &node.exprs {
+                walk_expr(w, expr);
+            }
+        }
+        Expr::Block(node) => {
+            walk_block(w, &node.block);
+            walk_expr(w, &node.expr);
+        }
+        Expr::Ternary(node) => {
+            walk_expr(w, &node.cond);
+            walk_expr(w, &node.then);
+            walk_expr(w, &node.otherwise);
+        }
+        Expr::Binary(node) => {
+            walk_expr(w, &node.lhs);
+            walk_expr(w, &node.rhs);
+        }
+        Expr::Unary(node) => walk_expr(w, &node.rhs),
+        Expr::Cast(node) => walk_expr(w, &node.expr),
+        Expr::Literal(_) | Expr::NamespaceMember(_) => {}
+    }
+}